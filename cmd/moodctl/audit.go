@@ -0,0 +1,67 @@
+// cmd/moodctl/audit.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// auditPage is what audit list reports.
+type auditPage struct {
+	Events []*data.AuditEvent `json:"events"`
+	Page   int                `json:"page"`
+	Pages  int                `json:"pages"`
+	Total  int                `json:"total"`
+}
+
+// auditList prints the audit log newest first, across every actor: the owner,
+// API tokens, share link visitors, rejected API clients and moodctl itself. The
+// web app's activity page shows the same log but can't filter by actor.
+func (c *cli) auditList(args []string) error {
+	fs := c.newFlagSet("audit list")
+	action := fs.String("action", "", "Only events with this action, e.g. token.reject")
+	targetType := fs.String("target-type", "", "Only events for this target type, e.g. share")
+	targetID := fs.Int64("target-id", 0, "Only events for this target ID")
+	actor := fs.String("actor", "", `Only events by this actor, e.g. "share link visitor"`)
+	page := fs.Int("page", 1, "Page to show, from 1")
+	limit := fs.Int("limit", 50, "Events per page")
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *action != "" && !slices.Contains(data.AuditActions, *action) {
+		return fmt.Errorf("unknown -action %q", *action)
+	}
+	if *targetType != "" && !slices.Contains(data.AuditTargetTypes, *targetType) {
+		return fmt.Errorf("unknown -target-type %q", *targetType)
+	}
+	if *page < 1 || *limit < 1 {
+		return fmt.Errorf("-page and -limit must be at least 1")
+	}
+
+	m := &data.AuditModel{DB: c.db, QueryTimeout: c.queryTimeout}
+	filter := data.AuditFilter{Action: *action, TargetType: *targetType, TargetID: *targetID, Actor: *actor}
+	events, info, err := m.GetPage(filter, *page, *limit)
+	if err != nil {
+		return err
+	}
+	if events == nil {
+		events = []*data.AuditEvent{} // Write [] rather than null
+	}
+
+	result := auditPage{Events: events, Page: info.Page, Pages: info.LastPage, Total: info.TotalRecords}
+	return c.print(result, func(w io.Writer) {
+		for _, e := range events {
+			fmt.Fprintf(w, "%s  %-20s %s:%d  %s", e.OccurredAt.UTC().Format(time.RFC3339), e.Action, e.TargetType, e.TargetID, e.Actor)
+			if e.IP != "" {
+				fmt.Fprintf(w, " from %s", e.IP)
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "\npage %d of %d, %d events\n", result.Page, max(result.Pages, 1), result.Total)
+	})
+}
@@ -0,0 +1,242 @@
+// cmd/moodctl/main.go
+
+// Command moodctl performs operator tasks against the Feel Flow database
+// without hand-written SQL. It uses the same internal/data models as the web
+// app, so encryption and the audit log behave exactly as they do there.
+//
+//	moodctl [global flags] <group> <command> [flags] [args]
+//
+// Run moodctl -h for the list of commands.
+//
+// There are no users commands yet: the app has a single owner and no accounts
+// or passwords to manage. Nor are there purge-trash or reindex-search commands:
+// notes are deleted immediately rather than trashed, and there is no search
+// index to rebuild (see note_cipher.go for why search can't run in SQL).
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/mickali02/mood-notes-app/internal/data"
+	"github.com/mickali02/mood-notes-app/internal/vault"
+)
+
+// auditActor is recorded in the audit log for changes made through moodctl.
+const auditActor = "moodctl"
+
+// cli holds the global options and the connections shared by every command.
+type cli struct {
+	driver       string
+	dsn          string
+	masterKeys   string
+	queryTimeout time.Duration
+	json         bool
+
+	stdout io.Writer
+	stderr io.Writer
+	db     *sql.DB
+}
+
+// command is one "<group> <name>" subcommand.
+type command struct {
+	group, name string
+	summary     string
+	needsDB     bool
+	run         func(c *cli, args []string) error
+}
+
+// commands lists every subcommand in the order they're shown in the help.
+var commands = []command{
+	{"notes", "export", "Write every note, decrypted, as JSON", true, (*cli).notesExport},
+	{"notes", "import", "Add notes from a JSON export", true, (*cli).notesImport},
+	{"audit", "list", "Show the audit log for every actor, newest first", true, (*cli).auditList},
+	{"migrations", "status", "Show the applied schema version and pending migrations", true, (*cli).migrationsStatus},
+	{"maintenance", "rotate-encryption-keys", "Rewrap data keys, rotate the active key and re-encrypt every note", true, (*cli).rotateEncryptionKeys},
+}
+
+// errUsage means the command line was wrong; the usage has already been printed.
+var errUsage = errors.New("usage error")
+
+func main() {
+	c := &cli{stdout: os.Stdout, stderr: os.Stderr}
+	err := c.main(os.Args[1:])
+	if c.db != nil {
+		c.db.Close()
+	}
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "moodctl:", err)
+		os.Exit(1)
+	}
+}
+
+// main parses the global flags, picks the subcommand and runs it.
+func (c *cli) main(args []string) error {
+	fs := flag.NewFlagSet("moodctl", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&c.driver, "db-driver", envOr("MOODNOTES_DB_DRIVER", "postgres"), "Storage backend: postgres or sqlite (default $MOODNOTES_DB_DRIVER)")
+	fs.StringVar(&c.dsn, "dsn", os.Getenv("MOODNOTES_DB_DSN"), "PostgreSQL DSN or SQLite database file (default $MOODNOTES_DB_DSN)")
+	fs.StringVar(&c.masterKeys, "encryption-master-keys", os.Getenv("MOODNOTES_ENCRYPTION_MASTER_KEYS"), "Comma-separated id:base64key master keys (default $MOODNOTES_ENCRYPTION_MASTER_KEYS)")
+	fs.DurationVar(&c.queryTimeout, "query-timeout", 30*time.Second, "Timeout for each database query")
+	fs.BoolVar(&c.json, "json", false, "Print results as JSON instead of text")
+	fs.Usage = func() { c.usage(fs) }
+
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if fs.NArg() < 2 {
+		c.usage(fs)
+		return errUsage
+	}
+
+	group, name := fs.Arg(0), fs.Arg(1)
+	for _, cmd := range commands {
+		if cmd.group != group || cmd.name != name {
+			continue
+		}
+		if cmd.needsDB {
+			err = c.openDB()
+			if err != nil {
+				return err
+			}
+		}
+		return cmd.run(c, fs.Args()[2:])
+	}
+	fmt.Fprintf(c.stderr, "moodctl: unknown command %q\n\n", group+" "+name)
+	c.usage(fs)
+	return errUsage
+}
+
+// usage prints the global flags and the command list.
+func (c *cli) usage(fs *flag.FlagSet) {
+	fmt.Fprintln(c.stderr, "Usage: moodctl [flags] <group> <command> [command flags] [args]")
+	fmt.Fprintln(c.stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-42s %s\n", cmd.group+" "+cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(c.stderr, "\nFlags:")
+	fs.PrintDefaults()
+}
+
+// openDB connects to the database and verifies the connection.
+func (c *cli) openDB() error {
+	if c.dsn == "" {
+		return errors.New("no database DSN: set -dsn or MOODNOTES_DB_DSN")
+	}
+	driver := "postgres"
+	switch c.driver {
+	case "postgres":
+	case "sqlite":
+		driver = data.SQLiteDriver
+	default:
+		return fmt.Errorf("unknown -db-driver %q: must be postgres or sqlite", c.driver)
+	}
+	db, err := sql.Open(driver, c.dsn)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.queryTimeout)
+	defer cancel()
+	err = db.PingContext(ctx)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("database ping failed: %w", err)
+	}
+	c.db = db
+	return nil
+}
+
+// noteModel returns the note model, with the cipher loaded when master keys are set.
+// Unlike the web app it never rotates keys on the way in.
+func (c *cli) noteModel() (*data.MoodNoteModel, error) {
+	m := &data.MoodNoteModel{DB: c.db, QueryTimeout: c.queryTimeout}
+	cipher, err := c.noteCipher()
+	if err != nil || cipher == nil {
+		return m, err
+	}
+	err = cipher.Load()
+	if err != nil {
+		return nil, err
+	}
+	m.Cipher = cipher
+	return m, nil
+}
+
+// noteCipher builds the cipher from -encryption-master-keys, or returns nil when none are set.
+func (c *cli) noteCipher() (*data.NoteCipher, error) {
+	var entries []string
+	for _, entry := range strings.Split(c.masterKeys, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	keyring, err := vault.ParseKeyring(entries)
+	if err != nil {
+		return nil, err
+	}
+	return &data.NoteCipher{DB: c.db, QueryTimeout: c.queryTimeout, Keyring: keyring}, nil
+}
+
+// auditInfo identifies moodctl and the operator's login in the audit log.
+func (c *cli) auditInfo() data.AuditInfo {
+	info := data.AuditInfo{Actor: auditActor, UserAgent: "moodctl"}
+	if user := os.Getenv("USER"); user != "" {
+		info.UserAgent = "moodctl (" + user + ")"
+	}
+	return info
+}
+
+// envOr returns the environment variable key, or fallback when it's unset.
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
+
+// --- Output ---
+
+// print writes v as indented JSON with -json, otherwise calls text to write the human form.
+func (c *cli) print(v any, text func(w io.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	text(c.stdout)
+	return nil
+}
+
+// newFlagSet returns a flag set for a subcommand's own flags.
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("moodctl "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// parse parses a subcommand's flags, mapping failures to errUsage.
+func parse(fs *flag.FlagSet, args []string) error {
+	if fs.Parse(args) != nil {
+		return errUsage // The flag package has already printed the problem and usage
+	}
+	return nil
+}
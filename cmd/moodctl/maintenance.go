@@ -0,0 +1,120 @@
+// cmd/moodctl/maintenance.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// reencryptBatchSize is how many notes are re-encrypted per query, as in the web app.
+const reencryptBatchSize = 100
+
+// maxStalledBatches is how many batches in a row may re-encrypt nothing (every
+// note in them was edited meanwhile) before the rotation gives up on the rest.
+const maxStalledBatches = 3
+
+// rotationResult is what rotate-encryption-keys reports.
+type rotationResult struct {
+	DryRun      bool          `json:"dry_run"`
+	Before      data.KeyStats `json:"before"`
+	Rewrapped   int           `json:"rewrapped"`
+	NewKeyID    int64         `json:"new_key_id,omitempty"`
+	Reencrypted int           `json:"reencrypted"`
+	Remaining   int           `json:"remaining"` // Notes still not on the new key
+}
+
+// rotateEncryptionKeys does in one go what the web app spreads over restarts:
+// rewraps every data key with the current master key, makes a new active data
+// key and moves every note onto it. Afterwards the old master key can be dropped
+// from the config. The old data keys are left for the web app to delete: until it
+// is restarted it keeps sealing new notes with the one it has cached.
+func (c *cli) rotateEncryptionKeys(args []string) error {
+	fs := c.newFlagSet("maintenance rotate-encryption-keys")
+	dryRun := fs.Bool("dry-run", false, "Show what would change without changing anything")
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cipher, err := c.noteCipher()
+	if err != nil {
+		return err
+	}
+	if cipher == nil {
+		return errors.New("no master keys: set -encryption-master-keys or MOODNOTES_ENCRYPTION_MASTER_KEYS")
+	}
+	err = cipher.Load()
+	if err != nil {
+		return err
+	}
+
+	result := rotationResult{DryRun: *dryRun}
+	result.Before, err = cipher.Stats()
+	if err != nil {
+		return err
+	}
+
+	if !*dryRun {
+		result.Rewrapped, err = cipher.RewrapDataKeys()
+		if err != nil {
+			return fmt.Errorf("rewrapping data keys: %w", err)
+		}
+		err = cipher.RotateDataKey()
+		if err != nil {
+			return fmt.Errorf("rotating the data key: %w", err)
+		}
+		result.NewKeyID = cipher.ActiveKeyID()
+
+		// A batch can re-encrypt nothing while notes remain, when the web app
+		// edited every note in it meanwhile, so count what's left rather than
+		// stopping at the first empty batch.
+		notes := &data.MoodNoteModel{DB: c.db, QueryTimeout: c.queryTimeout, Cipher: cipher}
+		stalled := 0
+		for {
+			result.Remaining, err = notesOffActiveKey(cipher)
+			if err != nil {
+				return err
+			}
+			if result.Remaining == 0 || stalled == maxStalledBatches {
+				break
+			}
+			n, err := notes.ReencryptNotes(reencryptBatchSize)
+			if err != nil {
+				return fmt.Errorf("re-encrypting notes (%d done): %w", result.Reencrypted, err)
+			}
+			result.Reencrypted += n
+			stalled++
+			if n > 0 {
+				stalled = 0
+			}
+		}
+	}
+
+	return c.print(result, func(w io.Writer) {
+		b := result.Before
+		if result.DryRun {
+			fmt.Fprintf(w, "dry run: would rewrap %d of %d data keys with master key %q,\n", b.StaleWrapped, b.DataKeys, cipher.Keyring.CurrentID())
+			fmt.Fprintf(w, "create a new active data key and re-encrypt %d notes\n", b.Notes)
+			return
+		}
+		fmt.Fprintf(w, "rewrapped %d data keys with master key %q\n", result.Rewrapped, cipher.Keyring.CurrentID())
+		fmt.Fprintf(w, "new active data key: %d\n", result.NewKeyID)
+		fmt.Fprintf(w, "re-encrypted %d of %d notes\n", result.Reencrypted, b.Notes)
+		if result.Remaining > 0 {
+			fmt.Fprintf(w, "%d notes were edited meanwhile and are still on an older data key\n", result.Remaining)
+		}
+		fmt.Fprintln(w, "restart the web app: it switches to the new data key, re-encrypts any notes left over and deletes the old keys")
+	})
+}
+
+// notesOffActiveKey counts the notes not sealed with cipher's active data key.
+func notesOffActiveKey(cipher *data.NoteCipher) (int, error) {
+	stats, err := cipher.Stats()
+	if err != nil {
+		return 0, err
+	}
+	return stats.Notes - stats.NotesOnActiveKey, nil
+}
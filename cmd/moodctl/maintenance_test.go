@@ -0,0 +1,87 @@
+// cmd/moodctl/maintenance_test.go
+
+//go:build cgo
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// keyState is which data key each note uses and which master key wraps each data key.
+func keyState(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var parts []string
+	for _, query := range []string{
+		"SELECT id || '=' || COALESCE(key_id, 0) FROM mood_notes ORDER BY id",
+		"SELECT id || '=' || master_key_id FROM data_keys ORDER BY id",
+	} {
+		rows, err := db.Query(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var s string
+			err := rows.Scan(&s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts = append(parts, s)
+		}
+		rows.Close()
+		parts = append(parts, "|")
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestRotateEncryptionKeys(t *testing.T) {
+	oldKey, newKey := testMasterKey("old", 1), testMasterKey("new", 2)
+	db, path := newTestDB(t, oldKey)
+	insertTestNotes(t, testNoteModel(t, db, oldKey), 250) // More than two batches
+	both := newKey + "," + oldKey                         // The new key first, so it wraps
+
+	before := keyState(t, db)
+	out, err := runCLI(t, path, both, "maintenance", "rotate-encryption-keys", "-dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, `dry run: would rewrap 1 of 1 data keys with master key "new"`) || !strings.Contains(out, "re-encrypt 250 notes") {
+		t.Errorf("dry run output = %q", out)
+	}
+	if after := keyState(t, db); after != before {
+		t.Errorf("dry run changed keys:\nbefore %s\nafter  %s", before, after)
+	}
+
+	out, err = runCLI(t, path, both, "-json", "maintenance", "rotate-encryption-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result rotationResult
+	err = json.Unmarshal([]byte(out), &result)
+	if err != nil {
+		t.Fatalf("output %q: %v", out, err)
+	}
+	if result.Rewrapped != 1 || result.Reencrypted != 250 || result.Remaining != 0 || result.NewKeyID == 0 {
+		t.Errorf("result = %+v", result)
+	}
+	var offKey int
+	err = db.QueryRow("SELECT COUNT(*) FROM mood_notes WHERE key_id IS DISTINCT FROM $1", result.NewKeyID).Scan(&offKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offKey != 0 {
+		t.Errorf("%d notes not on the new data key", offKey)
+	}
+
+	// Every data key is now wrapped by the new master key, so the old one can go.
+	notes, err := testNoteModel(t, db, newKey).GetAll()
+	if err != nil {
+		t.Fatalf("reading notes with only the new master key: %v", err)
+	}
+	if len(notes) != 250 || notes[0].Content != "Wrote something ✍️" {
+		t.Errorf("read %d notes, first %+v", len(notes), notes[0])
+	}
+}
@@ -0,0 +1,103 @@
+// cmd/moodctl/migrations.go
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mickali02/mood-notes-app/migrations"
+)
+
+// migrationStatus is what migrations status reports.
+type migrationStatus struct {
+	Version    int64       `json:"version"` // 0 when no migration has been applied
+	Dirty      bool        `json:"dirty"`   // A migration failed part-way and needs fixing by hand
+	Migrations []migration `json:"migrations"`
+	Pending    int         `json:"pending"`
+}
+
+// migration is one numbered up migration in the migrations directory.
+type migration struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+// migrationsStatus compares the schema_migrations table with the migration files:
+// for PostgreSQL the ones in -dir that the migrate tool (make db/migrations/up)
+// applies, for SQLite the ones built into the app, which applies them at startup.
+func (c *cli) migrationsStatus(args []string) error {
+	flags := c.newFlagSet("migrations status")
+	dir := flags.String("dir", "./migrations", "Directory holding the NNNNNN_name.up.sql files (PostgreSQL only)")
+	err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	files := os.DirFS(*dir)
+	if c.driver == "sqlite" {
+		files = migrations.SQLite()
+	}
+
+	status := migrationStatus{}
+	err = c.db.QueryRow(`SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&status.Version, &status.Dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("reading schema_migrations (have the migrations ever run?): %w", err)
+	}
+
+	status.Migrations, err = readMigrations(files)
+	if err != nil {
+		return err
+	}
+	for i, m := range status.Migrations {
+		status.Migrations[i].Applied = m.Version <= status.Version
+		if !status.Migrations[i].Applied {
+			status.Pending++
+		}
+	}
+
+	return c.print(status, func(w io.Writer) {
+		state := "clean"
+		if status.Dirty {
+			state = "DIRTY: the last migration failed part-way; fix it by hand, then migrate force"
+		}
+		fmt.Fprintf(w, "schema version %d (%s)\n\n", status.Version, state)
+		for _, m := range status.Migrations {
+			mark := "pending"
+			if m.Applied {
+				mark = "applied"
+			}
+			fmt.Fprintf(w, "  %06d  %-8s %s\n", m.Version, mark, m.Name)
+		}
+		fmt.Fprintf(w, "\n%d pending\n", status.Pending)
+	})
+}
+
+// readMigrations lists the up migrations in files, in version order.
+func readMigrations(files fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+	var list []migration
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".up.sql")
+		if !ok || e.IsDir() {
+			continue
+		}
+		num, label, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected migration file %s", e.Name())
+		}
+		list = append(list, migration{Version: version, Name: label})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
@@ -0,0 +1,184 @@
+// cmd/moodctl/notes.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+	"github.com/mickali02/mood-notes-app/internal/validator"
+)
+
+// notesExport writes every note as a JSON array, the same format as notes.json in
+// the web app's data export. Notes are the single owner's; there are no per-user exports yet.
+func (c *cli) notesExport(args []string) error {
+	fs := c.newFlagSet("notes export")
+	out := fs.String("o", "-", "File to write, or - for standard output")
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	notes, err := c.mustNoteModel()
+	if err != nil {
+		return err
+	}
+	all, err := notes.GetAll()
+	if err != nil {
+		return err
+	}
+	if all == nil {
+		all = []*data.MoodNote{} // Write [] rather than null
+	}
+
+	w := c.stdout
+	if *out != "-" {
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) // Decrypted notes: owner-only, never overwrite
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(all)
+	if err != nil {
+		return err
+	}
+	if *out != "-" {
+		fmt.Fprintf(c.stderr, "exported %d notes to %s\n", len(all), *out)
+	}
+	return nil
+}
+
+// importResult is what notes import reports.
+type importResult struct {
+	DryRun   bool           `json:"dry_run"`
+	Read     int            `json:"read"`
+	Imported int            `json:"imported"`
+	Invalid  map[int]string `json:"invalid,omitempty"` // Position in the file (from 1) => first problem
+	IDs      []int64        `json:"ids,omitempty"`     // IDs of the new notes
+}
+
+// notesImport adds the notes in a JSON export as new notes. Every note is
+// validated first and nothing is written if any is invalid; the inserts share one
+// transaction, so nothing is written if any fails either. IDs, versions and
+// timestamps other than occurred_at are assigned afresh.
+func (c *cli) notesImport(args []string) error {
+	fs := c.newFlagSet("notes import")
+	dryRun := fs.Bool("dry-run", false, "Validate and count the notes without importing them")
+	titleMax := fs.Int("title-max-length", 150, "Max characters allowed in a title (match the web app's limits.title_max_length)")
+	contentMax := fs.Int("content-max-length", 5000, "Max characters allowed in content (match the web app's limits.content_max_length)")
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "Usage: moodctl notes import [-dry-run] <file.json|->")
+		return errUsage
+	}
+
+	var r io.Reader = os.Stdin
+	if fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	var incoming []*data.MoodNote
+	err = json.NewDecoder(r).Decode(&incoming)
+	if err != nil {
+		return fmt.Errorf("reading notes: %w", err)
+	}
+
+	result := importResult{DryRun: *dryRun, Read: len(incoming), Invalid: map[int]string{}}
+	limits := data.MoodNoteLimits{TitleMaxLength: *titleMax, ContentMaxLength: *contentMax}
+	for i, n := range incoming {
+		v := validator.NewValidator()
+		data.ValidateMoodNote(v, n, limits)
+		for field, msg := range v.Errors {
+			result.Invalid[i+1] = field + " " + msg
+			break
+		}
+	}
+
+	if len(result.Invalid) == 0 && !*dryRun {
+		notes, err := c.mustNoteModel()
+		if err != nil {
+			return err
+		}
+		// One transaction, so a failure part-way leaves the journal as it was.
+		models := data.NewModels(c.db, c.queryTimeout, notes.Cipher)
+		err = models.WithTx(context.Background(), func(tx data.Models) error {
+			result.Imported, result.IDs = 0, nil // WithTx may run this more than once
+			for _, n := range incoming {
+				note := &data.MoodNote{Title: n.Title, Content: n.Content, OccurredAt: n.OccurredAt}
+				err := tx.MoodNotes.Insert(note, c.auditInfo())
+				if err != nil {
+					return fmt.Errorf("importing note %d of %d (nothing was imported): %w", result.Imported+1, result.Read, err)
+				}
+				result.Imported++
+				result.IDs = append(result.IDs, note.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.print(result, func(w io.Writer) {
+		positions := slices.Sorted(maps.Keys(result.Invalid))
+		for _, pos := range positions {
+			fmt.Fprintf(w, "note %d: %s\n", pos, result.Invalid[pos])
+		}
+		switch {
+		case len(result.Invalid) > 0:
+			fmt.Fprintf(w, "%d of %d notes are invalid; nothing was imported\n", len(result.Invalid), result.Read)
+		case *dryRun:
+			fmt.Fprintf(w, "dry run: %d notes would be imported\n", result.Read)
+		default:
+			fmt.Fprintf(w, "imported %d notes\n", result.Imported)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Invalid) > 0 {
+		return fmt.Errorf("%d invalid notes", len(result.Invalid))
+	}
+	return nil
+}
+
+// mustNoteModel is noteModel for commands that read or write note text. Without
+// master keys, encrypted notes can't be read and new notes would be stored in
+// plaintext, so it refuses when the database holds any data keys. With master keys,
+// it needs the active data key the web app creates on its first encrypted start.
+func (c *cli) mustNoteModel() (*data.MoodNoteModel, error) {
+	notes, err := c.noteModel()
+	if err != nil {
+		return nil, err
+	}
+	if notes.Cipher != nil && notes.Cipher.ActiveKeyID() == 0 {
+		return nil, fmt.Errorf("no active data key yet: start the web app once with these master keys")
+	}
+	if notes.Cipher == nil {
+		var keys int
+		err := c.db.QueryRow(`SELECT COUNT(*) FROM data_keys`).Scan(&keys)
+		if err != nil {
+			return nil, err
+		}
+		if keys > 0 {
+			return nil, fmt.Errorf("notes are encrypted: set -encryption-master-keys or MOODNOTES_ENCRYPTION_MASTER_KEYS")
+		}
+	}
+	return notes, nil
+}
@@ -0,0 +1,135 @@
+// cmd/moodctl/notes_test.go
+
+//go:build cgo
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// TestNotesExportImport exports an encrypted journal and imports it into an
+// empty one with different master keys, and checks the notes come out the same.
+func TestNotesExportImport(t *testing.T) {
+	fromKey, toKey := testMasterKey("from", 1), testMasterKey("to", 2)
+	fromDB, fromPath := newTestDB(t, fromKey)
+	insertTestNotes(t, testNoteModel(t, fromDB, fromKey), 3)
+
+	exported := filepath.Join(t.TempDir(), "notes.json")
+	_, err := runCLI(t, fromPath, fromKey, "notes", "export", "-o", exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("export file mode = %v; want 0600", perm)
+	}
+	_, err = runCLI(t, fromPath, fromKey, "notes", "export", "-o", exported)
+	if err == nil {
+		t.Error("second export overwrote the first")
+	}
+
+	toDB, toPath := newTestDB(t, toKey)
+	out, err := runCLI(t, toPath, toKey, "-json", "notes", "import", exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result importResult
+	err = json.Unmarshal([]byte(out), &result)
+	if err != nil {
+		t.Fatalf("import output %q: %v", out, err)
+	}
+	if result.Read != 3 || result.Imported != 3 || len(result.IDs) != 3 || result.DryRun {
+		t.Errorf("import result = %+v", result)
+	}
+
+	want, err := testNoteModel(t, fromDB, fromKey).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := testNoteModel(t, toDB, toKey).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("imported %d notes; want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Title != want[i].Title || got[i].Content != want[i].Content || !got[i].OccurredAt.Equal(want[i].OccurredAt) {
+			t.Errorf("note %d = %q, %q, %v; want %q, %q, %v", i, got[i].Title, got[i].Content, got[i].OccurredAt,
+				want[i].Title, want[i].Content, want[i].OccurredAt)
+		}
+	}
+
+	// The imported notes are sealed, and the audit log says who added them.
+	var stored string
+	err = toDB.QueryRow("SELECT content FROM mood_notes LIMIT 1").Scan(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, "Wrote something") {
+		t.Errorf("imported note stored in plaintext: %q", stored)
+	}
+	events, _, err := (&data.AuditModel{DB: toDB}).GetPage(data.AuditFilter{Actor: auditActor}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[0].Action != data.AuditNoteCreate {
+		t.Errorf("audit events for the import = %d (first %+v); want 3 %s", len(events), events, data.AuditNoteCreate)
+	}
+}
+
+func TestNotesImportWritesNothing(t *testing.T) {
+	srcDB, srcPath := newTestDB(t)
+	insertTestNotes(t, testNoteModel(t, srcDB), 2)
+	exported := filepath.Join(t.TempDir(), "notes.json")
+	_, err := runCLI(t, srcPath, "", "notes", "export", "-o", exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		db, path := newTestDB(t)
+		out, err := runCLI(t, path, "", "notes", "import", "-dry-run", exported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != "dry run: 2 notes would be imported\n" {
+			t.Errorf("output = %q", out)
+		}
+		if n := countRows(t, db, "mood_notes") + countRows(t, db, "audit_events"); n != 0 {
+			t.Errorf("dry run wrote %d rows", n)
+		}
+	})
+
+	t.Run("invalid note", func(t *testing.T) {
+		invalid := filepath.Join(t.TempDir(), "invalid.json")
+		err := os.WriteFile(invalid, []byte(`[
+			{"title": "Fine", "content": "Body", "occurred_at": "2026-03-01T09:30:00Z"},
+			{"title": "", "content": "No title", "occurred_at": "2026-03-02T09:30:00Z"}
+		]`), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, path := newTestDB(t)
+		out, err := runCLI(t, path, "", "notes", "import", invalid)
+		if err == nil || err.Error() != "1 invalid notes" {
+			t.Errorf("error = %v; want 1 invalid notes", err)
+		}
+		if !strings.Contains(out, "note 2: title must be provided") {
+			t.Errorf("output = %q; want the invalid note named", out)
+		}
+		if n := countRows(t, db, "mood_notes"); n != 0 {
+			t.Errorf("import with an invalid note wrote %d notes", n)
+		}
+	})
+}
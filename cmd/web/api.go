@@ -0,0 +1,437 @@
+// cmd/web/api.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+	"github.com/mickali02/mood-notes-app/internal/validator"
+)
+
+// The JSON API under /v1 serves scripts and apps, which authenticate with a
+// personal API token, and the service worker (ui/static/js/sw.js), which caches
+// recent notes for offline reading and syncs entries written offline. The app's
+// own pages and worker use the browser's session instead (see session.go).
+//
+// While the HTML pages have no login, anyone who can load them can start a
+// session, so tokens and their scopes limit what a script is given, not who can
+// reach the notes.
+
+// envelope wraps every JSON response in a named top-level object, e.g. {"note": {...}}.
+type envelope map[string]any
+
+// writeJSON sends data as an indented JSON response. API responses are never cached.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+	js = append(js, '\n')
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(js)
+	return nil
+}
+
+// apiError sends {"error": message}, where message is a string or a map of field errors.
+func (app *application) apiError(w http.ResponseWriter, r *http.Request, status int, message any) {
+	err := app.writeJSON(w, status, envelope{"error": message})
+	if err != nil {
+		app.logger.Error("writing JSON error response", "request_id", requestID(r), "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// apiServerError logs err and sends a generic 500 response.
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Error("internal server error", "method", r.Method, "uri", r.URL.RequestURI(), "request_id", requestID(r), "error", err)
+	app.apiError(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+}
+
+// apiMaxBodySize comfortably fits a note at the largest configurable content length.
+const apiMaxBodySize = 1 << 20
+
+// readJSON decodes a single JSON object from the request body into dst, rejecting
+// other content types, unknown fields and trailing data.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return errors.New("body must be sent as application/json")
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, apiMaxBodySize)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &typeErr):
+			return fmt.Errorf("body has the wrong type for field %q", typeErr.Field)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case errors.As(err, &maxBytesErr):
+			return fmt.Errorf("body must not be larger than %d bytes", apiMaxBodySize)
+		default:
+			return err // Including unknown fields, whose message names the field
+		}
+	}
+	if dec.More() {
+		return errors.New("body must only contain a single JSON object")
+	}
+	return nil
+}
+
+// --- Authentication ---
+
+// apiTokenUseInterval limits how often a token's last-used time is written, so
+// a busy script doesn't turn every read into a write.
+const apiTokenUseInterval = time.Minute
+
+// tokenRejectInterval is how often one client's rejected API tokens go in the
+// audit log. A script retrying with a revoked token would otherwise add a row per
+// request, and audit rows are kept for good; every rejection is still logged.
+const tokenRejectInterval = time.Minute
+
+// newTokenRejectLimiter returns the limiter recordTokenReject samples with: one
+// event per client (see clientKey) per tokenRejectInterval.
+func newTokenRejectLimiter() *rateLimiter {
+	return newRateLimiter(1/tokenRejectInterval.Seconds(), 1)
+}
+
+// recordTokenReject logs a rejected API token and adds it to the audit log,
+// unless the same client already had one added in the last tokenRejectInterval.
+func (app *application) recordTokenReject(r *http.Request, info data.AuditInfo, tokenID int64) {
+	app.logger.Warn("API token rejected", "ip", app.clientIP(r), "token_id", tokenID, "request_id", requestID(r))
+	if ok, _, _ := app.tokenRejects.allow(app.clientKey(r), time.Now()); ok {
+		app.recordAuthEvent(info, data.AuditTokenReject, tokenID)
+	}
+}
+
+// requireScope lets through requests carrying an "Authorization: Bearer" API token
+// that grants scope, and answers the rest with 401 (no token, or an unknown or
+// expired one) or 403 (a token without the scope), with a WWW-Authenticate
+// header as RFC 6750 describes. Routes using it sit behind a rate limiter, which
+// also slows down guessing.
+//
+// Requests from the app's own pages and service worker carry the browser's
+// session cookie and CSRF token instead of a token: there are no accounts, so
+// they may do anything the HTML forms can.
+func (app *application) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		if r.Header.Get("Authorization") == "" && app.validBrowserSession(r) {
+			next(w, r)
+			return
+		}
+		scheme, secret, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || secret == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="feelflow"`)
+			app.apiError(w, r, http.StatusUnauthorized, "an API token is required")
+			return
+		}
+		token, err := app.apiTokens.GetByToken(strings.TrimSpace(secret))
+		if err != nil {
+			if err.Error() != "API token not found" {
+				app.apiServerError(w, r, err)
+				return
+			}
+			client := app.auditInfo(r)
+			client.Actor = apiClientActor
+			app.recordTokenReject(r, client, 0)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="feelflow", error="invalid_token"`)
+			app.apiError(w, r, http.StatusUnauthorized, "invalid or expired API token")
+			return
+		}
+		if !token.HasScope(scope) {
+			app.recordTokenReject(r, app.auditInfo(withAPIToken(r, token)), token.ID)
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="feelflow", error="insufficient_scope", scope=%q`, scope))
+			app.apiError(w, r, http.StatusForbidden, fmt.Sprintf("this API token lacks the %s scope", scope))
+			return
+		}
+
+		if time.Since(token.LastUsedAt) > apiTokenUseInterval {
+			err = app.apiTokens.RecordUse(token.ID)
+			if err != nil {
+				app.logger.Error("recording API token use", "token_id", token.ID, "request_id", requestID(r), "error", err)
+			}
+		}
+		next(w, withAPIToken(r, token))
+	}
+}
+
+// --- Notes API ---
+
+// apiNoteInput is the body of a create or update request.
+type apiNoteInput struct {
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	OccurredAt time.Time `json:"occurred_at"`
+	ClientID   string    `json:"client_id"` // Create only: the offline queue's ID for the entry
+	Version    int       `json:"version"`   // Update only: the version the edit was based on
+}
+
+// clientIDRX matches the IDs the service worker generates (crypto.randomUUID).
+var clientIDRX = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+// apiNoteListDefault and apiNoteListMax bound GET /v1/notes?limit=.
+const (
+	apiNoteListDefault = 20
+	apiNoteListMax     = 100
+)
+
+// apiListNotes returns the most recent entries, newest first.
+func (app *application) apiListNotes(w http.ResponseWriter, r *http.Request) {
+	limit := apiNoteListDefault
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > apiNoteListMax {
+			app.apiError(w, r, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", apiNoteListMax))
+			return
+		}
+		limit = n
+	}
+	notes, err := app.moodNotes.GetRecent(limit)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	if notes == nil {
+		notes = []*data.MoodNote{} // [] rather than null
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"notes": notes})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// apiNoteID reads the {id} path value, sending a 404 (and returning 0) if it isn't valid.
+func (app *application) apiNoteID(w http.ResponseWriter, r *http.Request) int64 {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.apiError(w, r, http.StatusNotFound, "mood note not found")
+		return 0
+	}
+	return id
+}
+
+// apiShowNote returns one entry.
+func (app *application) apiShowNote(w http.ResponseWriter, r *http.Request) {
+	id := app.apiNoteID(w, r)
+	if id == 0 {
+		return
+	}
+	note, err := app.moodNotes.Get(id)
+	if err != nil {
+		if err.Error() == "mood note record not found" {
+			app.apiError(w, r, http.StatusNotFound, "mood note not found")
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"note": note})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// apiCreateNote saves an entry, usually one written offline. Sending the same
+// client_id again returns the note it created the first time (200 instead of 201),
+// so the offline queue can safely retry after a lost response.
+func (app *application) apiCreateNote(w http.ResponseWriter, r *http.Request) {
+	var input apiNoteInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	note := &data.MoodNote{Title: input.Title, Content: input.Content, OccurredAt: input.OccurredAt, ClientID: input.ClientID}
+	v := validator.NewValidator()
+	v.Check(input.ClientID == "" || clientIDRX.MatchString(input.ClientID), "client_id", "must be up to 64 letters, digits and dashes")
+	v.Check(input.Version == 0, "version", "must not be set when creating a note")
+	data.ValidateMoodNote(v, note, app.noteLimits())
+	if !v.ValidData() {
+		app.apiError(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	status := http.StatusCreated
+	err = app.moodNotes.Insert(note, app.auditInfo(r))
+	if err != nil {
+		if err.Error() != "duplicate mood note client ID" {
+			app.apiServerError(w, r, err)
+			return
+		}
+		note, err = app.moodNotes.GetByClientID(input.ClientID)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		status = http.StatusOK
+	} else {
+		app.logger.Info("mood note created through the API", "note_id", note.ID, "client_id", input.ClientID)
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", r.URL.Path, note.ID))
+	err = app.writeJSON(w, status, envelope{"note": note})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// apiUpdateNote saves an edit if the note is still at input.version. Otherwise it
+// responds 409 with the current note, and the client decides what to do with its
+// copy (the service worker keeps it as a draft; see sw.js).
+func (app *application) apiUpdateNote(w http.ResponseWriter, r *http.Request) {
+	id := app.apiNoteID(w, r)
+	if id == 0 {
+		return
+	}
+	var input apiNoteInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	note := &data.MoodNote{ID: id, Title: input.Title, Content: input.Content, OccurredAt: input.OccurredAt, Version: input.Version}
+	v := validator.NewValidator()
+	v.Check(input.Version > 0, "version", "must be provided")
+	v.Check(input.ClientID == "", "client_id", "must only be set when creating a note")
+	data.ValidateMoodNote(v, note, app.noteLimits())
+	if !v.ValidData() {
+		app.apiError(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	// Save the note and drop its draft together, as the edit form does.
+	err = app.models.WithTx(r.Context(), func(tx data.Models) error {
+		err := tx.MoodNotes.Update(note, app.auditInfo(r))
+		if err != nil {
+			return err
+		}
+		return tx.Drafts.Discard(auditActor, data.DraftSlot(id))
+	})
+	if err != nil {
+		if err.Error() != "mood note record not found or version mismatch" {
+			app.apiServerError(w, r, err)
+			return
+		}
+		current, getErr := app.moodNotes.Get(id)
+		switch {
+		case getErr != nil && getErr.Error() == "mood note record not found":
+			app.apiError(w, r, http.StatusNotFound, "mood note not found")
+		case getErr != nil:
+			app.apiServerError(w, r, getErr)
+		default:
+			err = app.writeJSON(w, http.StatusConflict, envelope{"error": "edit conflict", "note": current})
+			if err != nil {
+				app.apiServerError(w, r, err)
+			}
+		}
+		return
+	}
+
+	// Update only returns the new version, so read back the whole note.
+	note, err = app.moodNotes.Get(id)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"note": note})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// --- Export API ---
+
+// apiRequestExport queues a data export, like the button on /settings/data. The
+// client polls the Location it gets back until the export is ready.
+func (app *application) apiRequestExport(w http.ResponseWriter, r *http.Request) {
+	export, err := app.dataExports.Insert(app.auditInfo(r))
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.logger.Info("data export requested through the API", "export_id", export.ID)
+
+	w.Header().Set("Location", fmt.Sprintf("/v1/exports/%d", export.ID))
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"export": export})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// apiExportID reads the {id} path value, sending a 404 (and returning 0) if it isn't valid.
+func (app *application) apiExportID(w http.ResponseWriter, r *http.Request) int64 {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.apiError(w, r, http.StatusNotFound, "data export not found")
+		return 0
+	}
+	return id
+}
+
+// apiShowExport returns an export's status, with the archive's URL once it is ready.
+func (app *application) apiShowExport(w http.ResponseWriter, r *http.Request) {
+	id := app.apiExportID(w, r)
+	if id == 0 {
+		return
+	}
+	export, err := app.dataExports.Get(id)
+	if err != nil {
+		if err.Error() == "data export not found" {
+			app.apiError(w, r, http.StatusNotFound, "data export not found")
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	env := envelope{"export": export}
+	if export.Ready() {
+		env["archive_url"] = fmt.Sprintf("/v1/exports/%d/archive", export.ID)
+	}
+	err = app.writeJSON(w, http.StatusOK, env)
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+// apiDownloadExport sends a ready export's ZIP archive.
+func (app *application) apiDownloadExport(w http.ResponseWriter, r *http.Request) {
+	id := app.apiExportID(w, r)
+	if id == 0 {
+		return
+	}
+	export, err := app.dataExports.GetReady(id)
+	if err != nil {
+		if err.Error() == "data export not found or expired" {
+			app.apiError(w, r, http.StatusNotFound, "data export not found, not ready yet or expired")
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	err = app.serveDataExport(w, r, export)
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
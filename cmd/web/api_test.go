@@ -0,0 +1,157 @@
+// cmd/web/api_test.go
+
+//go:build cgo
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+func TestRequireScope(t *testing.T) {
+	app := newEmailTestApp(t, newFakeSMTP(t))
+	app.apiTokens = app.models.APITokens
+	app.auditEvents = app.models.AuditEvents
+	app.tokenRejects = newTokenRejectLimiter()
+
+	reader := &data.APIToken{Name: "Reader", Scopes: []string{data.ScopeNotesRead}}
+	err := app.apiTokens.Insert(reader, data.AuditInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := &data.APIToken{Name: "Old", Scopes: []string{data.ScopeNotesRead}, ExpiresAt: time.Now().Add(-time.Minute)}
+	err = app.apiTokens.Insert(expired, data.AuditInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := app.requireScope(data.ScopeNotesRead, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	writeHandler := app.requireScope(data.ScopeNotesWrite, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called for a token without its scope")
+	})
+
+	bearer := func(token string) map[string]string { return map[string]string{"Authorization": "Bearer " + token} }
+	session := func(id, csrf string) map[string]string {
+		return map[string]string{"Cookie": sessionCookie + "=" + id, csrfHeader: csrf}
+	}
+	sessionID := strings.Repeat("A", sessionIDLength)
+	csrf := app.sign("csrf", sessionID)
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		header        map[string]string
+		wantStatus    int
+		wantChallenge string // Substring of WWW-Authenticate
+	}{
+		{"no header", handler, nil, http.StatusUnauthorized, `Bearer realm="feelflow"`},
+		{"basic auth", handler, map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, http.StatusUnauthorized, `Bearer realm="feelflow"`},
+		{"unknown token", handler, bearer("ffp_" + strings.Repeat("x", 43)), http.StatusUnauthorized, `error="invalid_token"`},
+		{"malformed token", handler, bearer("nope"), http.StatusUnauthorized, `error="invalid_token"`},
+		{"expired token", handler, bearer(expired.Token), http.StatusUnauthorized, `error="invalid_token"`},
+		{"missing scope", writeHandler, bearer(reader.Token), http.StatusForbidden, `scope="notes:write"`},
+		{"valid token", handler, bearer(reader.Token), http.StatusNoContent, ""},
+		{"lowercase scheme", handler, map[string]string{"Authorization": "bearer " + reader.Token}, http.StatusNoContent, ""},
+
+		// The app's own pages and service worker use the browser's session instead.
+		{"browser session", handler, session(sessionID, csrf), http.StatusNoContent, ""},
+		{"session without CSRF token", handler, session(sessionID, ""), http.StatusUnauthorized, `Bearer realm="feelflow"`},
+		{"CSRF token of another session", handler, session(strings.Repeat("B", sessionIDLength), csrf), http.StatusUnauthorized, `Bearer realm="feelflow"`},
+		{"CSRF token without a session", handler, map[string]string{csrfHeader: csrf}, http.StatusUnauthorized, `Bearer realm="feelflow"`},
+		{"same-origin headers alone", handler, map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://example.com"}, http.StatusUnauthorized, `Bearer realm="feelflow"`},
+		{"session with a bad token", handler, map[string]string{"Cookie": sessionCookie + "=" + sessionID, csrfHeader: csrf, "Authorization": "Bearer nope"}, http.StatusUnauthorized, `error="invalid_token"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/notes", nil) // Host example.com
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			tt.handler(rr, r)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d; want %d", rr.Code, tt.wantStatus)
+			}
+			challenge := rr.Header().Get("WWW-Authenticate")
+			if tt.wantChallenge == "" && challenge != "" {
+				t.Errorf("WWW-Authenticate = %q; want none", challenge)
+			}
+			if !strings.Contains(challenge, tt.wantChallenge) {
+				t.Errorf("WWW-Authenticate = %q; want it to contain %q", challenge, tt.wantChallenge)
+			}
+		})
+	}
+
+	// Rejected tokens are in the audit log, but only one a minute per client: the
+	// first, unknown token stands for the malformed, expired and under-scoped ones
+	// that followed from the same address.
+	rejects, _, err := app.auditEvents.GetPage(data.AuditFilter{Action: data.AuditTokenReject}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejects) != 1 || rejects[0].TargetID != 0 || rejects[0].Actor != apiClientActor {
+		t.Errorf("token.reject events = %+v; want just the first", rejects)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/v1/notes", nil)
+	r.RemoteAddr = "198.51.100.7:1234"
+	r.Header.Set("Authorization", "Bearer "+reader.Token)
+	writeHandler(httptest.NewRecorder(), r)
+	rejects, _, err = app.auditEvents.GetPage(data.AuditFilter{Action: data.AuditTokenReject}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejects) != 2 || rejects[0].TargetID != reader.ID || rejects[0].IP != "198.51.100.7" {
+		t.Errorf("token.reject events = %+v; want one more from the other client", rejects)
+	}
+
+	// Only the token that was used has a last-used time.
+	tokens, err := app.apiTokens.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range tokens {
+		if used := !tok.LastUsedAt.IsZero(); used != (tok.ID == reader.ID) {
+			t.Errorf("token %q has last-used time %v", tok.Name, tok.LastUsedAt)
+		}
+	}
+}
+
+func TestBrowserSession(t *testing.T) {
+	app := newEmailTestApp(t, newFakeSMTP(t))
+
+	// A first visit starts a session; the token only works with its cookie.
+	rr := httptest.NewRecorder()
+	token, err := app.browserSession(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("cookies = %+v; want one HttpOnly, SameSite=Strict session cookie", cookies)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/notes", nil)
+	r.AddCookie(cookies[0])
+	r.Header.Set(csrfHeader, token)
+	if !app.validBrowserSession(r) {
+		t.Error("session with its own CSRF token not accepted")
+	}
+
+	// Later visits keep the session and get the same token.
+	rr = httptest.NewRecorder()
+	again, err := app.browserSession(rr, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != token || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("second visit got token %q and cookies %v; want the same token and no new cookie", again, rr.Result().Cookies())
+	}
+}
@@ -0,0 +1,115 @@
+// cmd/web/assets.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mickali02/mood-notes-app/ui"
+)
+
+// Static files are served with a ?v=<hash of the file> query (see asset), so
+// browsers and the service worker can cache them forever: a changed file gets
+// a new URL. The hashes are worked out once, from the embedded files.
+
+// assetVersions maps each /static/ path to the first 12 hex digits of its SHA-256.
+var assetVersions = sync.OnceValue(func() map[string]string {
+	versions := map[string]string{}
+	err := fs.WalkDir(ui.Files, "static", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(ui.Files, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		versions["/"+path] = hex.EncodeToString(sum[:])[:12]
+		return nil
+	})
+	if err != nil {
+		panic(err) // The files are embedded, so this can only be a build problem
+	}
+	return versions
+})
+
+// asset returns the versioned URL of a static file, e.g.
+// {{asset "/static/styles.css"}} -> "/static/styles.css?v=3f2a9c01b7de".
+// Unknown paths are returned unchanged.
+func asset(path string) string {
+	if v, ok := assetVersions()[path]; ok {
+		return path + "?v=" + v
+	}
+	return path
+}
+
+// shellAssets are the static files every page needs, precached by the service
+// worker so the app shell loads offline. The service worker file itself is not one.
+var shellAssets = []string{
+	"/static/styles.css",
+	"/static/js/main.js",
+	"/static/manifest.json",
+	"/static/icons/icon.svg",
+	"/static/icons/icon-192.png",
+	"/static/icons/icon-512.png",
+}
+
+// cacheStatic marks versioned static files as immutable. Unversioned requests
+// keep the default headers, so an old bookmark never pins a stale file.
+func (app *application) cacheStatic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.URL.Query().Get("v"); v != "" && v == assetVersions()[r.URL.Path] {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serviceWorker serves ui/static/js/sw.js at /sw.js, so that it may control the
+// whole site rather than just /static/. The placeholders in the file are filled
+// in with the versioned shell assets and a version covering all static files:
+// any change to them changes the worker's bytes, which makes browsers install
+// the new worker and drop the old caches.
+func (app *application) serviceWorker(w http.ResponseWriter, r *http.Request) {
+	src, err := fs.ReadFile(ui.Files, "static/js/sw.js")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	versions := assetVersions()
+	paths := make([]string, 0, len(versions))
+	for path := range versions {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		h.Write([]byte(path + "=" + versions[path] + "\n"))
+	}
+
+	urls := make([]string, len(shellAssets))
+	for i, path := range shellAssets {
+		urls[i] = asset(path)
+	}
+	shell, err := json.Marshal(urls)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	js := strings.NewReplacer(
+		`"__VERSION__"`, `"`+hex.EncodeToString(h.Sum(nil))[:12]+`"`,
+		`["__SHELL_ASSETS__"]`, string(shell),
+	).Replace(string(src))
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache") // Browsers also revalidate workers at least daily
+	w.Write([]byte(js))
+}
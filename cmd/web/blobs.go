@@ -0,0 +1,95 @@
+// cmd/web/blobs.go
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/mickali02/mood-notes-app/internal/blobstore"
+)
+
+// Blobs hold note data too (attachments, export archives), so they are sealed
+// with the note cipher's active data key, like note titles and content. With
+// encryption off they are stored as they are, like notes.
+
+// putSealedBlob stores what write writes under key, sealed with the active data
+// key, and returns that key's ID (0 when encryption is off). Nothing is held in
+// memory: write's output is encrypted and streamed into the blob store as it comes.
+func (app *application) putSealedBlob(ctx context.Context, key string, write func(w io.Writer) error) (int64, error) {
+	pr, pw := io.Pipe()
+
+	var (
+		sealer io.WriteCloser
+		keyID  int64
+		w      io.Writer = pw
+	)
+	if app.moodNotes.Cipher != nil {
+		var err error
+		sealer, keyID, err = app.moodNotes.Cipher.SealStream(pw, key)
+		if err != nil {
+			return 0, err
+		}
+		w = sealer
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		err := write(w)
+		if err == nil && sealer != nil {
+			err = sealer.Close()
+		}
+		pw.CloseWithError(err) // A nil error ends the blob; any other fails Put
+		written <- err
+	}()
+
+	_, err := app.blobs.Put(ctx, key, pr)
+	pr.CloseWithError(err) // Stops write early if Put gave up
+	if writeErr := <-written; err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return keyID, nil
+}
+
+// writeBytes returns a putSealedBlob write function for a blob already in memory.
+func writeBytes(b []byte) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	}
+}
+
+// openSealedBlob opens a blob stored by putSealedBlob with data key keyID (0 for
+// a blob stored without encryption). The result reads and seeks in plaintext.
+func (app *application) openSealedBlob(ctx context.Context, key string, keyID int64) (io.ReadSeekCloser, error) {
+	blob, err := app.blobs.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if keyID == 0 {
+		return blob, nil
+	}
+	if app.moodNotes.Cipher == nil {
+		blob.Close()
+		return nil, errors.New("blob is encrypted but no master key is configured")
+	}
+	plain, err := app.moodNotes.Cipher.OpenStream(blob, blob.Size(), key, keyID)
+	if err != nil {
+		blob.Close()
+		return nil, err
+	}
+	return sealedBlob{plain, blob}, nil
+}
+
+// sealedBlob reads a decrypted stream and closes the blob under it.
+type sealedBlob struct {
+	io.ReadSeeker
+	blob blobstore.Blob
+}
+
+func (b sealedBlob) Close() error {
+	return b.blob.Close()
+}
@@ -0,0 +1,549 @@
+// cmd/web/config.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mickali02/mood-notes-app/internal/validator"
+	"github.com/mickali02/mood-notes-app/internal/vault"
+)
+
+// config holds every tunable setting for the web application.
+// Values are layered in this order (later sources win):
+//  1. built-in defaults (defaultConfig)
+//  2. the TOML config file given by -config or MOODNOTES_CONFIG
+//  3. MOODNOTES_* environment variables
+//  4. command-line flags
+type config struct {
+	Addr    string `toml:"addr"`
+	BaseURL string `toml:"base_url"` // Public URL of the app, used for links in emails
+
+	// SigningSecret keys the HMAC on links that must work without logging in
+	// (e.g. unsubscribe links). Keep it secret; changing it invalidates old links.
+	SigningSecret string `toml:"signing_secret"`
+
+	DB struct {
+		Driver       string        `toml:"driver"` // "postgres" (default) or "sqlite"
+		DSN          string        `toml:"dsn"`    // PostgreSQL DSN, or the database file path for SQLite
+		MaxOpenConns int           `toml:"max_open_conns"`
+		MaxIdleConns int           `toml:"max_idle_conns"`
+		MaxIdleTime  time.Duration `toml:"max_idle_time"`
+		MaxLifetime  time.Duration `toml:"max_lifetime"`
+		PingTimeout  time.Duration `toml:"ping_timeout"`
+		QueryTimeout time.Duration `toml:"query_timeout"`
+	} `toml:"db"`
+
+	Server struct {
+		IdleTimeout  time.Duration `toml:"idle_timeout"`
+		ReadTimeout  time.Duration `toml:"read_timeout"`
+		WriteTimeout time.Duration `toml:"write_timeout"`
+	} `toml:"server"`
+
+	TLS struct {
+		CertFile       string        `toml:"cert_file"`
+		KeyFile        string        `toml:"key_file"`
+		RedirectAddr   string        `toml:"redirect_addr"`   // Optional plain-HTTP listener that redirects to HTTPS
+		ReloadInterval time.Duration `toml:"reload_interval"` // How often the certificate files are checked for changes
+		HSTSMaxAge     time.Duration `toml:"hsts_max_age"`
+	} `toml:"tls"`
+
+	UI struct {
+		Timezone string `toml:"timezone"` // IANA zone used until the browser reports its own ("Local" = server zone)
+	} `toml:"ui"`
+
+	Mail struct {
+		Driver  string `toml:"driver"`   // "log" (default), "file" or "smtp"
+		Sender  string `toml:"sender"`   // From header, e.g. "Feel Flow <no-reply@example.com>"
+		FileDir string `toml:"file_dir"` // Where the file driver writes .eml files
+
+		SMTP struct {
+			Host     string `toml:"host"`
+			Port     int    `toml:"port"`
+			Username string `toml:"username"`
+			Password string `toml:"password"`
+		} `toml:"smtp"`
+	} `toml:"mail"`
+
+	// Reminders are sent to a single recipient until the app has user accounts.
+	Reminders struct {
+		Enabled   bool   `toml:"enabled"`
+		Recipient string `toml:"recipient"`
+		Time      string `toml:"time"`     // Local time of day, "15:04"
+		Timezone  string `toml:"timezone"` // IANA zone for Time (and the digest); defaults to ui.timezone
+	} `toml:"reminders"`
+
+	// The weekly digest is opt-in; recipients can also leave via the signed unsubscribe link.
+	Digest struct {
+		Enabled   bool   `toml:"enabled"`
+		Recipient string `toml:"recipient"`
+		Time      string `toml:"time"` // Local time on Sunday, "15:04"
+	} `toml:"digest"`
+
+	// Encryption seals note titles and content at rest when master keys are set.
+	Encryption struct {
+		MasterKeys    stringList    `toml:"master_keys"`      // "id:base64key" entries; the first wraps new data keys
+		DataKeyMaxAge time.Duration `toml:"data_key_max_age"` // Rotate the active data key once it is this old (0 = never)
+	} `toml:"encryption"`
+
+	// RateLimit throttles each client IP (IPv6: each /64) with a token bucket per route group.
+	RateLimit struct {
+		Enabled        bool       `toml:"enabled"`
+		TrustedProxies stringList `toml:"trusted_proxies"` // IPs/CIDRs whose X-Forwarded-For header is believed
+		Read           rateGroup  `toml:"read"`            // Page views and the live preview
+		Write          rateGroup  `toml:"write"`           // Note changes and unsubscribes
+		Autosave       rateGroup  `toml:"autosave"`        // Draft autosaves, every few seconds while writing
+		Sync           rateGroup  `toml:"sync"`            // JSON API note changes, which the service worker replays in bulk
+	} `toml:"rate_limit"`
+
+	// Privacy covers "download all my data" and "delete all my data".
+	Privacy struct {
+		ExportTTL          time.Duration `toml:"export_ttl"`           // How long a finished export can be downloaded
+		ErasureGracePeriod time.Duration `toml:"erasure_grace_period"` // Delay before a requested erasure runs, so it can be cancelled
+	} `toml:"privacy"`
+
+	// Drafts are autosaved copies of the note form, kept until the note is saved.
+	Drafts struct {
+		TTL time.Duration `toml:"ttl"` // Unsaved drafts older than this are deleted
+	} `toml:"drafts"`
+
+	// Attachments are images and audio clips uploaded to a note, stored outside the database.
+	Attachments struct {
+		Dir             string        `toml:"dir"`              // Where the local blob store keeps uploaded files
+		MaxSize         int64         `toml:"max_size"`         // Largest file accepted, in bytes
+		TransferTimeout time.Duration `toml:"transfer_timeout"` // How long one upload or download may take (the server timeouts are too short)
+	} `toml:"attachments"`
+
+	Limits struct {
+		TitleMaxLength   int `toml:"title_max_length"`
+		ContentMaxLength int `toml:"content_max_length"`
+	} `toml:"limits"`
+}
+
+// rateGroup is the token bucket size for one group of routes.
+type rateGroup struct {
+	RPS   float64 `toml:"rps"`   // Average requests per second allowed
+	Burst int     `toml:"burst"` // Requests allowed at once before throttling starts
+}
+
+// stringList is a comma-separated flag value that decodes from a TOML array.
+type stringList []string
+
+// String implements flag.Value.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value. It replaces the list rather than appending, so an
+// environment variable or flag overrides the config file instead of adding to it.
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// defaultConfig returns the settings the app used before they became configurable.
+func defaultConfig() config {
+	var cfg config
+	cfg.Addr = ":4000"
+	cfg.BaseURL = "http://localhost:4000"
+
+	cfg.DB.Driver = "postgres"
+	cfg.DB.MaxOpenConns = 25
+	cfg.DB.MaxIdleConns = 25
+	cfg.DB.MaxIdleTime = 5 * time.Minute
+	cfg.DB.MaxLifetime = 2 * time.Hour
+	cfg.DB.PingTimeout = 5 * time.Second
+	cfg.DB.QueryTimeout = 3 * time.Second
+
+	cfg.Server.IdleTimeout = time.Minute
+	cfg.Server.ReadTimeout = 5 * time.Second
+	cfg.Server.WriteTimeout = 10 * time.Second
+
+	cfg.TLS.ReloadInterval = 30 * time.Second
+	cfg.TLS.HSTSMaxAge = 365 * 24 * time.Hour
+
+	cfg.UI.Timezone = "Local"
+
+	cfg.Mail.Driver = "log"
+	cfg.Mail.Sender = "Feel Flow <no-reply@feelflow.local>"
+	cfg.Mail.FileDir = "./tmp/mail"
+	cfg.Mail.SMTP.Port = 587
+
+	cfg.Reminders.Time = "20:00"
+	cfg.Digest.Time = "18:00"
+
+	cfg.Encryption.DataKeyMaxAge = 90 * 24 * time.Hour
+
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Read = rateGroup{RPS: 10, Burst: 40}
+	cfg.RateLimit.Write = rateGroup{RPS: 0.5, Burst: 10}
+	cfg.RateLimit.Autosave = rateGroup{RPS: 1, Burst: 20}
+	cfg.RateLimit.Sync = rateGroup{RPS: 2, Burst: 50}
+
+	cfg.Privacy.ExportTTL = 24 * time.Hour
+	cfg.Privacy.ErasureGracePeriod = 7 * 24 * time.Hour
+
+	cfg.Drafts.TTL = 7 * 24 * time.Hour
+
+	cfg.Attachments.Dir = "./tmp/attachments"
+	cfg.Attachments.MaxSize = 10 << 20
+	cfg.Attachments.TransferTimeout = 2 * time.Minute
+
+	cfg.Limits.TitleMaxLength = 150
+	cfg.Limits.ContentMaxLength = 5000
+	return cfg
+}
+
+// envVars maps each MOODNOTES_* environment variable to the flag it overrides.
+// Keeping them in one table means a new setting only needs a flag and a line here.
+var envVars = []struct {
+	env  string
+	flag string
+}{
+	{"MOODNOTES_ADDR", "addr"},
+	{"MOODNOTES_BASE_URL", "base-url"},
+	{"MOODNOTES_SIGNING_SECRET", "signing-secret"},
+	{"MOODNOTES_DB_DRIVER", "db-driver"},
+	{"MOODNOTES_DB_DSN", "dsn"},
+	{"MOODNOTES_DB_MAX_OPEN_CONNS", "db-max-open-conns"},
+	{"MOODNOTES_DB_MAX_IDLE_CONNS", "db-max-idle-conns"},
+	{"MOODNOTES_DB_MAX_IDLE_TIME", "db-max-idle-time"},
+	{"MOODNOTES_DB_MAX_LIFETIME", "db-max-lifetime"},
+	{"MOODNOTES_DB_PING_TIMEOUT", "db-ping-timeout"},
+	{"MOODNOTES_DB_QUERY_TIMEOUT", "db-query-timeout"},
+	{"MOODNOTES_SERVER_IDLE_TIMEOUT", "server-idle-timeout"},
+	{"MOODNOTES_SERVER_READ_TIMEOUT", "server-read-timeout"},
+	{"MOODNOTES_SERVER_WRITE_TIMEOUT", "server-write-timeout"},
+	{"MOODNOTES_TLS_CERT", "tls-cert"},
+	{"MOODNOTES_TLS_KEY", "tls-key"},
+	{"MOODNOTES_TLS_REDIRECT_ADDR", "tls-redirect-addr"},
+	{"MOODNOTES_TLS_RELOAD_INTERVAL", "tls-reload-interval"},
+	{"MOODNOTES_TLS_HSTS_MAX_AGE", "tls-hsts-max-age"},
+	{"MOODNOTES_UI_TIMEZONE", "timezone"},
+	{"MOODNOTES_MAIL_DRIVER", "mail-driver"},
+	{"MOODNOTES_MAIL_SENDER", "mail-sender"},
+	{"MOODNOTES_MAIL_FILE_DIR", "mail-file-dir"},
+	{"MOODNOTES_SMTP_HOST", "smtp-host"},
+	{"MOODNOTES_SMTP_PORT", "smtp-port"},
+	{"MOODNOTES_SMTP_USERNAME", "smtp-username"},
+	{"MOODNOTES_SMTP_PASSWORD", "smtp-password"},
+	{"MOODNOTES_REMINDERS_ENABLED", "reminders"},
+	{"MOODNOTES_REMINDERS_RECIPIENT", "reminder-recipient"},
+	{"MOODNOTES_REMINDERS_TIME", "reminder-time"},
+	{"MOODNOTES_REMINDERS_TIMEZONE", "reminder-timezone"},
+	{"MOODNOTES_DIGEST_ENABLED", "digest"},
+	{"MOODNOTES_DIGEST_RECIPIENT", "digest-recipient"},
+	{"MOODNOTES_DIGEST_TIME", "digest-time"},
+	{"MOODNOTES_ENCRYPTION_MASTER_KEYS", "encryption-master-keys"},
+	{"MOODNOTES_ENCRYPTION_DATA_KEY_MAX_AGE", "encryption-data-key-max-age"},
+	{"MOODNOTES_RATE_LIMIT_ENABLED", "rate-limit"},
+	{"MOODNOTES_RATE_LIMIT_TRUSTED_PROXIES", "trusted-proxies"},
+	{"MOODNOTES_RATE_LIMIT_READ_RPS", "rate-limit-read-rps"},
+	{"MOODNOTES_RATE_LIMIT_READ_BURST", "rate-limit-read-burst"},
+	{"MOODNOTES_RATE_LIMIT_WRITE_RPS", "rate-limit-write-rps"},
+	{"MOODNOTES_RATE_LIMIT_WRITE_BURST", "rate-limit-write-burst"},
+	{"MOODNOTES_RATE_LIMIT_AUTOSAVE_RPS", "rate-limit-autosave-rps"},
+	{"MOODNOTES_RATE_LIMIT_AUTOSAVE_BURST", "rate-limit-autosave-burst"},
+	{"MOODNOTES_RATE_LIMIT_SYNC_RPS", "rate-limit-sync-rps"},
+	{"MOODNOTES_RATE_LIMIT_SYNC_BURST", "rate-limit-sync-burst"},
+	{"MOODNOTES_PRIVACY_EXPORT_TTL", "export-ttl"},
+	{"MOODNOTES_PRIVACY_ERASURE_GRACE_PERIOD", "erasure-grace-period"},
+	{"MOODNOTES_DRAFTS_TTL", "draft-ttl"},
+	{"MOODNOTES_ATTACHMENTS_DIR", "attachments-dir"},
+	{"MOODNOTES_ATTACHMENTS_MAX_SIZE", "attachment-max-size"},
+	{"MOODNOTES_ATTACHMENTS_TRANSFER_TIMEOUT", "attachment-transfer-timeout"},
+	{"MOODNOTES_LIMITS_TITLE_MAX_LENGTH", "title-max-length"},
+	{"MOODNOTES_LIMITS_CONTENT_MAX_LENGTH", "content-max-length"},
+}
+
+// loadConfig builds the effective configuration from defaults, the optional config file,
+// the environment and the given command-line arguments (normally os.Args[1:]).
+// The second return value reports whether -print-config was requested.
+func loadConfig(args []string) (config, bool, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("MOODNOTES_CONFIG"), "Path to a TOML config file (reads MOODNOTES_CONFIG env var)")
+	printConfig := fs.Bool("print-config", false, "Print the effective configuration (secrets redacted) and exit")
+
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "HTTP network address")
+	fs.StringVar(&cfg.BaseURL, "base-url", cfg.BaseURL, "Public base URL of the app, used in email links")
+	fs.StringVar(&cfg.SigningSecret, "signing-secret", cfg.SigningSecret, "Secret (32+ chars) for signed links (prefer MOODNOTES_SIGNING_SECRET)")
+	fs.StringVar(&cfg.DB.Driver, "db-driver", cfg.DB.Driver, "Storage backend: postgres or sqlite")
+	fs.StringVar(&cfg.DB.DSN, "dsn", cfg.DB.DSN, "PostgreSQL DSN, or SQLite database file (reads MOODNOTES_DB_DSN env var)")
+	fs.IntVar(&cfg.DB.MaxOpenConns, "db-max-open-conns", cfg.DB.MaxOpenConns, "Max number of open database connections")
+	fs.IntVar(&cfg.DB.MaxIdleConns, "db-max-idle-conns", cfg.DB.MaxIdleConns, "Max number of idle database connections")
+	fs.DurationVar(&cfg.DB.MaxIdleTime, "db-max-idle-time", cfg.DB.MaxIdleTime, "How long a database connection can be idle before being closed")
+	fs.DurationVar(&cfg.DB.MaxLifetime, "db-max-lifetime", cfg.DB.MaxLifetime, "Max lifetime of any database connection")
+	fs.DurationVar(&cfg.DB.PingTimeout, "db-ping-timeout", cfg.DB.PingTimeout, "Timeout for the startup database ping")
+	fs.DurationVar(&cfg.DB.QueryTimeout, "db-query-timeout", cfg.DB.QueryTimeout, "Timeout for individual database queries")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "server-idle-timeout", cfg.Server.IdleTimeout, "Max time for idle HTTP connections")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "server-read-timeout", cfg.Server.ReadTimeout, "Max time to read an HTTP request")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "server-write-timeout", cfg.Server.WriteTimeout, "Max time to write an HTTP response")
+	fs.StringVar(&cfg.TLS.CertFile, "tls-cert", cfg.TLS.CertFile, "Path to a PEM certificate file; enables HTTPS together with -tls-key")
+	fs.StringVar(&cfg.TLS.KeyFile, "tls-key", cfg.TLS.KeyFile, "Path to the PEM private key for -tls-cert")
+	fs.StringVar(&cfg.TLS.RedirectAddr, "tls-redirect-addr", cfg.TLS.RedirectAddr, "Optional plain HTTP address that redirects to HTTPS (e.g. :80)")
+	fs.DurationVar(&cfg.TLS.ReloadInterval, "tls-reload-interval", cfg.TLS.ReloadInterval, "How often to check the certificate files for changes")
+	fs.DurationVar(&cfg.TLS.HSTSMaxAge, "tls-hsts-max-age", cfg.TLS.HSTSMaxAge, "max-age for the Strict-Transport-Security header when HTTPS is on")
+	fs.StringVar(&cfg.UI.Timezone, "timezone", cfg.UI.Timezone, "Default IANA timezone for displaying dates (e.g. America/Belize)")
+	fs.StringVar(&cfg.Mail.Driver, "mail-driver", cfg.Mail.Driver, "How to deliver email: log, file or smtp")
+	fs.StringVar(&cfg.Mail.Sender, "mail-sender", cfg.Mail.Sender, "From address for outgoing email")
+	fs.StringVar(&cfg.Mail.FileDir, "mail-file-dir", cfg.Mail.FileDir, "Directory for .eml files when -mail-driver=file")
+	fs.StringVar(&cfg.Mail.SMTP.Host, "smtp-host", cfg.Mail.SMTP.Host, "SMTP server host")
+	fs.IntVar(&cfg.Mail.SMTP.Port, "smtp-port", cfg.Mail.SMTP.Port, "SMTP server port")
+	fs.StringVar(&cfg.Mail.SMTP.Username, "smtp-username", cfg.Mail.SMTP.Username, "SMTP username")
+	fs.StringVar(&cfg.Mail.SMTP.Password, "smtp-password", cfg.Mail.SMTP.Password, "SMTP password (prefer MOODNOTES_SMTP_PASSWORD)")
+	fs.BoolVar(&cfg.Reminders.Enabled, "reminders", cfg.Reminders.Enabled, "Send a daily journaling reminder email")
+	fs.StringVar(&cfg.Reminders.Recipient, "reminder-recipient", cfg.Reminders.Recipient, "Email address that receives reminders")
+	fs.StringVar(&cfg.Reminders.Time, "reminder-time", cfg.Reminders.Time, "Local time of day to send the reminder (HH:MM)")
+	fs.StringVar(&cfg.Reminders.Timezone, "reminder-timezone", cfg.Reminders.Timezone, "IANA timezone for -reminder-time (defaults to -timezone)")
+	fs.BoolVar(&cfg.Digest.Enabled, "digest", cfg.Digest.Enabled, "Send the weekly mood digest email on Sundays")
+	fs.StringVar(&cfg.Digest.Recipient, "digest-recipient", cfg.Digest.Recipient, "Email address that receives the weekly digest")
+	fs.StringVar(&cfg.Digest.Time, "digest-time", cfg.Digest.Time, "Local time on Sunday to send the digest (HH:MM)")
+	fs.Var(&cfg.Encryption.MasterKeys, "encryption-master-keys", "Comma-separated id:base64key master keys; enables note encryption (prefer MOODNOTES_ENCRYPTION_MASTER_KEYS)")
+	fs.DurationVar(&cfg.Encryption.DataKeyMaxAge, "encryption-data-key-max-age", cfg.Encryption.DataKeyMaxAge, "Rotate the note data key once it is this old (0 = never)")
+	fs.BoolVar(&cfg.RateLimit.Enabled, "rate-limit", cfg.RateLimit.Enabled, "Throttle clients that send too many requests")
+	fs.Var(&cfg.RateLimit.TrustedProxies, "trusted-proxies", "Comma-separated proxy IPs/CIDRs whose X-Forwarded-For header is trusted")
+	fs.Float64Var(&cfg.RateLimit.Read.RPS, "rate-limit-read-rps", cfg.RateLimit.Read.RPS, "Average page views per second allowed per client")
+	fs.IntVar(&cfg.RateLimit.Read.Burst, "rate-limit-read-burst", cfg.RateLimit.Read.Burst, "Page views a client may make at once")
+	fs.Float64Var(&cfg.RateLimit.Write.RPS, "rate-limit-write-rps", cfg.RateLimit.Write.RPS, "Average note changes per second allowed per client")
+	fs.IntVar(&cfg.RateLimit.Write.Burst, "rate-limit-write-burst", cfg.RateLimit.Write.Burst, "Note changes a client may make at once")
+	fs.Float64Var(&cfg.RateLimit.Autosave.RPS, "rate-limit-autosave-rps", cfg.RateLimit.Autosave.RPS, "Average draft autosaves per second allowed per client")
+	fs.IntVar(&cfg.RateLimit.Autosave.Burst, "rate-limit-autosave-burst", cfg.RateLimit.Autosave.Burst, "Draft autosaves a client may make at once")
+	fs.Float64Var(&cfg.RateLimit.Sync.RPS, "rate-limit-sync-rps", cfg.RateLimit.Sync.RPS, "Average API note changes per second allowed per client")
+	fs.IntVar(&cfg.RateLimit.Sync.Burst, "rate-limit-sync-burst", cfg.RateLimit.Sync.Burst, "API note changes a client may make at once, e.g. an offline queue")
+	fs.DurationVar(&cfg.Privacy.ExportTTL, "export-ttl", cfg.Privacy.ExportTTL, "How long a data export stays downloadable")
+	fs.DurationVar(&cfg.Privacy.ErasureGracePeriod, "erasure-grace-period", cfg.Privacy.ErasureGracePeriod, "Delay before a requested data erasure runs")
+	fs.DurationVar(&cfg.Drafts.TTL, "draft-ttl", cfg.Drafts.TTL, "How long an unsaved note draft is kept")
+	fs.StringVar(&cfg.Attachments.Dir, "attachments-dir", cfg.Attachments.Dir, "Directory where uploaded attachments are stored")
+	fs.Int64Var(&cfg.Attachments.MaxSize, "attachment-max-size", cfg.Attachments.MaxSize, "Largest attachment accepted, in bytes")
+	fs.DurationVar(&cfg.Attachments.TransferTimeout, "attachment-transfer-timeout", cfg.Attachments.TransferTimeout, "How long an attachment upload or download may take")
+	fs.IntVar(&cfg.Limits.TitleMaxLength, "title-max-length", cfg.Limits.TitleMaxLength, "Max characters allowed in a note title")
+	fs.IntVar(&cfg.Limits.ContentMaxLength, "content-max-length", cfg.Limits.ContentMaxLength, "Max characters allowed in note content")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, false, err
+	}
+
+	// Remember which flags were given explicitly so they can be re-applied
+	// on top of the file and environment (flags have the final say).
+	explicit := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	// --- Config File ---
+	if *configFile != "" {
+		md, err := toml.DecodeFile(*configFile, &cfg)
+		if err != nil {
+			return cfg, false, fmt.Errorf("reading config file %s: %w", *configFile, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return cfg, false, fmt.Errorf("unknown keys in config file %s: %v", *configFile, undecoded)
+		}
+	}
+
+	// --- Environment ---
+	for _, ev := range envVars {
+		value, ok := os.LookupEnv(ev.env)
+		if !ok || value == "" {
+			continue
+		}
+		err := fs.Set(ev.flag, value)
+		if err != nil {
+			return cfg, false, fmt.Errorf("invalid value for %s: %w", ev.env, err)
+		}
+	}
+
+	// --- Flags ---
+	for name, value := range explicit {
+		err := fs.Set(name, value)
+		if err != nil {
+			return cfg, false, fmt.Errorf("invalid value for -%s: %w", name, err)
+		}
+	}
+
+	return cfg, *printConfig, nil
+}
+
+// validate checks the effective configuration and returns a single error listing every problem.
+func (cfg config) validate() error {
+	v := validator.NewValidator()
+
+	v.Check(validator.NotBlank(cfg.Addr), "addr", "must be provided")
+	v.Check(cfg.DB.Driver == "postgres" || cfg.DB.Driver == "sqlite", "db.driver", "must be postgres or sqlite")
+	v.Check(validator.NotBlank(cfg.DB.DSN), "db.dsn", "must be provided (set MOODNOTES_DB_DSN or use the -dsn flag)")
+	v.Check(cfg.DB.MaxOpenConns > 0, "db.max_open_conns", "must be greater than zero")
+	v.Check(cfg.DB.MaxIdleConns >= 0, "db.max_idle_conns", "must not be negative")
+	v.Check(cfg.DB.MaxIdleConns <= cfg.DB.MaxOpenConns, "db.max_idle_conns", "must not be more than db.max_open_conns")
+	v.Check(cfg.DB.MaxIdleTime >= 0, "db.max_idle_time", "must not be negative")
+	v.Check(cfg.DB.MaxLifetime >= 0, "db.max_lifetime", "must not be negative")
+	v.Check(cfg.DB.PingTimeout > 0, "db.ping_timeout", "must be greater than zero")
+	v.Check(cfg.DB.QueryTimeout > 0, "db.query_timeout", "must be greater than zero")
+	v.Check(cfg.Server.IdleTimeout > 0, "server.idle_timeout", "must be greater than zero")
+	v.Check(cfg.Server.ReadTimeout > 0, "server.read_timeout", "must be greater than zero")
+	v.Check(cfg.Server.WriteTimeout > 0, "server.write_timeout", "must be greater than zero")
+	v.Check((cfg.TLS.CertFile == "") == (cfg.TLS.KeyFile == ""), "tls", "cert_file and key_file must be provided together")
+	v.Check(cfg.TLS.RedirectAddr == "" || cfg.tlsEnabled(), "tls.redirect_addr", "requires cert_file and key_file")
+	v.Check(cfg.TLS.RedirectAddr == "" || cfg.TLS.RedirectAddr != cfg.Addr, "tls.redirect_addr", "must differ from addr")
+	v.Check(cfg.TLS.ReloadInterval > 0, "tls.reload_interval", "must be greater than zero")
+	v.Check(cfg.TLS.HSTSMaxAge >= 0, "tls.hsts_max_age", "must not be negative")
+	_, tzErr := time.LoadLocation(cfg.UI.Timezone)
+	v.Check(tzErr == nil, "ui.timezone", "must be a valid IANA timezone name")
+	baseURL, urlErr := url.Parse(cfg.BaseURL)
+	v.Check(urlErr == nil && (baseURL.Scheme == "http" || baseURL.Scheme == "https") && baseURL.Host != "", "base_url", "must be an absolute http(s) URL")
+	v.Check(cfg.Mail.Driver == "log" || cfg.Mail.Driver == "file" || cfg.Mail.Driver == "smtp", "mail.driver", "must be log, file or smtp")
+	_, senderErr := mail.ParseAddress(cfg.Mail.Sender)
+	v.Check(senderErr == nil, "mail.sender", "must be a valid email address")
+	if cfg.Mail.Driver == "file" {
+		v.Check(validator.NotBlank(cfg.Mail.FileDir), "mail.file_dir", "must be provided for the file driver")
+	}
+	if cfg.Mail.Driver == "smtp" {
+		v.Check(validator.NotBlank(cfg.Mail.SMTP.Host), "mail.smtp.host", "must be provided for the smtp driver")
+		v.Check(cfg.Mail.SMTP.Port > 0 && cfg.Mail.SMTP.Port <= 65535, "mail.smtp.port", "must be a valid port")
+	}
+	if cfg.Reminders.Enabled {
+		v.Check(validator.IsValidEmail(cfg.Reminders.Recipient), "reminders.recipient", "must be a valid email address")
+		_, timeErr := time.Parse("15:04", cfg.Reminders.Time)
+		v.Check(timeErr == nil, "reminders.time", "must be a time of day like 20:00")
+		if cfg.Reminders.Timezone != "" {
+			_, tzErr := time.LoadLocation(cfg.Reminders.Timezone)
+			v.Check(tzErr == nil, "reminders.timezone", "must be a valid IANA timezone name")
+		}
+	}
+	if cfg.Digest.Enabled {
+		v.Check(validator.IsValidEmail(cfg.Digest.Recipient), "digest.recipient", "must be a valid email address")
+		_, timeErr := time.Parse("15:04", cfg.Digest.Time)
+		v.Check(timeErr == nil, "digest.time", "must be a time of day like 18:00")
+		v.Check(validator.MinLength(cfg.SigningSecret, 32), "signing_secret", "must be at least 32 characters when the digest is enabled")
+	}
+	if cfg.encryptionEnabled() {
+		_, keysErr := vault.ParseKeyring(cfg.Encryption.MasterKeys)
+		v.Check(keysErr == nil, "encryption.master_keys", "must be id:base64key entries holding 32-byte keys with unique ids")
+	}
+	v.Check(cfg.Encryption.DataKeyMaxAge >= 0, "encryption.data_key_max_age", "must not be negative")
+	if cfg.RateLimit.Enabled {
+		v.Check(cfg.RateLimit.Read.RPS > 0, "rate_limit.read.rps", "must be greater than zero")
+		v.Check(cfg.RateLimit.Read.Burst > 0, "rate_limit.read.burst", "must be greater than zero")
+		v.Check(cfg.RateLimit.Write.RPS > 0, "rate_limit.write.rps", "must be greater than zero")
+		v.Check(cfg.RateLimit.Write.Burst > 0, "rate_limit.write.burst", "must be greater than zero")
+		v.Check(cfg.RateLimit.Autosave.RPS > 0, "rate_limit.autosave.rps", "must be greater than zero")
+		v.Check(cfg.RateLimit.Autosave.Burst > 0, "rate_limit.autosave.burst", "must be greater than zero")
+		v.Check(cfg.RateLimit.Sync.RPS > 0, "rate_limit.sync.rps", "must be greater than zero")
+		v.Check(cfg.RateLimit.Sync.Burst > 0, "rate_limit.sync.burst", "must be greater than zero")
+	}
+	_, proxiesErr := parseTrustedProxies(cfg.RateLimit.TrustedProxies)
+	v.Check(proxiesErr == nil, "rate_limit.trusted_proxies", "must be IP addresses or CIDR ranges")
+	v.Check(cfg.Privacy.ExportTTL > 0, "privacy.export_ttl", "must be greater than zero")
+	v.Check(cfg.Privacy.ErasureGracePeriod >= 0, "privacy.erasure_grace_period", "must not be negative")
+	v.Check(cfg.Drafts.TTL >= time.Hour, "drafts.ttl", "must be at least 1h")
+	v.Check(validator.NotBlank(cfg.Attachments.Dir), "attachments.dir", "must be provided")
+	v.Check(cfg.Attachments.MaxSize > 0 && cfg.Attachments.MaxSize <= 100<<20, "attachments.max_size", "must be between 1 byte and 100 MiB")
+	v.Check(cfg.Attachments.TransferTimeout > 0, "attachments.transfer_timeout", "must be greater than zero")
+	v.Check(cfg.Limits.TitleMaxLength > 0, "limits.title_max_length", "must be greater than zero")
+	v.Check(cfg.Limits.ContentMaxLength > 0, "limits.content_max_length", "must be greater than zero")
+
+	if v.ValidData() {
+		return nil
+	}
+
+	// Sort the field names so the message is stable between runs.
+	fields := make([]string, 0, len(v.Errors))
+	for field := range v.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	problems := make([]string, 0, len(fields))
+	for _, field := range fields {
+		problems = append(problems, fmt.Sprintf("%s %s", field, v.Errors[field]))
+	}
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
+
+// tlsEnabled reports whether the server should listen with HTTPS.
+func (cfg config) tlsEnabled() bool {
+	return cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != ""
+}
+
+// encryptionEnabled reports whether notes should be encrypted at rest.
+func (cfg config) encryptionEnabled() bool {
+	return len(cfg.Encryption.MasterKeys) > 0
+}
+
+// --- Printing ---
+
+// redactedPlaceholder replaces secret values when the config is printed.
+const redactedPlaceholder = "REDACTED"
+
+// dsnPasswordRX matches the password in key=value style DSNs ("host=... password=secret").
+var dsnPasswordRX = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// redacted returns a copy of cfg that is safe to print or log.
+func (cfg config) redacted() config {
+	cfg.DB.DSN = redactDSN(cfg.DB.DSN)
+	if cfg.SigningSecret != "" {
+		cfg.SigningSecret = redactedPlaceholder
+	}
+	if cfg.Mail.SMTP.Password != "" {
+		cfg.Mail.SMTP.Password = redactedPlaceholder
+	}
+	// Keep the IDs so it's clear which keys are configured, but never the key material.
+	if len(cfg.Encryption.MasterKeys) > 0 {
+		keys := make(stringList, len(cfg.Encryption.MasterKeys))
+		for i, entry := range cfg.Encryption.MasterKeys {
+			id, _, _ := strings.Cut(entry, ":")
+			keys[i] = id + ":" + redactedPlaceholder
+		}
+		cfg.Encryption.MasterKeys = keys
+	}
+	return cfg
+}
+
+// redactDSN hides the password in both URL style and key=value style PostgreSQL DSNs.
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	u, err := url.Parse(dsn)
+	if err == nil && u.Scheme != "" {
+		if u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.UserPassword(u.User.Username(), redactedPlaceholder)
+			}
+		}
+		q := u.Query()
+		if q.Has("password") {
+			q.Set("password", redactedPlaceholder)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	return dsnPasswordRX.ReplaceAllString(dsn, "${1}"+redactedPlaceholder)
+}
+
+// printTOML renders the redacted configuration as TOML, so the output can be
+// saved and reused directly as a config file.
+func (cfg config) printTOML() (string, error) {
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).Encode(cfg.redacted())
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,66 @@
+// cmd/web/context.go
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// contextKey is a private type for request context keys, so they can't collide
+// with keys set by other packages.
+type contextKey string
+
+const (
+	cspNonceContextKey  = contextKey("cspNonce")
+	requestIDContextKey = contextKey("requestID")
+	apiTokenContextKey  = contextKey("apiToken")
+)
+
+// cspNonce returns the Content-Security-Policy nonce generated for this request
+// by the secureHeaders middleware, or "" if there is none.
+func cspNonce(r *http.Request) string {
+	nonce, ok := r.Context().Value(cspNonceContextKey).(string)
+	if !ok {
+		return ""
+	}
+	return nonce
+}
+
+// withCSPNonce returns a copy of r carrying the given nonce.
+func withCSPNonce(r *http.Request, nonce string) *http.Request {
+	ctx := context.WithValue(r.Context(), cspNonceContextKey, nonce)
+	return r.WithContext(ctx)
+}
+
+// requestID returns the ID assigned to this request by the requestID middleware, or "".
+func requestID(r *http.Request) string {
+	id, ok := r.Context().Value(requestIDContextKey).(string)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// withRequestID returns a copy of r carrying the given request ID.
+func withRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// apiToken returns the API token that authenticated this request (see
+// requireScope), or nil for requests from the browser.
+func apiToken(r *http.Request) *data.APIToken {
+	token, ok := r.Context().Value(apiTokenContextKey).(*data.APIToken)
+	if !ok {
+		return nil
+	}
+	return token
+}
+
+// withAPIToken returns a copy of r carrying the token that authenticated it.
+func withAPIToken(r *http.Request, token *data.APIToken) *http.Request {
+	ctx := context.WithValue(r.Context(), apiTokenContextKey, token)
+	return r.WithContext(ctx)
+}
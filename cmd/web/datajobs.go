@@ -0,0 +1,300 @@
+// cmd/web/datajobs.go
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// dataJobInterval is how often the worker looks for export requests and due erasures.
+// Short, so a requested export is ready a few seconds after the button is pressed.
+const dataJobInterval = 5 * time.Second
+
+// exportManifest is manifest.json: what the archive holds and what it can't.
+type exportManifest struct {
+	Format      int               `json:"format"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Files       map[string]string `json:"files"`
+	Notes       []string          `json:"notes"`
+}
+
+// runDataJobs builds requested data exports, purges expired archives and drafts,
+// carries out scheduled erasures and deletes the files of deleted attachments
+// until done is closed. Exports left half-built
+// by a previous run are queued again first.
+func (app *application) runDataJobs(done <-chan struct{}) {
+	err := app.dataExports.ResetStale()
+	if err != nil {
+		app.logger.Error("requeueing data exports failed", "error", err)
+	}
+
+	ticker := time.NewTicker(dataJobInterval)
+	defer ticker.Stop()
+
+	for {
+		app.runDataExports()
+
+		purged, err := app.purgeExpiredExports()
+		if err != nil {
+			app.logger.Error("purging expired data exports failed", "error", err)
+		} else if purged > 0 {
+			app.logger.Info("purged expired data exports", "count", purged)
+		}
+
+		purged, err = app.drafts.PurgeExpired()
+		if err != nil {
+			app.logger.Error("purging expired drafts failed", "error", err)
+		} else if purged > 0 {
+			app.logger.Info("purged expired drafts", "count", purged)
+		}
+
+		err = app.eraseDataIfDue()
+		if err != nil {
+			app.logger.Error("data erasure failed", "error", err)
+		}
+
+		// After the erasure, which detaches every attachment, so their files go at once.
+		purged, err = app.purgeDetachedAttachments()
+		if err != nil {
+			app.logger.Error("purging deleted attachments failed", "error", err)
+		} else if purged > 0 {
+			app.logger.Info("purged deleted attachments", "count", purged)
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDataExports builds every pending export.
+func (app *application) runDataExports() {
+	for {
+		id, err := app.dataExports.ClaimPending()
+		if err != nil {
+			app.logger.Error("claiming data export failed", "error", err)
+			return
+		}
+		if id == 0 {
+			return
+		}
+
+		blobKey := data.DataExportBlobKey(id)
+		keyID, err := app.putSealedBlob(context.Background(), blobKey, func(w io.Writer) error {
+			return app.writeDataExport(w, time.Now())
+		})
+		if err != nil {
+			app.logger.Error("building data export failed", "export_id", id, "error", err)
+			err = app.dataExports.Fail(id, "The export could not be built. Please try again.")
+			if err != nil {
+				app.logger.Error("recording failed data export", "export_id", id, "error", err)
+			}
+			continue
+		}
+
+		err = app.dataExports.Complete(id, blobKey, keyID, time.Now().Add(app.config.Privacy.ExportTTL))
+		if err != nil {
+			app.logger.Error("saving data export failed", "export_id", id, "error", err)
+			// Rebuilt under the same key after the next restart requeues it.
+			continue
+		}
+		app.logger.Info("data export ready", "export_id", id)
+	}
+}
+
+// writeDataExport writes a ZIP of JSON files holding everything the app stores
+// about its owner to w. Notes are decrypted, since the archive is for the owner to
+// take elsewhere (it is sealed again on its way into the blob store); share tokens
+// and passcode hashes are left out because they are secrets that only work inside
+// this app.
+func (app *application) writeDataExport(w io.Writer, now time.Time) error {
+	notes, err := app.moodNotes.GetAll()
+	if err != nil {
+		return err
+	}
+	shares, err := app.noteShares.GetAll()
+	if err != nil {
+		return err
+	}
+	templates, err := app.noteTemplates.GetAll()
+	if err != nil {
+		return err
+	}
+	drafts, err := app.drafts.GetAll()
+	if err != nil {
+		return err
+	}
+	attached, err := app.attachments.GetAll()
+	if err != nil {
+		return err
+	}
+	events, err := app.auditEvents.GetAll()
+	if err != nil {
+		return err
+	}
+
+	manifest := exportManifest{
+		Format:      1,
+		GeneratedAt: now.UTC(),
+		Files: map[string]string{
+			"notes.json":        "Every journal entry, decrypted",
+			"shares.json":       "Share links, including revoked and expired ones",
+			"templates.json":    "Your note templates (the built-in ones ship with the app)",
+			"drafts.json":       "Unsaved drafts of new and edited entries, decrypted",
+			"attachments.json":  "Details of the images and audio clips attached to entries",
+			"attachments/":      "The attached files, as attachments/<id>/<filename>",
+			"audit_events.json": "The activity log",
+		},
+		Notes: []string{
+			"This app has a single owner and no user accounts, so there is no profile to export.",
+			"Entries have no revisions or tags; each note's version counts its edits.",
+			"Share link tokens and passcodes are not included, nor are API tokens.",
+		},
+	}
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"manifest.json", manifest},
+		{"notes.json", notes},
+		{"shares.json", shares},
+		{"templates.json", templates},
+		{"drafts.json", drafts},
+		{"attachments.json", attached},
+		{"audit_events.json", events},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(f.v)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Images and audio are compressed already, so they are stored as they are.
+	for _, a := range attached {
+		err = app.exportAttachment(zw, a, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// exportPurgeBatch is how many expired exports are looked up at a time.
+const exportPurgeBatch = 100
+
+// purgeExpiredExports deletes the archives of exports whose download window has
+// passed. An export is only marked expired once its blob is gone, so a failure
+// is simply retried on the next run.
+func (app *application) purgeExpiredExports() (int, error) {
+	purged := 0
+	for {
+		expired, err := app.dataExports.Expired(exportPurgeBatch)
+		if err != nil {
+			return purged, err
+		}
+		for _, e := range expired {
+			err = app.blobs.Delete(context.Background(), e.BlobKey)
+			if err != nil {
+				return purged, err
+			}
+			err = app.dataExports.MarkExpired(e.ID)
+			if err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(expired) < exportPurgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+// exportAttachment copies an attachment's file into the export archive.
+func (app *application) exportAttachment(zw *zip.Writer, a *data.Attachment, now time.Time) error {
+	blob, err := app.openSealedBlob(context.Background(), a.BlobKey, a.KeyID)
+	if err != nil {
+		return fmt.Errorf("exporting attachment %d: %w", a.ID, err)
+	}
+	defer blob.Close()
+
+	name := fmt.Sprintf("attachments/%d/%s", a.ID, a.Filename)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: now})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, blob)
+	return err
+}
+
+// attachmentPurgeBatch is how many detached attachments are looked up at a time.
+const attachmentPurgeBatch = 100
+
+// purgeDetachedAttachments deletes the blobs of attachments that were deleted or
+// whose note was deleted or erased, then their records. A record is only removed
+// once its blobs are gone, so a failure is simply retried on the next run.
+func (app *application) purgeDetachedAttachments() (int, error) {
+	purged := 0
+	for {
+		detached, err := app.attachments.Detached(attachmentPurgeBatch)
+		if err != nil {
+			return purged, err
+		}
+		for _, a := range detached {
+			for _, key := range []string{a.BlobKey, a.ThumbKey} {
+				if key == "" {
+					continue
+				}
+				err = app.blobs.Delete(context.Background(), key)
+				if err != nil {
+					return purged, err
+				}
+			}
+			err = app.attachments.Remove(a.ID)
+			if err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(detached) < attachmentPurgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+// eraseDataIfDue runs a scheduled erasure once its grace period is over, then
+// deletes the erased exports' archives. The erasure gives note encryption a fresh
+// data key itself (see DataErasureModel.EraseIfDue).
+func (app *application) eraseDataIfDue() error {
+	erased, blobKeys, err := app.dataErasures.EraseIfDue()
+	if err != nil || !erased {
+		return err
+	}
+	app.logger.Info("all journal data erased")
+
+	for _, key := range blobKeys {
+		err = app.blobs.Delete(context.Background(), key)
+		if err != nil {
+			// Its data key is gone, so what's left can't be read; log it for cleanup.
+			app.logger.Error("deleting erased export archive failed", "blob_key", key, "error", err)
+		}
+	}
+	return nil
+}
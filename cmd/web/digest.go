@@ -0,0 +1,162 @@
+// cmd/web/digest.go
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// digestKind identifies the weekly digest in email_sends and email_unsubscribes.
+const digestKind = "weekly-digest"
+
+// digestHighlight is a note summarised for the digest email.
+type digestHighlight struct {
+	Title   string
+	Date    string
+	Excerpt string
+	URL     string
+}
+
+// digestEmailData is passed to ui/email/weekly_digest.tmpl.
+type digestEmailData struct {
+	WeekLabel      string // e.g. "Oct 12 – Oct 18"
+	Entries        int
+	Comparison     string // e.g. "2 more than the week before"
+	DaysJournaled  int
+	AverageLength  int
+	Longest        *digestHighlight
+	YearAgo        *digestHighlight
+	UnsubscribeURL string
+}
+
+// sendDigestIfDue sends the weekly digest on Sunday once the configured local time has passed.
+func (app *application) sendDigestIfDue(now time.Time, loc *time.Location) error {
+	local := now.In(loc)
+	if local.Weekday() != time.Sunday {
+		return nil
+	}
+	// Config validation guarantees the format.
+	at, err := time.Parse("15:04", app.config.Digest.Time)
+	if err != nil {
+		return err
+	}
+	dueAt := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+	if now.Before(dueAt) {
+		return nil
+	}
+
+	recipient := app.config.Digest.Recipient
+	unsubscribed, err := app.unsubscribes.Exists(digestKind, recipient)
+	if err != nil {
+		return err
+	}
+	if unsubscribed {
+		return nil
+	}
+	verified, err := app.recipientVerified(recipient, now, loc)
+	if err != nil || !verified {
+		return err
+	}
+
+	// The digest covers Monday to Sunday of the current local week.
+	weekStart := data.PeriodStart(now, loc, data.PeriodWeek)
+	claimed, err := app.emailSends.Claim(digestKind, recipient, weekStart)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil // Already sent this week
+	}
+
+	err = app.sendDigest(recipient, weekStart, loc)
+	if err != nil {
+		// Give the claim back so the next tick retries.
+		releaseErr := app.emailSends.Release(digestKind, recipient, weekStart)
+		if releaseErr != nil {
+			app.logger.Error("could not release digest claim", "error", releaseErr)
+		}
+		return err
+	}
+
+	app.logger.Info("weekly digest sent", "recipient", recipient, "week", weekStart.Format("2006-01-02"))
+	return nil
+}
+
+// sendDigest gathers the week's statistics and emails them to recipient.
+func (app *application) sendDigest(recipient string, weekStart time.Time, loc *time.Location) error {
+	stats, err := app.moodNotes.DigestStats(weekStart, data.PeriodWeek, loc)
+	if err != nil {
+		return err
+	}
+	weekEnd := stats.End
+
+	td := digestEmailData{
+		WeekLabel:      weekStart.Format("Jan 02") + " – " + weekEnd.AddDate(0, 0, -1).Format("Jan 02"),
+		Entries:        stats.Entries,
+		Comparison:     compareCounts(stats.Entries, stats.PreviousEntries),
+		DaysJournaled:  stats.DaysJournaled,
+		AverageLength:  stats.AverageLength,
+		UnsubscribeURL: app.unsubscribeURL(digestKind, recipient),
+	}
+	td.Longest, err = app.digestHighlight(stats.Longest, loc)
+	if err != nil {
+		return err
+	}
+	td.YearAgo, err = app.digestHighlight(stats.YearAgo, loc)
+	if err != nil {
+		return err
+	}
+
+	msg, err := app.mailer.Render(recipient, "weekly_digest.tmpl", td)
+	if err != nil {
+		return err
+	}
+	// RFC 8058 one-click unsubscribe: mail clients POST to the URL without opening a page.
+	msg.Headers = map[string]string{
+		"List-Unsubscribe":      "<" + td.UnsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	return app.mailer.SendMessage(msg)
+}
+
+// digestHighlight summarises a note for the email, or returns nil for a nil note.
+func (app *application) digestHighlight(note *data.MoodNote, loc *time.Location) (*digestHighlight, error) {
+	if note == nil {
+		return nil, nil
+	}
+	text, err := noteRenderer.NoteText(note.ID, note.Version, note.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &digestHighlight{
+		Title:   note.Title,
+		Date:    note.OccurredAt.In(loc).Format("Monday, Jan 02, 2006"),
+		Excerpt: truncate(280, text),
+		URL:     fmt.Sprintf("%s/note/%d", app.config.BaseURL, note.ID),
+	}, nil
+}
+
+// compareCounts describes this week's entry count relative to last week's.
+func compareCounts(current, previous int) string {
+	switch {
+	case current > previous:
+		return fmt.Sprintf("%d more than the week before", current-previous)
+	case current < previous:
+		return fmt.Sprintf("%d fewer than the week before", previous-current)
+	default:
+		return "the same as the week before"
+	}
+}
+
+// unsubscribeURL returns a signed link that unsubscribes recipient from kind emails
+// without logging in.
+func (app *application) unsubscribeURL(kind, recipient string) string {
+	q := url.Values{}
+	q.Set("list", kind)
+	q.Set("email", recipient)
+	q.Set("sig", app.sign("unsubscribe", kind, recipient))
+	return app.config.BaseURL + "/unsubscribe?" + q.Encode()
+}
@@ -0,0 +1,308 @@
+// cmd/web/email_test.go
+
+//go:build cgo
+
+package main
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"mime/quotedprintable"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+	"github.com/mickali02/mood-notes-app/internal/mailer"
+	"github.com/mickali02/mood-notes-app/migrations"
+	"github.com/mickali02/mood-notes-app/ui"
+)
+
+const testRecipient = "writer@example.com"
+
+// newEmailTestApp returns an application on a fresh SQLite database that mails
+// through smtp, with reminders due at 20:00 and the digest at 18:00 on Sundays.
+// The recipient's address is already verified.
+func newEmailTestApp(t *testing.T, smtp *fakeSMTP) *application {
+	t.Helper()
+	db, err := sql.Open(data.SQLiteDriver, filepath.Join(t.TempDir(), "moodnotes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	_, err = data.MigrateSQLite(db, migrations.SQLite())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultConfig()
+	cfg.BaseURL = "https://journal.example.com"
+	cfg.SigningSecret = strings.Repeat("s", 32)
+	cfg.Reminders.Recipient = testRecipient
+	cfg.Digest.Recipient = testRecipient
+
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	models := data.NewModels(db, cfg.DB.QueryTimeout, nil)
+	err = models.VerifiedEmails.Insert(testRecipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &application{
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		config:          cfg,
+		models:          models,
+		moodNotes:       models.MoodNotes,
+		emailSends:      models.EmailSends,
+		unsubscribes:    models.Unsubscribes,
+		tokens:          models.Tokens,
+		verifiedEmails:  models.VerifiedEmails,
+		mailer:          mailer.New(&mailer.SMTPSender{Host: smtp.host, Port: smtp.port}, cfg.Mail.Sender, ui.Files, "email"),
+		templateCache:   templateCache,
+		defaultLocation: time.UTC,
+		rateLimiters:    newRateLimiters(cfg),
+	}
+}
+
+// Sunday 18 October 2026 in New York, after both the digest and the reminder are due.
+var (
+	testEmailLocation, _ = time.LoadLocation("America/New_York")
+	testSundayEvening    = time.Date(2026, time.October, 18, 20, 30, 0, 0, testEmailLocation)
+)
+
+func TestReminderSentOncePerDay(t *testing.T) {
+	smtp := newFakeSMTP(t)
+	app := newEmailTestApp(t, smtp)
+
+	// Not yet due.
+	err := app.sendReminderIfDue(testSundayEvening.Add(-time.Hour), testEmailLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(smtp.received()); n != 0 {
+		t.Fatalf("%d reminders sent before 20:00; want 0", n)
+	}
+
+	// Several schedulers (or restarts) racing for the same day send one email.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := app.sendReminderIfDue(testSundayEvening, testEmailLocation)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	err = app.sendReminderIfDue(testSundayEvening.Add(3*time.Hour), testEmailLocation) // 23:30, same day
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := smtp.received()
+	if len(msgs) != 1 {
+		t.Fatalf("%d reminders sent for one day; want 1", len(msgs))
+	}
+	if to := msgs[0].Header.Get("To"); to != testRecipient {
+		t.Errorf("reminder sent to %q; want %q", to, testRecipient)
+	}
+
+	// The next local day gets its own.
+	err = app.sendReminderIfDue(testSundayEvening.AddDate(0, 0, 1), testEmailLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(smtp.received()); n != 2 {
+		t.Fatalf("%d reminders sent over two days; want 2", n)
+	}
+}
+
+func TestReminderRetriedAfterFailedSend(t *testing.T) {
+	smtp := newFakeSMTP(t)
+	app := newEmailTestApp(t, smtp)
+
+	smtp.rejectNext(1)
+	err := app.sendReminderIfDue(testSundayEvening, testEmailLocation)
+	if err == nil {
+		t.Fatal("sendReminderIfDue succeeded though the server refused the message")
+	}
+	if n := len(smtp.received()); n != 0 {
+		t.Fatalf("%d reminders delivered; want 0", n)
+	}
+
+	// The claim was given back, so the next tick sends it.
+	err = app.sendReminderIfDue(testSundayEvening.Add(time.Minute), testEmailLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(smtp.received()); n != 1 {
+		t.Fatalf("%d reminders delivered after the retry; want 1", n)
+	}
+}
+
+func TestDigestSentOncePerWeek(t *testing.T) {
+	smtp := newFakeSMTP(t)
+	app := newEmailTestApp(t, smtp)
+
+	for _, now := range []time.Time{
+		testSundayEvening.AddDate(0, 0, -1), // Saturday: not due
+		testSundayEvening,
+		testSundayEvening.Add(time.Hour),
+	} {
+		err := app.sendDigestIfDue(now, testEmailLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(smtp.received()); n != 1 {
+		t.Fatalf("%d digests sent for one week; want 1", n)
+	}
+
+	// A failed send is retried on the following tick.
+	nextSunday := testSundayEvening.AddDate(0, 0, 7)
+	smtp.rejectNext(1)
+	err := app.sendDigestIfDue(nextSunday, testEmailLocation)
+	if err == nil {
+		t.Fatal("sendDigestIfDue succeeded though the server refused the message")
+	}
+	err = app.sendDigestIfDue(nextSunday.Add(time.Minute), testEmailLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(smtp.received()); n != 2 {
+		t.Fatalf("%d digests sent over two weeks; want 2", n)
+	}
+}
+
+// TestDigestOneClickUnsubscribe checks the RFC 8058 headers on the digest and
+// that a mail client's POST to the link stops the next one.
+func TestDigestOneClickUnsubscribe(t *testing.T) {
+	smtp := newFakeSMTP(t)
+	app := newEmailTestApp(t, smtp)
+
+	err := app.sendDigestIfDue(testSundayEvening, testEmailLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := smtp.received()
+	if len(msgs) != 1 {
+		t.Fatalf("%d digests sent; want 1", len(msgs))
+	}
+
+	if got := msgs[0].Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q; want %q", got, "List-Unsubscribe=One-Click")
+	}
+	header := msgs[0].Header.Get("List-Unsubscribe")
+	if !strings.HasPrefix(header, "<https://") || !strings.HasSuffix(header, ">") {
+		t.Fatalf("List-Unsubscribe = %q; want one HTTPS URL in angle brackets", header)
+	}
+	link, err := url.Parse(strings.Trim(header, "<>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Path != "/unsubscribe" || link.Query().Get("list") != digestKind || link.Query().Get("email") != testRecipient {
+		t.Fatalf("List-Unsubscribe = %q; want the signed unsubscribe link for the digest", header)
+	}
+
+	// What a mail client sends (RFC 8058, section 3.2): a POST to the link itself.
+	routes := app.routes()
+	post := func(target string) int {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader("List-Unsubscribe=One-Click"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	forged := *link
+	q := forged.Query()
+	q.Set("email", "someone-else@example.com")
+	forged.RawQuery = q.Encode()
+	if code := post(forged.RequestURI()); code != http.StatusBadRequest {
+		t.Errorf("one-click POST with another address: status %d; want %d", code, http.StatusBadRequest)
+	}
+	if code := post(link.RequestURI()); code != http.StatusOK {
+		t.Fatalf("one-click POST: status %d; want %d", code, http.StatusOK)
+	}
+
+	err = app.sendDigestIfDue(testSundayEvening.AddDate(0, 0, 7), testEmailLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(smtp.received()); n != 1 {
+		t.Fatalf("%d digests sent after unsubscribing; want 1", n)
+	}
+}
+
+// TestRecipientVerification checks that an unverified recipient gets one
+// verification email a day instead of reminders, until the link is followed.
+func TestRecipientVerification(t *testing.T) {
+	smtp := newFakeSMTP(t)
+	app := newEmailTestApp(t, smtp)
+	const other = "typo@example.com"
+	app.config.Reminders.Recipient = other
+
+	for _, now := range []time.Time{testSundayEvening, testSundayEvening.Add(time.Hour)} {
+		err := app.sendReminderIfDue(now, testEmailLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := smtp.received()
+	if len(msgs) != 1 {
+		t.Fatalf("%d emails sent to an unverified address in one day; want 1", len(msgs))
+	}
+	if subject := msgs[0].Header.Get("Subject"); !strings.Contains(subject, "Confirm your email") {
+		t.Fatalf("first email to an unverified address has subject %q; want the verification email", subject)
+	}
+	raw, err := io.ReadAll(quotedprintable.NewReader(msgs[0].Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := regexp.MustCompile(`/email/verify\?token=([A-Z2-7]{26})`).FindSubmatch(raw)
+	if match == nil {
+		t.Fatalf("no verification link in:\n%s", raw)
+	}
+	token := string(match[1])
+
+	routes := app.routes()
+	request := func(method, target, body string) int {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	// Opening the link only asks for confirmation.
+	if code := request(http.MethodGet, "/email/verify?token="+token, ""); code != http.StatusOK {
+		t.Errorf("GET verification link: status %d; want %d", code, http.StatusOK)
+	}
+	if code := request(http.MethodPost, "/email/verify", "token="+strings.Repeat("A", 26)); code != http.StatusUnprocessableEntity {
+		t.Errorf("POST with an unknown token: status %d; want %d", code, http.StatusUnprocessableEntity)
+	}
+	if code := request(http.MethodPost, "/email/verify", "token="+token); code != http.StatusOK {
+		t.Fatalf("POST verification: status %d; want %d", code, http.StatusOK)
+	}
+	if code := request(http.MethodPost, "/email/verify", "token="+token); code != http.StatusUnprocessableEntity {
+		t.Errorf("second POST with the same token: status %d; want %d", code, http.StatusUnprocessableEntity)
+	}
+
+	// Verified: the next day's reminder goes out as usual.
+	err = app.sendReminderIfDue(testSundayEvening.AddDate(0, 0, 1), testEmailLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs = smtp.received()
+	if len(msgs) != 2 || msgs[1].Header.Get("To") != other || strings.Contains(msgs[1].Header.Get("Subject"), "Confirm") {
+		t.Fatalf("after verifying, got %d emails; want the verification email and one reminder", len(msgs))
+	}
+}
@@ -0,0 +1,182 @@
+// cmd/web/encryption.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/blobstore"
+	"github.com/mickali02/mood-notes-app/internal/data"
+	"github.com/mickali02/mood-notes-app/internal/vault"
+)
+
+// reencryptBatchSize is how many notes the re-encryption job handles per query.
+const reencryptBatchSize = 100
+
+// openNoteCipher sets up note encryption from the config, or returns nil when no
+// master keys are configured. It rewraps data keys and rotates the active one
+// as needed before the server starts.
+func openNoteCipher(cfg config, db *sql.DB, logger *slog.Logger) (*data.NoteCipher, error) {
+	if !cfg.encryptionEnabled() {
+		return nil, nil
+	}
+	keyring, err := vault.ParseKeyring(cfg.Encryption.MasterKeys)
+	if err != nil {
+		return nil, err
+	}
+	cipher := &data.NoteCipher{
+		DB:           db,
+		QueryTimeout: cfg.DB.QueryTimeout,
+		Keyring:      keyring,
+		MaxKeyAge:    cfg.Encryption.DataKeyMaxAge,
+	}
+	err = cipher.Setup()
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("note encryption enabled", "master_key", keyring.CurrentID(), "data_key", cipher.ActiveKeyID())
+	return cipher, nil
+}
+
+// runReencryption moves every note and attachment onto the active data key in
+// small batches: plaintext ones from before encryption was enabled, and ones
+// sealed with a rotated-out key. Afterwards, data keys nothing uses any more are deleted.
+// It runs once at startup; anything it misses is picked up on the next start.
+func (app *application) runReencryption(done <-chan struct{}) {
+	total := 0
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		n, err := app.moodNotes.ReencryptNotes(reencryptBatchSize)
+		if err != nil {
+			app.logger.Error("re-encrypting notes failed", "error", err, "reencrypted", total)
+			return
+		}
+		total += n
+		if n == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond) // Leave room for user requests
+	}
+
+	moved, err := app.reencryptAttachments(done)
+	if err != nil {
+		app.logger.Error("re-encrypting attachments failed", "error", err, "reencrypted", moved)
+		return
+	}
+
+	deleted, err := app.moodNotes.Cipher.DeleteUnusedDataKeys()
+	if err != nil {
+		app.logger.Error("deleting unused data keys failed", "error", err)
+		return
+	}
+	if total > 0 || moved > 0 || deleted > 0 {
+		app.logger.Info("note re-encryption finished", "reencrypted", total, "attachments", moved, "deleted_keys", deleted)
+	}
+}
+
+// reencryptAttachments seals the blobs of attachments that aren't on the active
+// data key again, and returns how many attachments it moved.
+func (app *application) reencryptAttachments(done <-chan struct{}) (int, error) {
+	total := 0
+	for {
+		select {
+		case <-done:
+			return total, nil
+		default:
+		}
+
+		pending, err := app.attachments.NotOnKey(app.moodNotes.Cipher.ActiveKeyID(), reencryptBatchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(pending) == 0 {
+			return total, nil
+		}
+		for _, a := range pending {
+			err = app.reencryptAttachment(a)
+			if err != nil {
+				return total, fmt.Errorf("attachment %d: %w", a.ID, err)
+			}
+			total++
+		}
+		time.Sleep(100 * time.Millisecond) // Leave room for user requests
+	}
+}
+
+// reencryptAttachment copies an attachment's blobs to new keys, sealed with the
+// active data key, points the record at them and deletes the old blobs. Blobs are
+// never rewritten in place, so the record always names blobs its key can open.
+func (app *application) reencryptAttachment(a *data.Attachment) error {
+	ctx := context.Background()
+	blobKey, err := blobstore.NewKey("attachments")
+	if err != nil {
+		return err
+	}
+	thumbKey := ""
+	if a.ThumbKey != "" {
+		thumbKey = blobKey + "-thumb"
+	}
+	deleteBlobs := func(keys ...string) {
+		for _, key := range keys {
+			if key == "" {
+				continue
+			}
+			err := app.blobs.Delete(ctx, key)
+			if err != nil {
+				app.logger.Error("deleting attachment blob failed", "key", key, "error", err)
+			}
+		}
+	}
+
+	keyID, err := app.resealBlob(ctx, a.BlobKey, a.KeyID, blobKey)
+	if err != nil {
+		deleteBlobs(blobKey)
+		return err
+	}
+	if thumbKey != "" {
+		thumbKeyID, err := app.resealBlob(ctx, a.ThumbKey, a.KeyID, thumbKey)
+		if err == nil && thumbKeyID != keyID {
+			err = errors.New("data key rotated while re-encrypting attachment")
+		}
+		if err != nil {
+			deleteBlobs(blobKey, thumbKey)
+			return err
+		}
+	}
+
+	err = app.attachments.Rekey(a, blobKey, thumbKey, keyID)
+	if err != nil {
+		deleteBlobs(blobKey, thumbKey)
+		if err.Error() == "attachment not found" {
+			return nil // Deleted or moved meanwhile
+		}
+		return err
+	}
+	deleteBlobs(a.BlobKey, a.ThumbKey)
+	return nil
+}
+
+// resealBlob copies the blob under key, sealed with data key keyID (0 for none),
+// to newKey, sealed with the active data key, and returns that key's ID.
+func (app *application) resealBlob(ctx context.Context, key string, keyID int64, newKey string) (int64, error) {
+	src, err := app.openSealedBlob(ctx, key, keyID)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	return app.putSealedBlob(ctx, newKey, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
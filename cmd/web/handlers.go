@@ -2,10 +2,26 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/mickali02/mood-notes-app/internal/attachments"
+	"github.com/mickali02/mood-notes-app/internal/blobstore"
 	"github.com/mickali02/mood-notes-app/internal/data"
 	"github.com/mickali02/mood-notes-app/internal/validator"
 )
@@ -15,7 +31,7 @@ import (
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
 	method := r.Method
 	uri := r.URL.RequestURI()
-	app.logger.Error("internal server error", "method", method, "uri", uri, "error", err)
+	app.logger.Error("internal server error", "method", method, "uri", uri, "request_id", requestID(r), "error", err)
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
@@ -31,14 +47,90 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	if td == nil {
 		td = newTemplateData()
 	}
+	// Per-request values every layout needs.
+	td.CSPNonce = cspNonce(r)
+	var err error
+	if !td.Public { // Share link visitors get no session for the API
+		td.CSRFToken, err = app.browserSession(w, r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	td.localize(app.location(r))
+	td.Now = time.Now().In(td.Location)
 	// td.Flash = app.sessionManager.PopString(r.Context(), "flash") // Add later
-	err := app.renderTemplate(w, status, page, td)
+	err = app.renderTemplate(w, status, page, td)
 	if err != nil {
 		app.logger.Error("error rendering template", "template", page, "error", err)
 		app.serverError(w, r, err)
 	}
 }
 
+// auditActor names whoever is using the app in the audit log. There is a single
+// owner until the app has accounts.
+const auditActor = "owner"
+
+// shareVisitorActor names whoever opens a share link in the audit log.
+const shareVisitorActor = "share link visitor"
+
+// apiClientActor names whoever presents an API token that matches none.
+const apiClientActor = "API client"
+
+// auditInfo describes the request making a change, for the audit log. Changes
+// made with an API token name it, so the log shows which script made them.
+func (app *application) auditInfo(r *http.Request) data.AuditInfo {
+	userAgent := r.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	actor := auditActor
+	if token := apiToken(r); token != nil {
+		actor = fmt.Sprintf("%s (API token %d)", auditActor, token.ID)
+	}
+	return data.AuditInfo{
+		Actor:     actor,
+		IP:        app.clientIP(r),
+		UserAgent: userAgent,
+		RequestID: requestID(r),
+	}
+}
+
+// recordAuthEvent writes a passcode or token check to the audit log. These
+// events change nothing, so a failure to record one is logged rather than
+// turned into an error page.
+func (app *application) recordAuthEvent(info data.AuditInfo, action string, targetID int64) {
+	err := app.auditEvents.Record(info, action, targetID)
+	if err != nil {
+		app.logger.Error("recording auth event", "action", action, "request_id", info.RequestID, "error", err)
+	}
+}
+
+// noteLimits returns the configured mood note size limits for validation.
+func (app *application) noteLimits() data.MoodNoteLimits {
+	return data.MoodNoteLimits{
+		TitleMaxLength:   app.config.Limits.TitleMaxLength,
+		ContentMaxLength: app.config.Limits.ContentMaxLength,
+	}
+}
+
+// occurredAtLayout matches the value of an <input type="datetime-local">.
+const occurredAtLayout = "2006-01-02T15:04"
+
+// parseOccurredAt reads the entry date from a form, interpreting it in the writer's
+// timezone. Problems are recorded on v under "occurred_at" and a zero time is returned.
+func parseOccurredAt(value string, loc *time.Location, v *validator.Validator) time.Time {
+	if !validator.NotBlank(value) {
+		return time.Time{} // ValidateMoodNote reports the missing value
+	}
+	t, err := time.ParseInLocation(occurredAtLayout, value, loc)
+	if err != nil {
+		v.AddError("occurred_at", "must be a valid date and time")
+		return time.Time{}
+	}
+	return t
+}
+
 // --- Route Handlers ---
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
@@ -48,9 +140,55 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
-	data := newTemplateData()
-	data.Notes = notes
-	app.render(w, r, http.StatusOK, "home.tmpl", data)
+	// Group by the reader's local day (or ?group=week / ?group=month).
+	groupBy := data.ParsePeriod(r.URL.Query().Get("group"))
+
+	td := newTemplateData()
+	td.Notes = notes
+	td.GroupBy = groupBy
+	td.NoteGroups = data.GroupNotes(notes, app.location(r), groupBy)
+	app.render(w, r, http.StatusOK, "home.tmpl", td)
+}
+
+// showMoodNote displays a single note read-only, with links to its neighbours.
+func (app *application) showMoodNote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w) // Invalid ID format
+		return
+	}
+	note, err := app.moodNotes.Get(id)
+	if err != nil {
+		if err.Error() == "mood note record not found" || err.Error() == "invalid mood note ID provided" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.renderMoodNote(w, r, http.StatusOK, note, AttachmentForm{})
+}
+
+// renderMoodNote shows a note's detail page with its attachments, its neighbours
+// and the attachment upload form (with any errors from the last upload).
+func (app *application) renderMoodNote(w http.ResponseWriter, r *http.Request, status int, note *data.MoodNote, form AttachmentForm) {
+	prev, next, err := app.moodNotes.GetNeighbours(note)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	attached, err := app.attachments.GetForNote(note.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	td := newTemplateData()
+	td.Note = note
+	td.PrevNote = prev
+	td.NextNote = next
+	td.Attachments = attached
+	td.Form = form
+	app.render(w, r, status, "note_view.tmpl", td)
 }
 
 func (app *application) showMoodNoteForm(w http.ResponseWriter, r *http.Request) {
@@ -58,7 +196,39 @@ func (app *application) showMoodNoteForm(w http.ResponseWriter, r *http.Request)
 	td := newTemplateData()
 
 	if idStr == "" { // CREATE
-		td.Form = MoodNoteCreateForm{}
+		// Default the entry date to "now" in the writer's timezone.
+		form := MoodNoteCreateForm{
+			OccurredAt: time.Now().In(app.location(r)).Format(occurredAtLayout),
+		}
+		// ?template= pre-fills the form from a template or today's prompt.
+		if name := r.URL.Query().Get("template"); name != "" {
+			title, content, err := app.noteTemplateText(r, name)
+			if err != nil {
+				if err.Error() == "note template not found" {
+					app.notFound(w)
+				} else {
+					app.serverError(w, r, err)
+				}
+				return
+			}
+			form.Title, form.Content = title, content
+		}
+		draft, err := app.drafts.Get(auditActor, data.DraftSlotNew)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if draft != nil && r.URL.Query().Get("draft") == "restore" {
+			form.Title, form.Content, form.OccurredAt = draft.Title, draft.Content, draft.OccurredAt
+		} else {
+			td.Draft = draft // Offer to restore it
+		}
+		td.Form = form
+		err = app.addTemplatePicker(r, td)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 		app.render(w, r, http.StatusOK, "note_form.tmpl", td)
 	} else { // EDIT
 		id, err := strconv.ParseInt(idStr, 10, 64)
@@ -79,12 +249,27 @@ func (app *application) showMoodNoteForm(w http.ResponseWriter, r *http.Request)
 			return
 		}
 		// Populate form for editing
-		td.Form = MoodNoteEditForm{
-			ID:      note.ID,
-			Title:   note.Title,
-			Content: note.Content,
-			Version: note.Version,
+		form := MoodNoteEditForm{
+			ID:         note.ID,
+			Title:      note.Title,
+			Content:    note.Content,
+			OccurredAt: note.OccurredAt.In(app.location(r)).Format(occurredAtLayout),
+			Version:    note.Version,
 		}
+		draft, err := app.drafts.Get(auditActor, data.DraftSlot(note.ID))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if draft != nil && r.URL.Query().Get("draft") == "restore" {
+			// Keep the version the draft started from, so saving it over a newer
+			// edit is reported as a conflict rather than silently overwriting it.
+			form.Title, form.Content, form.OccurredAt = draft.Title, draft.Content, draft.OccurredAt
+			form.Version = draft.BaseVersion
+		} else {
+			td.Draft = draft
+		}
+		td.Form = form
 		td.Note = note // Pass the full note data too
 		app.render(w, r, http.StatusOK, "note_form.tmpl", td)
 	}
@@ -102,23 +287,39 @@ func (app *application) createMoodNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	form := MoodNoteCreateForm{
-		Title:     r.PostForm.Get("title"),
-		Content:   r.PostForm.Get("content"),
-		Validator: *validator.NewValidator(),
+		Title:      r.PostForm.Get("title"),
+		Content:    r.PostForm.Get("content"),
+		OccurredAt: r.PostForm.Get("occurred_at"),
+		Validator:  *validator.NewValidator(),
+	}
+	occurredAt := parseOccurredAt(form.OccurredAt, app.location(r), &form.Validator)
+	if !validator.NotBlank(form.OccurredAt) {
+		occurredAt = time.Now() // Not backdated: the entry is about right now
 	}
-	noteToValidate := &data.MoodNote{Title: form.Title, Content: form.Content}
+	noteToValidate := &data.MoodNote{Title: form.Title, Content: form.Content, OccurredAt: occurredAt}
 	// Use the standalone validation function from the data package
-	data.ValidateMoodNote(&form.Validator, noteToValidate)
+	data.ValidateMoodNote(&form.Validator, noteToValidate, app.noteLimits())
 
 	if !form.ValidData() {
 		td := newTemplateData()
 		td.Form = form // Pass form with errors back
+		err := app.addTemplatePicker(r, td)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 		app.render(w, r, http.StatusUnprocessableEntity, "note_form.tmpl", td)
 		return
 	}
-	// Call the correct model method
-	noteToInsert := &data.MoodNote{Title: form.Title, Content: form.Content}
-	err = app.moodNotes.Insert(noteToInsert)
+	// Save the note and drop its draft together, so neither is left behind alone.
+	noteToInsert := &data.MoodNote{Title: form.Title, Content: form.Content, OccurredAt: occurredAt}
+	err = app.models.WithTx(r.Context(), func(tx data.Models) error {
+		err := tx.MoodNotes.Insert(noteToInsert, app.auditInfo(r))
+		if err != nil {
+			return err
+		}
+		return tx.Drafts.Discard(auditActor, data.DraftSlotNew)
+	})
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -149,15 +350,17 @@ func (app *application) updateMoodNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	form := MoodNoteEditForm{
-		ID:        id,
-		Title:     r.PostForm.Get("title"),
-		Content:   r.PostForm.Get("content"),
-		Version:   version,
-		Validator: *validator.NewValidator(),
+		ID:         id,
+		Title:      r.PostForm.Get("title"),
+		Content:    r.PostForm.Get("content"),
+		OccurredAt: r.PostForm.Get("occurred_at"),
+		Version:    version,
+		Validator:  *validator.NewValidator(),
 	}
-	noteToValidate := &data.MoodNote{ID: form.ID, Title: form.Title, Content: form.Content, Version: form.Version}
+	occurredAt := parseOccurredAt(form.OccurredAt, app.location(r), &form.Validator)
+	noteToValidate := &data.MoodNote{ID: form.ID, Title: form.Title, Content: form.Content, OccurredAt: occurredAt, Version: form.Version}
 	// Use the standalone validation function
-	data.ValidateMoodNote(&form.Validator, noteToValidate)
+	data.ValidateMoodNote(&form.Validator, noteToValidate, app.noteLimits())
 
 	if !form.ValidData() {
 		td := newTemplateData()
@@ -165,9 +368,15 @@ func (app *application) updateMoodNote(w http.ResponseWriter, r *http.Request) {
 		app.render(w, r, http.StatusUnprocessableEntity, "note_form.tmpl", td)
 		return
 	}
-	// Call the correct model method
-	noteToUpdate := &data.MoodNote{ID: form.ID, Title: form.Title, Content: form.Content, Version: form.Version}
-	err = app.moodNotes.Update(noteToUpdate)
+	// Save the note and drop its draft together, as in createMoodNote.
+	noteToUpdate := &data.MoodNote{ID: form.ID, Title: form.Title, Content: form.Content, OccurredAt: occurredAt, Version: form.Version}
+	err = app.models.WithTx(r.Context(), func(tx data.Models) error {
+		err := tx.MoodNotes.Update(noteToUpdate, app.auditInfo(r))
+		if err != nil {
+			return err
+		}
+		return tx.Drafts.Discard(auditActor, data.DraftSlot(id))
+	})
 	if err != nil {
 		// ** CORRECTED ERROR CHECK **
 		// Check the specific error messages returned by the model's Update method
@@ -214,7 +423,7 @@ func (app *application) deleteMoodNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Call the correct model method
-	err = app.moodNotes.Delete(id)
+	err = app.moodNotes.Delete(id, app.auditInfo(r))
 	if err != nil {
 		// ** CORRECTED ERROR CHECK **
 		// Check the specific error message returned by the model's Delete method
@@ -228,4 +437,1262 @@ func (app *application) deleteMoodNote(w http.ResponseWriter, r *http.Request) {
 	}
 	// Success
 	http.Redirect(w, r, "/", http.StatusSeeOther)
-}
\ No newline at end of file
+}
+
+// previewMoodNote renders submitted Markdown as sanitised HTML for the live preview
+// on the note form. It returns an HTML fragment, not a full page.
+func (app *application) previewMoodNote(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	content := r.PostForm.Get("content")
+	if !validator.MaxLength(content, app.config.Limits.ContentMaxLength) {
+		app.clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+	html, err := noteRenderer.Render(content)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(html))
+}
+
+// --- Drafts ---
+
+// draftFormURL returns the note form a draft slot belongs to.
+func draftFormURL(slot string) string {
+	if slot == data.DraftSlotNew {
+		return "/note/new"
+	}
+	return "/note/edit/" + slot
+}
+
+// saveDraft stores the note form as it is right now. main.js calls it every few
+// seconds while the form has unsaved changes; it answers 204 No Content.
+func (app *application) saveDraft(w http.ResponseWriter, r *http.Request) {
+	slot := r.PathValue("slot")
+	_, err := data.ParseDraftSlot(slot)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	draft := &data.Draft{
+		Slot:       slot,
+		Title:      r.PostForm.Get("title"),
+		Content:    r.PostForm.Get("content"),
+		OccurredAt: r.PostForm.Get("occurred_at"),
+	}
+	if slot != data.DraftSlotNew {
+		draft.BaseVersion, err = strconv.Atoi(r.PostForm.Get("version"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	v := validator.NewValidator()
+	data.ValidateDraft(v, draft, app.noteLimits())
+	if !v.ValidData() {
+		app.clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	err = app.drafts.Save(auditActor, draft, app.config.Drafts.TTL)
+	if err != nil {
+		if err.Error() == "mood note record not found" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// discardDraft deletes a draft from the "restore draft?" banner and goes back to the form.
+func (app *application) discardDraft(w http.ResponseWriter, r *http.Request) {
+	slot := r.PathValue("slot")
+	_, err := data.ParseDraftSlot(slot)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+	err = app.drafts.Discard(auditActor, slot)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, draftFormURL(slot), http.StatusSeeOther)
+}
+
+// --- Attachments ---
+
+// attachmentTypesHint lists the accepted formats for error messages.
+const attachmentTypesHint = "must be an image (JPEG, PNG, GIF or WebP) or an audio clip (MP3, M4A, Ogg, WAV or WebM)"
+
+// extendTransferDeadlines gives an attachment upload or download longer than the
+// server's read and write timeouts, which are sized for ordinary pages.
+func (app *application) extendTransferDeadlines(w http.ResponseWriter, r *http.Request) {
+	deadline := time.Now().Add(app.config.Attachments.TransferTimeout)
+	rc := http.NewResponseController(w)
+	err := rc.SetReadDeadline(deadline)
+	if err == nil {
+		err = rc.SetWriteDeadline(deadline)
+	}
+	if err != nil {
+		app.logger.Warn("could not extend attachment transfer deadline", "request_id", requestID(r), "error", err)
+	}
+}
+
+// attachmentFilename cleans up the name a file was uploaded with. It is only
+// shown and used in Content-Disposition, never as a path on disk.
+func attachmentFilename(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	name = name[strings.LastIndexAny(name, `/\`)+1:] // Some browsers send the full path
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > 200 {
+		name = string(runes[len(runes)-200:]) // Keep the extension
+	}
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	return name
+}
+
+// uploadAttachment stores an image or audio clip sent as the "file" field of a
+// multipart form and attaches it to the note. The type is sniffed from the
+// file's bytes. Audio is streamed straight to the blob store; images are read
+// into memory (at most Attachments.MaxSize) to strip location data and make a thumbnail.
+func (app *application) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	note := app.noteFromPath(w, r)
+	if note == nil {
+		return
+	}
+	app.extendTransferDeadlines(w, r)
+
+	maxSize := app.config.Attachments.MaxSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+64<<10) // Room for the multipart headers
+	form := AttachmentForm{Validator: *validator.NewValidator()}
+	tooLarge := fmt.Sprintf("must not be larger than %s", fileSize(maxSize))
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				form.AddError("file", tooLarge)
+				app.renderMoodNote(w, r, http.StatusRequestEntityTooLarge, note, form)
+			case errors.Is(err, io.EOF):
+				form.AddError("file", "must be provided")
+				app.renderMoodNote(w, r, http.StatusUnprocessableEntity, note, form)
+			default:
+				app.clientError(w, http.StatusBadRequest)
+			}
+			return
+		}
+		if part.FormName() == "file" {
+			break
+		}
+	}
+	defer part.Close()
+
+	attachment, err := app.storeAttachment(r, note.ID, part, maxSize)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr) || err.Error() == "attachment too large":
+			form.AddError("file", tooLarge)
+			status = http.StatusRequestEntityTooLarge
+		case err.Error() == "attachment is empty":
+			form.AddError("file", "must not be empty")
+		case errors.Is(err, attachments.ErrUnsupportedType):
+			form.AddError("file", attachmentTypesHint)
+		case errors.Is(err, attachments.ErrBadImage):
+			form.AddError("file", "could not be read as an image")
+		case errors.Is(err, attachments.ErrImageTooLarge):
+			form.AddError("file", fmt.Sprintf("must not have more than %d megapixels", attachments.MaxPixels/1_000_000))
+		case err.Error() == "mood note record not found":
+			app.notFound(w) // Deleted while the file was uploading
+			return
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+		app.renderMoodNote(w, r, status, note, form)
+		return
+	}
+
+	app.logger.Info("attachment uploaded", "note_id", note.ID, "attachment_id", attachment.ID, "kind", attachment.Kind, "bytes", attachment.Size)
+	http.Redirect(w, r, fmt.Sprintf("/note/%d#attachments", note.ID), http.StatusSeeOther)
+}
+
+// storeAttachment checks an uploaded file, writes its blobs and records it. On
+// failure nothing is left behind.
+func (app *application) storeAttachment(r *http.Request, noteID int64, part *multipart.Part, maxSize int64) (*data.Attachment, error) {
+	body := bufio.NewReaderSize(part, attachments.SniffLen)
+	head, err := body.Peek(attachments.SniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if len(head) == 0 {
+		return nil, errors.New("attachment is empty")
+	}
+	kind, contentType, err := attachments.Sniff(head)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &data.Attachment{
+		NoteID:      noteID,
+		Kind:        kind,
+		ContentType: contentType,
+		Filename:    attachmentFilename(part.FileName()),
+	}
+	a.BlobKey, err = blobstore.NewKey("attachments")
+	if err != nil {
+		return nil, err
+	}
+
+	// One byte over the limit is enough to know the file is too large.
+	limited := io.LimitReader(body, maxSize+1)
+	ctx := r.Context()
+	var stored []string
+	cleanUp := func() {
+		for _, key := range stored {
+			err := app.blobs.Delete(context.Background(), key)
+			if err != nil {
+				app.logger.Error("deleting attachment blob failed", "key", key, "error", err)
+			}
+		}
+	}
+
+	// Both blobs are sealed with the active data key; see putSealedBlob.
+	if kind == attachments.KindImage {
+		raw, err := io.ReadAll(limited)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(raw)) > maxSize {
+			return nil, errors.New("attachment too large")
+		}
+		img, err := attachments.PrepareImage(raw, contentType)
+		if err != nil {
+			return nil, err
+		}
+		a.ThumbKey = a.BlobKey + "-thumb"
+		a.Size, a.Width, a.Height = int64(len(img.Data)), img.Width, img.Height
+
+		a.KeyID, err = app.putSealedBlob(ctx, a.BlobKey, writeBytes(img.Data))
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, a.BlobKey)
+		thumbKeyID, err := app.putSealedBlob(ctx, a.ThumbKey, writeBytes(img.Thumbnail))
+		if err == nil && thumbKeyID != a.KeyID {
+			err = errors.New("data key rotated while storing attachment")
+		}
+		stored = append(stored, a.ThumbKey)
+		if err != nil {
+			cleanUp()
+			return nil, err
+		}
+	} else {
+		a.KeyID, err = app.putSealedBlob(ctx, a.BlobKey, func(w io.Writer) error {
+			n, err := io.Copy(w, limited)
+			a.Size = n
+			return err
+		})
+		stored = append(stored, a.BlobKey) // A failed Put stores nothing, so deleting is harmless
+		if err != nil {
+			cleanUp()
+			return nil, err
+		}
+		if a.Size > maxSize {
+			cleanUp()
+			return nil, errors.New("attachment too large")
+		}
+	}
+
+	err = app.attachments.Insert(a, app.auditInfo(r))
+	if err != nil {
+		cleanUp()
+		return nil, err
+	}
+	return a, nil
+}
+
+// attachmentFromPath loads the attachment named by the {id} path value, writing
+// a 404 (and returning nil) if there isn't one.
+func (app *application) attachmentFromPath(w http.ResponseWriter, r *http.Request) *data.Attachment {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+	a, err := app.attachments.Get(id)
+	if err != nil {
+		if err.Error() == "attachment not found" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil
+	}
+	return a
+}
+
+// serveBlob sends one of an attachment's blobs with Range support, so audio can
+// be seeked and interrupted downloads resumed. Attachments never change once
+// stored, so browsers may cache them, but only privately.
+func (app *application) serveBlob(w http.ResponseWriter, r *http.Request, a *data.Attachment, key, contentType, filename string) {
+	app.extendTransferDeadlines(w, r)
+	blob, err := app.openSealedBlob(r.Context(), key, a.KeyID)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			app.logger.Error("attachment blob missing", "key", key)
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	defer blob.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	h.Set("Cache-Control", "private, max-age=31536000, immutable")
+	h.Set("ETag", `"`+path.Base(key)+`"`)
+	http.ServeContent(w, r, "", a.CreatedAt, blob)
+}
+
+// serveAttachment sends an attachment's file.
+func (app *application) serveAttachment(w http.ResponseWriter, r *http.Request) {
+	a := app.attachmentFromPath(w, r)
+	if a == nil {
+		return
+	}
+	app.serveBlob(w, r, a, a.BlobKey, a.ContentType, a.Filename)
+}
+
+// serveAttachmentThumbnail sends an image attachment's JPEG thumbnail.
+func (app *application) serveAttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	a := app.attachmentFromPath(w, r)
+	if a == nil {
+		return
+	}
+	if a.ThumbKey == "" {
+		app.notFound(w) // Audio has no thumbnail
+		return
+	}
+	app.serveBlob(w, r, a, a.ThumbKey, "image/jpeg", strings.TrimSuffix(a.Filename, filepath.Ext(a.Filename))+"-thumbnail.jpg")
+}
+
+// deleteAttachment removes an attachment from its note. The files are deleted
+// shortly after by the data job (see purgeDetachedAttachments).
+func (app *application) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	a := app.attachmentFromPath(w, r)
+	if a == nil {
+		return
+	}
+	err := app.attachments.Delete(a.ID, app.auditInfo(r))
+	if err != nil {
+		if err.Error() == "attachment not found" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.logger.Info("attachment deleted", "note_id", a.NoteID, "attachment_id", a.ID)
+	http.Redirect(w, r, fmt.Sprintf("/note/%d#attachments", a.NoteID), http.StatusSeeOther)
+}
+
+// --- Note Templates ---
+
+// promptTemplate is the ?template= value that starts an entry from today's prompt.
+const promptTemplate = "prompt"
+
+// promptOfTheDay returns today's reflective prompt, today being the writer's local date.
+func (app *application) promptOfTheDay(r *http.Request) string {
+	return app.journal.PromptOfTheDay(auditActor, time.Now().In(app.location(r)))
+}
+
+// noteTemplateText returns the title and content a new entry starts with for the
+// ?template= value name: the ID of one of the owner's templates, the slug of a
+// built-in one, or "prompt" for today's prompt. Unknown names give the error
+// "note template not found".
+func (app *application) noteTemplateText(r *http.Request, name string) (title, content string, err error) {
+	if name == promptTemplate {
+		return "", "> " + app.promptOfTheDay(r) + "\n\n", nil
+	}
+	if builtin := app.journal.Template(name); builtin != nil {
+		return builtin.Title, builtin.Content, nil
+	}
+	id, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return "", "", errors.New("note template not found")
+	}
+	t, err := app.noteTemplates.Get(id)
+	if err != nil {
+		return "", "", err
+	}
+	return t.Title, t.Content, nil
+}
+
+// addTemplatePicker adds the prompt of the day and the templates to choose from
+// to the new entry form.
+func (app *application) addTemplatePicker(r *http.Request, td *TemplateData) error {
+	templates, err := app.noteTemplates.GetAll()
+	if err != nil {
+		return err
+	}
+	td.NoteTemplates = templates
+	td.BuiltinTemplates = app.journal.Templates
+	td.Prompt = app.promptOfTheDay(r)
+	return nil
+}
+
+// noteTemplateFromPath loads the template named by the {id} path value, writing a
+// 404 (and returning nil) if there isn't one.
+func (app *application) noteTemplateFromPath(w http.ResponseWriter, r *http.Request) *data.NoteTemplate {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+	t, err := app.noteTemplates.Get(id)
+	if err != nil {
+		if err.Error() == "note template not found" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil
+	}
+	return t
+}
+
+// parseNoteTemplateForm reads and validates a submitted template form.
+func (app *application) parseNoteTemplateForm(r *http.Request, id int64) (NoteTemplateForm, bool) {
+	form := NoteTemplateForm{
+		ID:        id,
+		Name:      strings.TrimSpace(r.PostForm.Get("name")),
+		Title:     r.PostForm.Get("title"),
+		Content:   r.PostForm.Get("content"),
+		Validator: *validator.NewValidator(),
+	}
+	if id > 0 {
+		version, err := strconv.Atoi(r.PostForm.Get("version"))
+		if err != nil {
+			return form, false
+		}
+		form.Version = version
+	}
+	data.ValidateNoteTemplate(&form.Validator, &data.NoteTemplate{Name: form.Name, Title: form.Title, Content: form.Content}, app.noteLimits())
+	return form, true
+}
+
+// renderNoteTemplateForm shows the template form, e.g. with validation errors.
+func (app *application) renderNoteTemplateForm(w http.ResponseWriter, r *http.Request, status int, form NoteTemplateForm) {
+	td := newTemplateData()
+	td.Form = form
+	app.render(w, r, status, "template_form.tmpl", td)
+}
+
+// showNoteTemplates lists the owner's templates and the built-in ones.
+func (app *application) showNoteTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := app.noteTemplates.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	td := newTemplateData()
+	td.NoteTemplates = templates
+	td.BuiltinTemplates = app.journal.Templates
+	td.Prompt = app.promptOfTheDay(r)
+	app.render(w, r, http.StatusOK, "templates.tmpl", td)
+}
+
+// showNoteTemplateForm shows the form for a new template (optionally a copy of
+// the built-in template named by ?from=) or for editing one.
+func (app *application) showNoteTemplateForm(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("id") == "" { // CREATE
+		form := NoteTemplateForm{}
+		if slug := r.URL.Query().Get("from"); slug != "" {
+			builtin := app.journal.Template(slug)
+			if builtin == nil {
+				app.notFound(w)
+				return
+			}
+			form.Name, form.Title, form.Content = builtin.Name, builtin.Title, builtin.Content
+		}
+		app.renderNoteTemplateForm(w, r, http.StatusOK, form)
+		return
+	}
+	t := app.noteTemplateFromPath(w, r) // EDIT
+	if t == nil {
+		return
+	}
+	app.renderNoteTemplateForm(w, r, http.StatusOK, NoteTemplateForm{
+		ID:      t.ID,
+		Name:    t.Name,
+		Title:   t.Title,
+		Content: t.Content,
+		Version: t.Version,
+	})
+}
+
+// createNoteTemplate saves a new template.
+func (app *application) createNoteTemplate(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form, _ := app.parseNoteTemplateForm(r, 0)
+	if !form.ValidData() {
+		app.renderNoteTemplateForm(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+	t := &data.NoteTemplate{Name: form.Name, Title: form.Title, Content: form.Content}
+	err = app.noteTemplates.Insert(t, app.auditInfo(r))
+	if err != nil {
+		if err.Error() == "duplicate note template name" {
+			form.AddError("name", "you already have a template with this name")
+			app.renderNoteTemplateForm(w, r, http.StatusUnprocessableEntity, form)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	http.Redirect(w, r, "/settings/templates", http.StatusSeeOther)
+}
+
+// updateNoteTemplate saves changes to a template.
+func (app *application) updateNoteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form, ok := app.parseNoteTemplateForm(r, id)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if !form.ValidData() {
+		app.renderNoteTemplateForm(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+	t := &data.NoteTemplate{ID: id, Name: form.Name, Title: form.Title, Content: form.Content, Version: form.Version}
+	err = app.noteTemplates.Update(t, app.auditInfo(r))
+	if err != nil {
+		switch err.Error() {
+		case "duplicate note template name":
+			form.AddError("name", "you already have a template with this name")
+			app.renderNoteTemplateForm(w, r, http.StatusUnprocessableEntity, form)
+		case "note template not found or version mismatch":
+			latest, getErr := app.noteTemplates.Get(id)
+			if getErr != nil {
+				if getErr.Error() == "note template not found" {
+					app.notFound(w)
+				} else {
+					app.serverError(w, r, getErr)
+				}
+				return
+			}
+			form.Version = latest.Version
+			form.AddError("_conflict", "Edit Conflict: This template was changed somewhere else. Please review your changes and save again.")
+			app.renderNoteTemplateForm(w, r, http.StatusConflict, form)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	http.Redirect(w, r, "/settings/templates", http.StatusSeeOther)
+}
+
+// deleteNoteTemplate removes a template. Entries written from it are kept.
+func (app *application) deleteNoteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	err = app.noteTemplates.Delete(id, app.auditInfo(r))
+	if err != nil {
+		if err.Error() == "note template not found" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	http.Redirect(w, r, "/settings/templates", http.StatusSeeOther)
+}
+
+// --- Email Unsubscribe ---
+
+// unsubscribeLists are the optional emails a recipient can opt out of with a signed link.
+var unsubscribeLists = map[string]string{
+	digestKind: "the weekly digest",
+}
+
+// unsubscribeRequest reads and checks the list, email and sig parameters of an
+// unsubscribe link. ok is false if the list is unknown or the signature is wrong.
+func (app *application) unsubscribeRequest(r *http.Request) (form UnsubscribeForm, ok bool) {
+	form = UnsubscribeForm{
+		List:      r.Form.Get("list"),
+		Email:     r.Form.Get("email"),
+		Signature: r.Form.Get("sig"),
+	}
+	form.ListName, ok = unsubscribeLists[form.List]
+	if !ok {
+		return form, false
+	}
+	return form, app.verifySignature(form.Signature, "unsubscribe", form.List, form.Email)
+}
+
+// showUnsubscribe asks for confirmation. Unsubscribing on GET would let link
+// scanners in mail clients opt people out without them noticing.
+func (app *application) showUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form, ok := app.unsubscribeRequest(r)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	td := newTemplateData()
+	td.Form = form
+	app.render(w, r, http.StatusOK, "unsubscribe.tmpl", td)
+}
+
+// unsubscribe records the opt-out. It serves both the confirmation form and RFC 8058
+// one-click requests, which POST to the link itself (parameters in the query string).
+func (app *application) unsubscribe(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form, ok := app.unsubscribeRequest(r)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	err = app.unsubscribes.Insert(form.List, form.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logger.Info("recipient unsubscribed", "list", form.List, "recipient", form.Email)
+
+	form.Done = true
+	td := newTemplateData()
+	td.Form = form
+	app.render(w, r, http.StatusOK, "unsubscribe.tmpl", td)
+}
+
+// --- Email Verification ---
+
+// showVerifyEmail asks for confirmation, for the same reason as showUnsubscribe:
+// link scanners must not use up the single-use token.
+func (app *application) showVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	form := VerifyEmailForm{Token: r.URL.Query().Get("token"), Validator: *validator.NewValidator()}
+	data.ValidateTokenPlaintext(&form.Validator, form.Token)
+
+	td := newTemplateData()
+	td.Form = form
+	status := http.StatusOK
+	if !form.ValidData() {
+		status = http.StatusBadRequest
+	}
+	app.render(w, r, status, "verify_email.tmpl", td)
+}
+
+// verifyEmail uses the token and marks the address it was sent to as verified.
+func (app *application) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := VerifyEmailForm{Token: r.PostForm.Get("token"), Validator: *validator.NewValidator()}
+	data.ValidateTokenPlaintext(&form.Validator, form.Token)
+
+	if form.ValidData() {
+		err = app.models.WithTx(r.Context(), func(tx data.Models) error {
+			form.Email, err = tx.Tokens.Consume(data.TokenScopeEmailVerification, form.Token)
+			if err != nil {
+				return err
+			}
+			return tx.VerifiedEmails.Insert(form.Email)
+		})
+		if err != nil && err.Error() == "token not found" {
+			form.AddError("token", "is invalid, expired or already used")
+		} else if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	td := newTemplateData()
+	if !form.ValidData() {
+		td.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "verify_email.tmpl", td)
+		return
+	}
+	app.logger.Info("email address verified", "recipient", form.Email)
+
+	form.Done = true
+	td.Form = form
+	app.render(w, r, http.StatusOK, "verify_email.tmpl", td)
+}
+
+// --- Note Sharing ---
+
+// shareExpiries are the lifetimes offered for a new share link, in display order.
+var shareExpiries = []ShareExpiry{
+	{Value: "1d", Label: "1 day", Duration: 24 * time.Hour},
+	{Value: "7d", Label: "7 days", Duration: 7 * 24 * time.Hour},
+	{Value: "30d", Label: "30 days", Duration: 30 * 24 * time.Hour},
+	{Value: "never", Label: "Never"},
+}
+
+// noteFromPath loads the note named by the {id} path value, writing a 404 (and
+// returning nil) if there isn't one. Used by the sharing and attachment handlers.
+func (app *application) noteFromPath(w http.ResponseWriter, r *http.Request) *data.MoodNote {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+	note, err := app.moodNotes.Get(id)
+	if err != nil {
+		if err.Error() == "mood note record not found" || err.Error() == "invalid mood note ID provided" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil
+	}
+	return note
+}
+
+// renderNoteShares shows a note's live share links, the form for a new one and,
+// right after creation, the new link (the only time its token is available).
+func (app *application) renderNoteShares(w http.ResponseWriter, r *http.Request, status int, note *data.MoodNote, form NoteShareForm, created *data.NoteShare) {
+	shares, err := app.noteShares.GetAllForNote(note.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	td := newTemplateData()
+	td.Note = note
+	td.Shares = shares
+	td.Form = form
+	if created != nil {
+		td.ShareURL = app.config.BaseURL + "/s/" + created.Token
+	}
+	app.render(w, r, status, "note_share.tmpl", td)
+}
+
+// showNoteShares lists a note's share links.
+func (app *application) showNoteShares(w http.ResponseWriter, r *http.Request) {
+	note := app.noteFromPath(w, r)
+	if note == nil {
+		return
+	}
+	app.renderNoteShares(w, r, http.StatusOK, note, NoteShareForm{Expiry: "7d"}, nil)
+}
+
+// createNoteShare creates a read-only link to a note.
+func (app *application) createNoteShare(w http.ResponseWriter, r *http.Request) {
+	note := app.noteFromPath(w, r)
+	if note == nil {
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := NoteShareForm{
+		Expiry:    r.PostForm.Get("expiry"),
+		Passcode:  r.PostForm.Get("passcode"),
+		Validator: *validator.NewValidator(),
+	}
+
+	var expiresAt time.Time
+	validExpiry := false
+	for _, e := range shareExpiries {
+		if e.Value == form.Expiry {
+			validExpiry = true
+			if e.Duration > 0 {
+				expiresAt = time.Now().Add(e.Duration)
+			}
+		}
+	}
+	form.Check(validExpiry, "expiry", "must be one of the listed options")
+	data.ValidateSharePasscode(&form.Validator, form.Passcode)
+
+	if !form.ValidData() {
+		form.Passcode = "" // Never echo a secret back into the page
+		app.renderNoteShares(w, r, http.StatusUnprocessableEntity, note, form, nil)
+		return
+	}
+
+	share, err := app.noteShares.Insert(note.ID, expiresAt, form.Passcode, app.auditInfo(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logger.Info("note shared", "note_id", note.ID, "share_id", share.ID, "expires", expiresAt, "passcode", share.HasPasscode)
+	app.renderNoteShares(w, r, http.StatusCreated, note, NoteShareForm{Expiry: form.Expiry}, share)
+}
+
+// revokeNoteShare disables a share link straight away.
+func (app *application) revokeNoteShare(w http.ResponseWriter, r *http.Request) {
+	noteID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || noteID < 1 {
+		app.notFound(w)
+		return
+	}
+	shareID, err := strconv.ParseInt(r.PathValue("shareID"), 10, 64)
+	if err != nil || shareID < 1 {
+		app.notFound(w)
+		return
+	}
+	err = app.noteShares.Revoke(noteID, shareID, app.auditInfo(r))
+	if err != nil {
+		if err.Error() == "share link not found or already revoked" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.logger.Info("note share revoked", "note_id", noteID, "share_id", shareID)
+	http.Redirect(w, r, fmt.Sprintf("/note/share/%d", noteID), http.StatusSeeOther)
+}
+
+// showSharedNote is the public, read-only view behind a share link. Links with a
+// passcode show the passcode form first; views are only counted once the note is shown.
+func (app *application) showSharedNote(w http.ResponseWriter, r *http.Request) {
+	app.sharedNote(w, r, "")
+}
+
+// Passcode attempts allowed per share link, from any number of addresses, so a
+// short passcode can't be guessed by spreading tries over many clients.
+const (
+	shareUnlockAttempts = 5
+	shareUnlockWindow   = 15 * time.Minute
+)
+
+// newShareUnlockLimiter returns the limiter for passcode attempts, keyed by share ID.
+func newShareUnlockLimiter() *rateLimiter {
+	return newRateLimiter(shareUnlockAttempts/shareUnlockWindow.Seconds(), shareUnlockAttempts)
+}
+
+// unlockSharedNote checks the passcode for a protected share link.
+func (app *application) unlockSharedNote(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	app.sharedNote(w, r, r.PostForm.Get("passcode"))
+}
+
+// sharedNote renders the note behind the {token} path value, or its passcode
+// form when the share is protected and passcode doesn't unlock it.
+func (app *application) sharedNote(w http.ResponseWriter, r *http.Request, passcode string) {
+	token := r.PathValue("token")
+	share, err := app.noteShares.GetByToken(token)
+	if err != nil {
+		if err.Error() == "share link not found" {
+			app.notFound(w) // Same response for unknown, expired and revoked links
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	visitor := app.auditInfo(r)
+	visitor.Actor = shareVisitorActor
+
+	td := newTemplateData()
+	td.ShareToken = token
+	td.Public = true
+	if r.Method == http.MethodPost && share.HasPasscode {
+		ok, _, retryAfter := app.shareUnlocks.allow(strconv.FormatInt(share.ID, 10), time.Now())
+		if !ok {
+			form := SharedNoteUnlockForm{Validator: *validator.NewValidator()}
+			minutes := int(math.Ceil(retryAfter.Minutes()))
+			form.AddError("passcode", fmt.Sprintf("was tried too many times; try again in %d minutes", minutes))
+			td.Form = form
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			app.logger.Warn("share passcode attempts exceeded", "share_id", share.ID, "ip", visitor.IP)
+			app.render(w, r, http.StatusTooManyRequests, "shared_note.tmpl", td)
+			return
+		}
+	}
+	if !share.CheckPasscode(passcode) {
+		form := SharedNoteUnlockForm{Validator: *validator.NewValidator()}
+		status := http.StatusOK
+		if r.Method == http.MethodPost {
+			form.AddError("passcode", "is incorrect")
+			status = http.StatusUnauthorized
+			app.recordAuthEvent(visitor, data.AuditShareUnlockFail, share.ID)
+		}
+		td.Form = form
+		app.render(w, r, status, "shared_note.tmpl", td)
+		return
+	}
+
+	note, err := app.moodNotes.Get(share.NoteID)
+	if err != nil {
+		if err.Error() == "mood note record not found" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	if r.Method == http.MethodPost {
+		app.recordAuthEvent(visitor, data.AuditShareUnlock, share.ID)
+	}
+	err = app.noteShares.RecordView(share.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	td.Note = note
+	app.render(w, r, http.StatusOK, "shared_note.tmpl", td)
+}
+
+// --- Activity Log ---
+
+// activityPageSize is how many audit events the activity page shows at once.
+const activityPageSize = 50
+
+// showActivity lists the audit log, newest first, optionally filtered by action
+// or target (e.g. ?target_type=note&target_id=12 for one note's history).
+func (app *application) showActivity(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, err := strconv.Atoi(q.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	filter := data.AuditFilter{
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+	}
+	if !slices.Contains(data.AuditActions, filter.Action) {
+		filter.Action = ""
+	}
+	if !slices.Contains(data.AuditTargetTypes, filter.TargetType) {
+		filter.TargetType = ""
+	}
+	filter.TargetID, err = strconv.ParseInt(q.Get("target_id"), 10, 64)
+	if err != nil || filter.TargetID < 0 {
+		filter.TargetID = 0
+	}
+
+	events, pageInfo, err := app.auditEvents.GetPage(filter, page, activityPageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Paging links keep the current filter.
+	pageURL := func(n int) string {
+		v := url.Values{}
+		if filter.Action != "" {
+			v.Set("action", filter.Action)
+		}
+		if filter.TargetType != "" {
+			v.Set("target_type", filter.TargetType)
+		}
+		if filter.TargetID > 0 {
+			v.Set("target_id", strconv.FormatInt(filter.TargetID, 10))
+		}
+		v.Set("page", strconv.Itoa(n))
+		return "/settings/activity?" + v.Encode()
+	}
+
+	td := newTemplateData()
+	td.AuditEvents = events
+	td.AuditFilter = filter
+	td.AuditActions = data.AuditActions
+	td.PageInfo = pageInfo
+	if pageInfo.HasPrev() {
+		td.PrevPageURL = pageURL(page - 1)
+	}
+	if pageInfo.HasNext() {
+		td.NextPageURL = pageURL(page + 1)
+	}
+	app.render(w, r, http.StatusOK, "activity.tmpl", td)
+}
+
+// --- Your Data ---
+
+// eraseConfirmWord must be typed to schedule an erasure, so it can't happen by a stray click.
+const eraseConfirmWord = "DELETE"
+
+// dataExportListSize is how many past exports the data page lists.
+const dataExportListSize = 10
+
+// renderDataPage shows the export and erasure controls.
+func (app *application) renderDataPage(w http.ResponseWriter, r *http.Request, status int, form DataErasureForm) {
+	exports, err := app.dataExports.GetRecent(dataExportListSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	erasure, err := app.dataErasures.Pending()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	td := newTemplateData()
+	td.DataExports = exports
+	td.Erasure = erasure
+	td.Form = form
+	app.render(w, r, status, "data.tmpl", td)
+}
+
+// showData is the "your data" settings page.
+func (app *application) showData(w http.ResponseWriter, r *http.Request) {
+	app.renderDataPage(w, r, http.StatusOK, DataErasureForm{})
+}
+
+// requestDataExport queues a ZIP of everything the app stores; runDataJobs builds it.
+func (app *application) requestDataExport(w http.ResponseWriter, r *http.Request) {
+	export, err := app.dataExports.Insert(app.auditInfo(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logger.Info("data export requested", "export_id", export.ID)
+	http.Redirect(w, r, "/settings/data", http.StatusSeeOther)
+}
+
+// downloadDataExport sends a finished export while its download window is open.
+func (app *application) downloadDataExport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	export, err := app.dataExports.GetReady(id)
+	if err != nil {
+		if err.Error() == "data export not found or expired" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	err = app.serveDataExport(w, r, export)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// serveDataExport sends a ready export's archive. It is also behind
+// GET /v1/exports/{id}/archive.
+func (app *application) serveDataExport(w http.ResponseWriter, r *http.Request, export *data.DataExport) error {
+	archive, err := app.openSealedBlob(r.Context(), export.BlobKey, export.KeyID)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	filename := fmt.Sprintf("feel-flow-export-%s.zip", export.CompletedAt.UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, "", export.CompletedAt, archive)
+	return nil
+}
+
+// scheduleErasure queues the erasure of every note, share link, log entry and
+// export once the grace period has passed.
+func (app *application) scheduleErasure(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := DataErasureForm{
+		Confirm:   r.PostForm.Get("confirm"),
+		Validator: *validator.NewValidator(),
+	}
+	form.Check(form.Confirm == eraseConfirmWord, "confirm", fmt.Sprintf("type %s to confirm", eraseConfirmWord))
+	if !form.ValidData() {
+		app.renderDataPage(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	erasure, err := app.dataErasures.Schedule(time.Now().Add(app.config.Privacy.ErasureGracePeriod), app.auditInfo(r))
+	if err != nil {
+		if err.Error() == "data erasure already scheduled" {
+			http.Redirect(w, r, "/settings/data", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.logger.Warn("data erasure scheduled", "erasure_id", erasure.ID, "scheduled_for", erasure.ScheduledFor)
+	http.Redirect(w, r, "/settings/data", http.StatusSeeOther)
+}
+
+// cancelErasure calls off a scheduled erasure during its grace period.
+func (app *application) cancelErasure(w http.ResponseWriter, r *http.Request) {
+	err := app.dataErasures.Cancel(app.auditInfo(r))
+	if err != nil && err.Error() != "no data erasure scheduled" {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logger.Info("data erasure cancelled")
+	http.Redirect(w, r, "/settings/data", http.StatusSeeOther)
+}
+
+// --- API Tokens ---
+
+// apiTokenExpiries are the lifetimes offered for a new API token, in display order.
+var apiTokenExpiries = []ShareExpiry{
+	{Value: "30d", Label: "30 days", Duration: 30 * 24 * time.Hour},
+	{Value: "90d", Label: "90 days", Duration: 90 * 24 * time.Hour},
+	{Value: "1y", Label: "1 year", Duration: 365 * 24 * time.Hour},
+	{Value: "never", Label: "Never"},
+}
+
+// renderAPITokens shows the API tokens, the form for a new one and, right after
+// creation, the new token (the only time it is available).
+func (app *application) renderAPITokens(w http.ResponseWriter, r *http.Request, status int, form APITokenForm, created *data.APIToken) {
+	tokens, err := app.apiTokens.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	td := newTemplateData()
+	td.APITokens = tokens
+	td.Form = form
+	if created != nil {
+		td.NewAPIToken = created.Token
+	}
+	app.render(w, r, status, "api_tokens.tmpl", td)
+}
+
+// showAPITokens lists the API tokens.
+func (app *application) showAPITokens(w http.ResponseWriter, r *http.Request) {
+	app.renderAPITokens(w, r, http.StatusOK, APITokenForm{Scopes: []string{data.ScopeNotesRead}, Expiry: "90d"}, nil)
+}
+
+// createAPIToken creates a token with the chosen name, scopes and lifetime.
+func (app *application) createAPIToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := APITokenForm{
+		Name:      r.PostForm.Get("name"),
+		Scopes:    r.PostForm["scopes"],
+		Expiry:    r.PostForm.Get("expiry"),
+		Validator: *validator.NewValidator(),
+	}
+
+	token := &data.APIToken{Name: form.Name, Scopes: form.Scopes}
+	validExpiry := false
+	for _, e := range apiTokenExpiries {
+		if e.Value == form.Expiry {
+			validExpiry = true
+			if e.Duration > 0 {
+				token.ExpiresAt = time.Now().Add(e.Duration)
+			}
+		}
+	}
+	form.Check(validExpiry, "expiry", "must be one of the listed options")
+	data.ValidateAPIToken(&form.Validator, token)
+
+	if !form.ValidData() {
+		app.renderAPITokens(w, r, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	err = app.apiTokens.Insert(token, app.auditInfo(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logger.Info("API token created", "token_id", token.ID, "scopes", token.Scopes, "expires", token.ExpiresAt)
+	app.renderAPITokens(w, r, http.StatusCreated, APITokenForm{Scopes: []string{data.ScopeNotesRead}, Expiry: form.Expiry}, token)
+}
+
+// deleteAPIToken revokes a token straight away.
+func (app *application) deleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	err = app.apiTokens.Delete(id, app.auditInfo(r))
+	if err != nil {
+		if err.Error() == "API token not found" {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.logger.Info("API token revoked", "token_id", id)
+	http.Redirect(w, r, "/settings/tokens", http.StatusSeeOther)
+}
+
+// --- Offline ---
+
+// showOffline is the page the service worker falls back to when a page isn't
+// cached and the network is down. It is precached, so it is rendered without
+// any data: main.js fills in the outbox and the entries cached for reading.
+// Online it still works as the place to see what is waiting to be saved.
+func (app *application) showOffline(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, http.StatusOK, "offline.tmpl", newTemplateData())
+}
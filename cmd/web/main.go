@@ -3,35 +3,77 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"errors" // Added for checking errors
-	"flag"
-	"html/template"
 	"fmt"
+	"html/template"
+	"io/fs"
 	"log/slog"
 	"net/http" // Required for http.Server
+	"net/netip"
 	"os"
 	"time"
+	_ "time/tzdata" // Embed the timezone database so user timezones work on minimal hosts
 
-	_ "github.com/lib/pq"                                // PostgreSQL driver
-	"github.com/mickali02/mood-notes-app/internal/data"  // Correct data package path
-	_ "github.com/mickali02/mood-notes-app/ui"          // Import the ui package with embedded files
+	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/mickali02/mood-notes-app/internal/blobstore"
+	"github.com/mickali02/mood-notes-app/internal/data" // Correct data package path
+	"github.com/mickali02/mood-notes-app/internal/mailer"
+	"github.com/mickali02/mood-notes-app/migrations"
+	"github.com/mickali02/mood-notes-app/ui" // Import the ui package with embedded files
 )
 
 // application struct holds application-wide dependencies.
 type application struct {
-	logger        *slog.Logger
-	addr          string
-	moodNotes     *data.MoodNoteModel // Use the specific model
-	templateCache map[string]*template.Template
+	logger         *slog.Logger
+	config         config
+	models         data.Models         // All models; use models.WithTx for changes spanning several
+	moodNotes      *data.MoodNoteModel // Use the specific model
+	emailSends     *data.EmailSendModel
+	unsubscribes   *data.UnsubscribeModel
+	tokens         *data.TokenModel
+	verifiedEmails *data.VerifiedEmailModel
+	noteShares     *data.NoteShareModel
+	noteTemplates  *data.NoteTemplateModel
+	drafts         *data.DraftModel
+	attachments    *data.AttachmentModel
+	blobs          blobstore.Store // Attachment files; see internal/blobstore
+	auditEvents    *data.AuditModel
+	dataExports    *data.DataExportModel
+	dataErasures   *data.DataErasureModel
+	apiTokens      *data.APITokenModel
+	mailer         *mailer.Mailer
+	templateCache  map[string]*template.Template
+	journal        *data.JournalLibrary // Built-in note templates and prompts
+
+	rateLimiters   rateLimiters   // Per route group; see ratelimit.go
+	trustedProxies []netip.Prefix // Proxies allowed to set X-Forwarded-For
+	tokenRejects   *rateLimiter   // Samples rejected API tokens for the audit log; see api.go
+	shareUnlocks   *rateLimiter   // Passcode attempts per share link; see handlers.go
+
+	defaultLocation *time.Location // Timezone for dates until the browser reports its own
 }
 
 func main() {
-	addr := flag.String("addr", ":4000", "HTTP network address")
-	// Read DSN from environment variable for better security/config management
-	dsn := flag.String("dsn", os.Getenv("MOODNOTES_DB_DSN"), "PostgreSQL DSN (reads MOODNOTES_DB_DSN env var)")
+	// --- Configuration ---
+	// Defaults < config file < MOODNOTES_* environment variables < flags (see config.go)
+	cfg, printConfig, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	flag.Parse()
+	if printConfig {
+		out, err := cfg.printTOML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+		os.Exit(0)
+	}
 
 	// --- Logging ---
 	// Use a structured logger with Debug level during development
@@ -39,21 +81,46 @@ func main() {
 		Level: slog.LevelDebug,
 	}))
 
-	// --- Database ---
-	// Check if the DSN was provided (either via flag or env var)
-	if *dsn == "" {
-		logger.Error("database DSN configuration is missing. Set the MOODNOTES_DB_DSN environment variable or use the -dsn flag.")
+	// Validate the combined configuration before touching the database.
+	err = cfg.validate()
+	if err != nil {
+		logger.Error("configuration error", "error", err)
 		os.Exit(1)
 	}
 
-	db, err := openDB(*dsn)
+	// Browser sessions' CSRF tokens are signed too, so there must be a secret.
+	// A random one works until the next restart, when pages and the service
+	// worker simply fetch new tokens; signed email links stop working, though.
+	if cfg.SigningSecret == "" {
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		if err != nil {
+			logger.Error("generating signing secret", "error", err)
+			os.Exit(1)
+		}
+		cfg.SigningSecret = hex.EncodeToString(secret)
+		logger.Warn("no signing secret configured; using a random one until restart")
+	}
+
+	// --- Database ---
+	db, err := openDB(cfg)
 	if err != nil {
 		logger.Error("database connection error", "error", err)
 		os.Exit(1)
 	}
 	// Defer closing the connection pool when main() exits.
 	defer db.Close()
-	logger.Info("database connection pool established")
+	logger.Info("database connection pool established", "driver", cfg.DB.Driver)
+
+	// SQLite installs have no migrate tool to hand, so they migrate themselves.
+	if cfg.DB.Driver == "sqlite" {
+		applied, err := data.MigrateSQLite(db, migrations.SQLite())
+		if err != nil {
+			logger.Error("sqlite migration failed", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("sqlite schema up to date", "applied", applied)
+	}
 
 	// --- Template Cache ---
 	// Initialize the template cache using the function from templates.go
@@ -64,17 +131,92 @@ func main() {
 	}
 	logger.Info("template cache loaded successfully")
 
+	// Built-in note templates and prompts, embedded like the page templates.
+	journalFiles, err := fs.Sub(ui.Files, "journal")
+	if err != nil {
+		logger.Error("failed to open journal library", "error", err)
+		os.Exit(1)
+	}
+	journal, err := data.LoadJournalLibrary(journalFiles)
+	if err != nil {
+		logger.Error("failed to load journal library", "error", err)
+		os.Exit(1)
+	}
+
+	// Validated by cfg.validate(), so this can't fail here.
+	defaultLocation, err := time.LoadLocation(cfg.UI.Timezone)
+	if err != nil {
+		logger.Error("invalid timezone", "error", err)
+		os.Exit(1)
+	}
+
+	// Also validated already.
+	trustedProxies, err := parseTrustedProxies(cfg.RateLimit.TrustedProxies)
+	if err != nil {
+		logger.Error("invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
 
 	// --- Initialize Application Dependencies ---
+	cipher, err := openNoteCipher(cfg, db, logger)
+	if err != nil {
+		logger.Error("failed to set up note encryption", "error", err)
+		os.Exit(1)
+	}
+	models := data.NewModels(db, cfg.DB.QueryTimeout, cipher)
+
+	// Attachment files are kept on local disk; another blobstore.Store can replace it.
+	blobs, err := blobstore.NewLocal(cfg.Attachments.Dir)
+	if err != nil {
+		logger.Error("failed to open attachment storage", "error", err)
+		os.Exit(1)
+	}
+
 	app := &application{
-		logger:        logger,
-		addr:          *addr,
-		moodNotes:     &data.MoodNoteModel{DB: db}, // Initialize MoodNoteModel with the DB pool
-		templateCache: templateCache,
+		logger:          logger,
+		config:          cfg,
+		models:          models,
+		moodNotes:       models.MoodNotes,
+		emailSends:      models.EmailSends,
+		unsubscribes:    models.Unsubscribes,
+		tokens:          models.Tokens,
+		verifiedEmails:  models.VerifiedEmails,
+		noteShares:      models.NoteShares,
+		noteTemplates:   models.Templates,
+		drafts:          models.Drafts,
+		attachments:     models.Attachments,
+		blobs:           blobs,
+		auditEvents:     models.AuditEvents,
+		dataExports:     models.DataExports,
+		dataErasures:    models.DataErasures,
+		apiTokens:       models.APITokens,
+		mailer:          newMailer(cfg, logger),
+		templateCache:   templateCache,
+		journal:         journal,
+		defaultLocation: defaultLocation,
+		rateLimiters:    newRateLimiters(cfg),
+		trustedProxies:  trustedProxies,
+		tokenRejects:    newTokenRejectLimiter(),
+		shareUnlocks:    newShareUnlockLimiter(),
+	}
+
+	// --- Background Jobs ---
+	// Stopped when main returns (after the server has shut down).
+	done := make(chan struct{})
+	defer close(done)
+	app.rateLimiters.cleanup(done)
+	go app.tokenRejects.cleanup(time.Minute, done)
+	go app.shareUnlocks.cleanup(time.Minute, done)
+	if cipher != nil {
+		go app.runReencryption(done)
+	}
+	go app.runDataJobs(done)
+	if cfg.Reminders.Enabled || cfg.Digest.Enabled {
+		go app.runEmailScheduler(done)
 	}
 
 	// --- Start HTTP Server ---
-	logger.Info("starting server", "address", app.addr)
+	logger.Info("starting server", "address", app.config.Addr)
 	// Call the serve method defined in server.go
 	err = app.serve()
 	// Check for specific server closed error, which is expected on shutdown
@@ -85,22 +227,44 @@ func main() {
 	logger.Info("server stopped gracefully") // Log on graceful shutdown too
 }
 
+// newMailer builds the mailer for the configured delivery driver.
+func newMailer(cfg config, logger *slog.Logger) *mailer.Mailer {
+	var sender mailer.Sender
+	switch cfg.Mail.Driver {
+	case "smtp":
+		sender = &mailer.SMTPSender{
+			Host:     cfg.Mail.SMTP.Host,
+			Port:     cfg.Mail.SMTP.Port,
+			Username: cfg.Mail.SMTP.Username,
+			Password: cfg.Mail.SMTP.Password,
+		}
+	case "file":
+		sender = &mailer.FileSender{Dir: cfg.Mail.FileDir}
+	default:
+		sender = &mailer.LogSender{Logger: logger}
+	}
+	return mailer.New(sender, cfg.Mail.Sender, ui.Files, "email")
+}
+
 // openDB connects to the database and verifies the connection.
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", dsn)
+func openDB(cfg config) (*sql.DB, error) {
+	driver := "postgres"
+	if cfg.DB.Driver == "sqlite" {
+		driver = data.SQLiteDriver // Needs a cgo build; see internal/data/sqlite.go
+	}
+	db, err := sql.Open(driver, cfg.DB.DSN)
 	if err != nil {
 		return nil, err
 	}
 
 	// Configure connection pool settings (important for performance and resource management)
-	db.SetMaxOpenConns(25) // Max number of open connections
-	db.SetMaxIdleConns(25) // Max number of connections sitting idle
-	db.SetConnMaxIdleTime(5 * time.Minute) // How long a connection can be idle before being closed
-	db.SetConnMaxLifetime(2 * time.Hour) // Max lifetime of any connection
-
+	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)   // Max number of open connections
+	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)   // Max number of connections sitting idle
+	db.SetConnMaxIdleTime(cfg.DB.MaxIdleTime) // How long a connection can be idle before being closed
+	db.SetConnMaxLifetime(cfg.DB.MaxLifetime) // Max lifetime of any connection
 
 	// Create a context with a timeout for the initial ping to verify the connection.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DB.PingTimeout)
 	defer cancel()
 
 	// Ping the database to verify the connection is live.
@@ -112,4 +276,4 @@ func openDB(dsn string) (*sql.DB, error) {
 	}
 
 	return db, nil
-}
\ No newline at end of file
+}
@@ -2,9 +2,51 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
 )
 
+// requestIDRX limits request IDs accepted from a trusted proxy to harmless characters.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestIDMiddleware gives every request an ID, sends it back in X-Request-ID and
+// stores it in the request context for logs and the audit log. An ID set by a
+// trusted proxy is kept so the same request can be followed across both logs.
+func (app *application) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := ""
+		if incoming := r.Header.Get("X-Request-ID"); requestIDRX.MatchString(incoming) && app.fromTrustedProxy(r) {
+			id = incoming
+		}
+		if id == "" {
+			b := make([]byte, 8)
+			_, err := rand.Read(b)
+			if err != nil {
+				app.serverError(w, r, fmt.Errorf("generating request ID: %w", err))
+				return
+			}
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, withRequestID(r, id))
+	})
+}
+
+// fromTrustedProxy reports whether the connection itself comes from a trusted proxy.
+func (app *application) fromTrustedProxy(r *http.Request) bool {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	return app.trustedProxy(addrPort.Addr())
+}
+
 // loggingMiddleware logs details about incoming HTTP requests.
 func (app *application) loggingMiddleware(next http.Handler) http.Handler {
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -14,20 +56,102 @@ func (app *application) loggingMiddleware(next http.Handler) http.Handler {
 			proto  = r.Proto
 			method = r.Method
 			uri    = r.URL.RequestURI()
+			id     = requestID(r)
 		)
 		// Log the extracted details using the application's structured logger.
-		app.logger.Info("received request", "ip", ip, "protocol", proto, "method", method, "uri", uri)
+		app.logger.Info("received request", "ip", ip, "protocol", proto, "method", method, "uri", uri, "request_id", id)
 
 		// Call the next handler in the chain.
 		next.ServeHTTP(w, r)
 
 		// Optional: Log after the request is processed (e.g., include status code if using a response recorder middleware)
-		app.logger.Info("request processed", "method", method, "uri", uri) // Simple processed message
+		app.logger.Info("request processed", "method", method, "uri", uri, "request_id", id) // Simple processed message
 	})
 	return fn
 }
 
+// secureHeaders sets security-related response headers on every response,
+// including a strict Content-Security-Policy with a fresh nonce per request.
+// Templates read the nonce from TemplateData.CSPNonce for any <script> or <style> tag.
+func (app *application) secureHeaders(next http.Handler) http.Handler {
+	// The only third-party origins are Google Fonts' stylesheet and font files.
+	styleSrc := "'self' https://fonts.googleapis.com"
+	fontSrc := "'self' https://fonts.gstatic.com"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, err := newCSPNonce()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		csp := []string{
+			"default-src 'self'",
+			"script-src 'self' 'nonce-" + nonce + "'",
+			"style-src " + styleSrc + " 'nonce-" + nonce + "'",
+			"font-src " + fontSrc,
+			"img-src 'self' data:",
+			"connect-src 'self'",
+			"object-src 'none'",
+			"base-uri 'self'",
+			"form-action 'self'",
+			"frame-ancestors 'none'",
+		}
+
+		h := w.Header()
+		h.Set("Content-Security-Policy", strings.Join(csp, "; "))
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		h.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=(), payment=(), usb=(), interest-cohort=()")
+		h.Set("X-Frame-Options", "DENY") // For older browsers that ignore frame-ancestors
+		h.Set("Cross-Origin-Opener-Policy", "same-origin")
+
+		next.ServeHTTP(w, withCSPNonce(r, nonce))
+	})
+}
+
+// newCSPNonce returns a random, base64-encoded value suitable for a CSP nonce.
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("generating CSP nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hsts tells browsers to only use HTTPS for this site from now on.
+// It is only added to the chain when the server is running with TLS, and even
+// then only answers over TLS carry the header (RFC 6797 says to ignore it otherwise).
+func (app *application) hsts(next http.Handler) http.Handler {
+	value := fmt.Sprintf("max-age=%d; includeSubDomains", int(app.config.TLS.HSTSMaxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// noIndex is for public pages reached by secret link (shared notes): search engines
+// must not index them, the secret URL must not leak to other sites via Referer,
+// and nothing should be cached by shared proxies or the browser.
+func (app *application) noIndex(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Robots-Tag", "noindex, nofollow, noarchive")
+		h.Set("Referrer-Policy", "no-referrer") // Overrides the site-wide policy from secureHeaders
+		h.Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Add other middleware here later (e.g., recoverPanic, authenticate)
+//
+// Two-factor authentication (TOTP with recovery codes and a remembered-device
+// cookie) waits for authenticate: with no password login there is no first
+// factor for it to add to. When it comes, the device cookie can be signed with
+// app.sign, and enabling, disabling and recovery-code use belong in the audit log.
 /*
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
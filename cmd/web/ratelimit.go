@@ -0,0 +1,234 @@
+// cmd/web/ratelimit.go
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// --- Token Buckets ---
+
+// rateLimiter hands out one token bucket per key (normally a client; see clientKey).
+// Buckets that have been idle longer than idleTTL are dropped by cleanup.
+type rateLimiter struct {
+	limit   rate.Limit
+	burst   int
+	idleTTL time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// bucket is a single client's limiter and when it was last used.
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter returns a limiter allowing rps requests per second on average,
+// with bursts of up to burst requests.
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	// Keep a bucket at least until it has refilled: dropping it sooner would
+	// hand the client a fresh burst.
+	idleTTL := 3 * time.Minute
+	if refill := time.Duration(float64(burst) / rps * float64(time.Second)); refill > idleTTL {
+		idleTTL = refill
+	}
+	return &rateLimiter{
+		limit:   rate.Limit(rps),
+		burst:   burst,
+		idleTTL: idleTTL,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token from key's bucket. When the bucket is empty it reports how
+// long the client should wait; remaining is the number of whole tokens left.
+func (l *rateLimiter) allow(key string, now time.Time) (ok bool, remaining int, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now) // Don't charge the client for a request we're refusing
+		return false, 0, delay
+	}
+	return true, int(b.limiter.TokensAt(now)), 0
+}
+
+// cleanup evicts idle buckets every interval until done is closed, so the map
+// doesn't grow with every address that has ever made a request.
+func (l *rateLimiter) cleanup(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			l.mu.Lock()
+			for key, b := range l.buckets {
+				if now.Sub(b.lastSeen) > l.idleTTL {
+					delete(l.buckets, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// rateLimiters holds one limiter per route group. A nil limiter means the group is unlimited.
+type rateLimiters struct {
+	read     *rateLimiter // Page views and the live Markdown preview
+	write    *rateLimiter // Creating, editing and deleting notes; unsubscribing
+	autosave *rateLimiter // Draft autosaves, so a long writing session can't starve the write group
+	sync     *rateLimiter // JSON API note changes, so an offline queue can be replayed in one go
+}
+
+// newRateLimiters builds the limiters described by the config, or none when rate limiting is off.
+func newRateLimiters(cfg config) rateLimiters {
+	if !cfg.RateLimit.Enabled {
+		return rateLimiters{}
+	}
+	return rateLimiters{
+		read:     newRateLimiter(cfg.RateLimit.Read.RPS, cfg.RateLimit.Read.Burst),
+		write:    newRateLimiter(cfg.RateLimit.Write.RPS, cfg.RateLimit.Write.Burst),
+		autosave: newRateLimiter(cfg.RateLimit.Autosave.RPS, cfg.RateLimit.Autosave.Burst),
+		sync:     newRateLimiter(cfg.RateLimit.Sync.RPS, cfg.RateLimit.Sync.Burst),
+	}
+}
+
+// cleanup starts the eviction goroutine for every configured limiter.
+func (ls rateLimiters) cleanup(done <-chan struct{}) {
+	for _, l := range []*rateLimiter{ls.read, ls.write, ls.autosave, ls.sync} {
+		if l != nil {
+			go l.cleanup(time.Minute, done)
+		}
+	}
+}
+
+// --- Middleware ---
+
+// rateLimit rejects requests from clients that have used up their bucket in l with
+// 429 Too Many Requests. Every response carries RateLimit-Limit and RateLimit-Remaining
+// headers; rejections add Retry-After (whole seconds).
+func (app *application) rateLimit(l *rateLimiter, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, remaining, retryAfter := l.allow(app.clientKey(r), time.Now())
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(l.burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		if !ok {
+			seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+			h.Set("Retry-After", seconds)
+			h.Set("RateLimit-Reset", seconds)
+			app.logger.Warn("rate limit exceeded", "ip", app.clientIP(r), "method", r.Method, "uri", r.URL.RequestURI())
+			app.clientError(w, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// --- Client IP ---
+
+// clientIP returns the client's address, for logs and the audit log. X-Forwarded-For
+// is only believed when the connection comes from a trusted proxy; the list is then
+// read right to left, skipping further trusted proxies, so a client can't spoof its
+// address by sending its own header.
+func (app *application) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !app.trustedProxy(addr) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // Garbage from here on can't be trusted; use the last good hop
+		}
+		host = hop.Unmap().String()
+		if !app.trustedProxy(hop) {
+			break
+		}
+	}
+	return host
+}
+
+// clientKey returns what rate limits are keyed on: the client's IPv4 address, or
+// the /64 network of its IPv6 address. An IPv6 client usually has a whole /64 to
+// itself and could take a fresh address, and a fresh bucket, for every request.
+func (app *application) clientKey(r *http.Request) string {
+	ip := app.clientIP(r)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Unmap().Is4() {
+		return ip
+	}
+	prefix, err := addr.WithZone("").Prefix(64)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// trustedProxy reports whether addr is in one of the configured trusted proxy ranges.
+func (app *application) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range app.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies converts entries such as "10.0.0.0/8" or "127.0.0.1" into prefixes.
+func parseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			p, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,84 @@
+// cmd/web/ratelimit_test.go
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRateLimitGroups checks that autosave and sync have buckets of their own,
+// so a long writing session or an offline queue doesn't use up the write group.
+func TestRateLimitGroups(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.RateLimit.Write = rateGroup{RPS: 0.001, Burst: 2}
+	cfg.RateLimit.Autosave = rateGroup{RPS: 0.001, Burst: 3}
+	cfg.RateLimit.Sync = rateGroup{RPS: 0.001, Burst: 4}
+	app := &application{
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		config:       cfg,
+		rateLimiters: newRateLimiters(cfg),
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	// allowed sends requests through l until one is refused.
+	allowed := func(l *rateLimiter) int {
+		h := app.rateLimit(l, ok)
+		for n := 0; n < 100; n++ {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/", nil))
+			if rr.Code == http.StatusTooManyRequests {
+				if rr.Header().Get("Retry-After") == "" {
+					t.Error("429 without Retry-After")
+				}
+				return n
+			}
+		}
+		return -1
+	}
+
+	// Written in this order, each group still has its full burst after the one before.
+	for _, tt := range []struct {
+		name string
+		l    *rateLimiter
+		want int
+	}{
+		{"write", app.rateLimiters.write, 2},
+		{"autosave", app.rateLimiters.autosave, 3},
+		{"sync", app.rateLimiters.sync, 4},
+	} {
+		if got := allowed(tt.l); got != tt.want {
+			t.Errorf("%s group allowed %d requests; want %d", tt.name, got, tt.want)
+		}
+	}
+
+	cfg.RateLimit.Enabled = false
+	if ls := newRateLimiters(cfg); ls.autosave != nil || ls.sync != nil {
+		t.Error("limiters built with rate limiting off")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	app := &application{}
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"192.0.2.7:1234", "192.0.2.7"},
+		{"[::ffff:192.0.2.7]:1234", "::ffff:192.0.2.7"}, // IPv4-mapped: still one address
+		{"[2001:db8:1:2:aaaa:bbbb:cccc:dddd]:1234", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:2::1]:1234", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:3::1]:1234", "2001:db8:1:3::/64"},
+		{"[fe80::1%eth0]:1234", "fe80::/64"},
+		{"not an address", "not an address"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if got := app.clientKey(r); got != tt.want {
+			t.Errorf("clientKey(%q) = %q; want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
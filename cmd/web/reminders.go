@@ -0,0 +1,70 @@
+// cmd/web/reminders.go
+package main
+
+import (
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// reminderKind identifies daily reminders in the email_sends table.
+const reminderKind = "daily-reminder"
+
+// reminderEmailData is passed to ui/email/daily_reminder.tmpl.
+type reminderEmailData struct {
+	Date       string // Local date the reminder is for, e.g. "Monday, Jan 02"
+	NewNoteURL string
+}
+
+// sendReminderIfDue sends today's reminder if it's time and it hasn't been sent or made redundant.
+func (app *application) sendReminderIfDue(now time.Time, loc *time.Location) error {
+	// Config validation guarantees the format.
+	at, err := time.Parse("15:04", app.config.Reminders.Time)
+	if err != nil {
+		return err
+	}
+
+	dayStart := data.PeriodStart(now, loc, data.PeriodDay)
+	dueAt := time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+	if now.Before(dueAt) {
+		return nil
+	}
+
+	// Already wrote today? No nudge needed.
+	count, err := app.moodNotes.CountCreatedBetween(dayStart, data.PeriodEnd(dayStart, data.PeriodDay))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	recipient := app.config.Reminders.Recipient
+	verified, err := app.recipientVerified(recipient, now, loc)
+	if err != nil || !verified {
+		return err
+	}
+	claimed, err := app.emailSends.Claim(reminderKind, recipient, dayStart)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil // Sent earlier today (possibly before a restart)
+	}
+
+	err = app.mailer.Send(recipient, "daily_reminder.tmpl", reminderEmailData{
+		Date:       dayStart.Format("Monday, Jan 02"),
+		NewNoteURL: app.config.BaseURL + "/note/new",
+	})
+	if err != nil {
+		// Give the claim back so the next tick retries.
+		releaseErr := app.emailSends.Release(reminderKind, recipient, dayStart)
+		if releaseErr != nil {
+			app.logger.Error("could not release reminder claim", "error", releaseErr)
+		}
+		return err
+	}
+
+	app.logger.Info("daily reminder sent", "recipient", recipient, "day", dayStart.Format("2006-01-02"))
+	return nil
+}
@@ -3,8 +3,9 @@ package main
 
 import (
 	"net/http"
-	"os" 
+	"os"
 
+	"github.com/mickali02/mood-notes-app/internal/data"
 	"github.com/mickali02/mood-notes-app/ui" // Import the ui package with embedded files
 )
 
@@ -54,20 +55,100 @@ func (app *application) routes() http.Handler {
 	// look for 'static/css/styles.css' within the embedded filesystem root.
 	// No StripPrefix is needed here because the request path already matches the
 	// structure within the embedded FS.
-	mux.Handle("GET /static/", fileServer)
+	// Versioned URLs ("?v=", see asset in assets.go) are cached as immutable.
+	mux.Handle("GET /static/", app.cacheStatic(fileServer))
+
+	// --- Rate Limited Route Groups ---
+	// Each group has its own token bucket per client IP, or IPv6 /64 (see ratelimit.go).
+	read := func(h http.HandlerFunc) http.Handler { return app.rateLimit(app.rateLimiters.read, h) }
+	write := func(h http.HandlerFunc) http.Handler { return app.rateLimit(app.rateLimiters.write, h) }
+	autosave := func(h http.HandlerFunc) http.Handler { return app.rateLimit(app.rateLimiters.autosave, h) }
+	sync := func(h http.HandlerFunc) http.Handler { return app.rateLimit(app.rateLimiters.sync, h) }
 
 	// --- Mood Note Dynamic Routes ---
-	mux.HandleFunc("GET /{$}", app.home)                   // Home page (list notes)
-	mux.HandleFunc("GET /note/new", app.showMoodNoteForm)  // Show form to CREATE note
-	mux.HandleFunc("POST /note/new", app.createMoodNote) // Handle form submission for CREATE
+	mux.Handle("GET /{$}", read(app.home))                  // Home page (list notes)
+	mux.Handle("GET /note/new", read(app.showMoodNoteForm)) // Show form to CREATE note
+	mux.Handle("POST /note/new", write(app.createMoodNote)) // Handle form submission for CREATE
 
 	// Use Go 1.22+ path parameters {id}
-	mux.HandleFunc("GET /note/edit/{id}", app.showMoodNoteForm) // Show form to EDIT note
-	mux.HandleFunc("POST /note/edit/{id}", app.updateMoodNote) // Handle form submission for UPDATE
-	mux.HandleFunc("POST /note/delete/{id}", app.deleteMoodNote) // Handle deletion
+	mux.Handle("GET /note/{id}", read(app.showMoodNote))            // Read-only detail page
+	mux.Handle("GET /note/edit/{id}", read(app.showMoodNoteForm))   // Show form to EDIT note
+	mux.Handle("POST /note/edit/{id}", write(app.updateMoodNote))   // Handle form submission for UPDATE
+	mux.Handle("POST /note/delete/{id}", write(app.deleteMoodNote)) // Handle deletion
+	mux.Handle("POST /note/preview", read(app.previewMoodNote))     // Live preview; read group as it fires while typing
+
+	// --- Drafts ---
+	// {slot} is "new" or the ID of the note being edited.
+	mux.Handle("PUT /drafts/{slot}", autosave(app.saveDraft))          // Autosave from main.js and the service worker
+	mux.Handle("POST /drafts/{slot}/discard", write(app.discardDraft)) // "Discard" on the restore banner
+
+	// --- Attachments ---
+	// Served only by these handlers, never from a public directory.
+	mux.Handle("POST /note/attach/{id}", write(app.uploadAttachment))                 // Multipart upload of one image or audio clip
+	mux.Handle("GET /attachments/{id}", read(app.serveAttachment))                    // The file itself; supports Range requests
+	mux.Handle("GET /attachments/{id}/thumbnail", read(app.serveAttachmentThumbnail)) // JPEG thumbnail of an image
+	mux.Handle("POST /attachments/{id}/delete", write(app.deleteAttachment))          // Detach; the files are purged by the data job
+
+	// --- Sharing ---
+	// Verb-first like /note/edit/{id}: "/note/{id}/share" would clash with "/note/edit/{id}".
+	mux.Handle("GET /note/share/{id}", read(app.showNoteShares))                     // List and create share links
+	mux.Handle("POST /note/share/{id}", write(app.createNoteShare))                  // Create a share link
+	mux.Handle("POST /note/share/{id}/revoke/{shareID}", write(app.revokeNoteShare)) // Revoke a share link
+	mux.Handle("GET /s/{token}", app.noIndex(read(app.showSharedNote)))              // Public read-only view
+	mux.Handle("POST /s/{token}", app.noIndex(write(app.unlockSharedNote)))          // Passcode entry; write limits slow guessing
+
+	// --- Note Templates ---
+	// New entries start from one with /note/new?template=<id|slug|prompt>.
+	mux.Handle("GET /settings/templates", read(app.showNoteTemplates))                // Own and built-in templates
+	mux.Handle("GET /settings/templates/new", read(app.showNoteTemplateForm))         // ?from=<slug> copies a built-in one
+	mux.Handle("POST /settings/templates/new", write(app.createNoteTemplate))         // Save a new template
+	mux.Handle("GET /settings/templates/edit/{id}", read(app.showNoteTemplateForm))   // Edit form
+	mux.Handle("POST /settings/templates/edit/{id}", write(app.updateNoteTemplate))   // Save changes
+	mux.Handle("POST /settings/templates/delete/{id}", write(app.deleteNoteTemplate)) // Delete; entries are kept
+
+	// --- Settings ---
+	mux.Handle("GET /settings/activity", read(app.showActivity))               // Paginated audit log
+	mux.Handle("GET /settings/data", read(app.showData))                       // Export and erasure controls
+	mux.Handle("POST /settings/data/export", write(app.requestDataExport))     // Queue a ZIP of everything
+	mux.Handle("GET /settings/data/export/{id}", read(app.downloadDataExport)) // Time-limited download
+	mux.Handle("POST /settings/data/erase", write(app.scheduleErasure))        // Erase everything after the grace period
+	mux.Handle("POST /settings/data/erase/cancel", write(app.cancelErasure))   // Call off a scheduled erasure
+
+	// --- Offline Mode ---
+	// The service worker lives at the root so that its scope covers every page.
+	mux.Handle("GET /sw.js", read(app.serviceWorker))        // ui/static/js/sw.js with its versions filled in
+	mux.Handle("GET /offline", read(app.showOffline))        // Fallback page, precached by the service worker
+	mux.Handle("GET /session/csrf", read(app.showCSRFToken)) // The worker's CSRF token for the JSON API
+
+	// --- JSON API ---
+	// For scripts and apps, authenticated with a personal API token, and for the
+	// service worker, which caches recent entries and syncs offline ones (see api.go).
+	mux.Handle("GET /v1/notes", read(app.requireScope(data.ScopeNotesRead, app.apiListNotes)))                  // Recent entries; ?limit=
+	mux.Handle("GET /v1/notes/{id}", read(app.requireScope(data.ScopeNotesRead, app.apiShowNote)))              // One entry
+	mux.Handle("POST /v1/notes", sync(app.requireScope(data.ScopeNotesWrite, app.apiCreateNote)))               // Create; idempotent per client_id
+	mux.Handle("PUT /v1/notes/{id}", sync(app.requireScope(data.ScopeNotesWrite, app.apiUpdateNote)))           // Update at a version; 409 on conflict
+	mux.Handle("POST /v1/exports", write(app.requireScope(data.ScopeExport, app.apiRequestExport)))             // Queue a ZIP of everything
+	mux.Handle("GET /v1/exports/{id}", read(app.requireScope(data.ScopeExport, app.apiShowExport)))             // Status; poll until ready
+	mux.Handle("GET /v1/exports/{id}/archive", read(app.requireScope(data.ScopeExport, app.apiDownloadExport))) // The ZIP
+
+	// --- API Tokens ---
+	mux.Handle("GET /settings/tokens", read(app.showAPITokens))                // List and create tokens
+	mux.Handle("POST /settings/tokens", write(app.createAPIToken))             // Create; the token is shown once
+	mux.Handle("POST /settings/tokens/delete/{id}", write(app.deleteAPIToken)) // Revoke
+
+	// --- Email ---
+	mux.Handle("GET /unsubscribe", read(app.showUnsubscribe))  // Confirmation page for signed unsubscribe links
+	mux.Handle("POST /unsubscribe", write(app.unsubscribe))    // Confirmation form and RFC 8058 one-click POSTs
+	mux.Handle("GET /email/verify", read(app.showVerifyEmail)) // Confirmation page for verification links
+	mux.Handle("POST /email/verify", write(app.verifyEmail))   // Uses the token; write limits slow guessing
 
 	// --- Middleware ---
-	// Apply middleware. Logging middleware is applied last (runs first).
+	// Apply middleware. The request ID is assigned first so every log line can carry it,
+	// then logging wraps everything else.
 	// Add other middleware like recovery, authentication later inside loggingMiddleware.
-	return app.loggingMiddleware(mux)
-}
\ No newline at end of file
+	var handler http.Handler = app.secureHeaders(mux)
+	if app.config.tlsEnabled() {
+		handler = app.hsts(handler)
+	}
+	return app.requestIDMiddleware(app.loggingMiddleware(handler))
+}
@@ -0,0 +1,56 @@
+// cmd/web/scheduler.go
+package main
+
+import (
+	"time"
+)
+
+// emailCheckInterval is how often the scheduler wakes up to see whether an email is due.
+const emailCheckInterval = time.Minute
+
+// runEmailScheduler sends the scheduled emails (daily reminder, weekly digest) that are
+// enabled in the config. It runs until done is closed. Every send is claimed in
+// email_sends first, so restarts never send the same email twice.
+func (app *application) runEmailScheduler(done <-chan struct{}) {
+	loc := app.scheduleLocation()
+	app.logger.Info("email scheduler started", "reminders", app.config.Reminders.Enabled,
+		"digest", app.config.Digest.Enabled, "timezone", loc.String())
+
+	ticker := time.NewTicker(emailCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		// Check straight away too, so a restart after the scheduled time catches up.
+		now := time.Now()
+		if app.config.Reminders.Enabled {
+			err := app.sendReminderIfDue(now, loc)
+			if err != nil {
+				app.logger.Error("daily reminder failed", "error", err)
+			}
+		}
+		if app.config.Digest.Enabled {
+			err := app.sendDigestIfDue(now, loc)
+			if err != nil {
+				app.logger.Error("weekly digest failed", "error", err)
+			}
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleLocation returns the timezone scheduled email times are read in:
+// reminders.timezone when set, otherwise the default display timezone.
+func (app *application) scheduleLocation() *time.Location {
+	if app.config.Reminders.Timezone != "" {
+		loc, err := loadLocation(app.config.Reminders.Timezone)
+		if err == nil {
+			return loc
+		}
+	}
+	return app.defaultLocation
+}
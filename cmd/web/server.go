@@ -2,28 +2,65 @@
 package main
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
-	"time"
 )
 
 // serve configures and starts the application's HTTP server.
+// When a TLS certificate is configured the server listens with HTTPS only,
+// optionally alongside a plain HTTP listener that redirects to it.
 func (app *application) serve() error {
+	errorLog := slog.NewLogLogger(app.logger.Handler(), slog.LevelError) // Use structured logger for server errors
+
 	// Configure the HTTP server.
 	srv := &http.Server{
-		Addr:    app.addr,            // Listen address from command-line flag/default
-		Handler: app.routes(),        // Use the router returned by app.routes()
-		ErrorLog: slog.NewLogLogger(app.logger.Handler(), slog.LevelError), // Use structured logger for server errors
-		// Set timeouts to improve security and resource management.
-		IdleTimeout:  time.Minute,      // Max time for idle connections
-		ReadTimeout:  5 * time.Second,  // Max time to read request headers/body
-		WriteTimeout: 10 * time.Second, // Max time to write response
+		Addr:     app.config.Addr, // Listen address from config/flags
+		Handler:  app.routes(),    // Use the router returned by app.routes()
+		ErrorLog: errorLog,
+		// Set timeouts to improve security and resource management (see config.go for defaults).
+		IdleTimeout:  app.config.Server.IdleTimeout,  // Max time for idle connections
+		ReadTimeout:  app.config.Server.ReadTimeout,  // Max time to read request headers/body
+		WriteTimeout: app.config.Server.WriteTimeout, // Max time to write response
+	}
+
+	if !app.config.tlsEnabled() {
+		// Start the HTTP server. ListenAndServe blocks until an error occurs
+		// (e.g., port already in use) or the server is shut down gracefully.
+		return srv.ListenAndServe()
 	}
 
-	// Log the server start address (this was already in main, but doesn't hurt here too for clarity).
-	// app.logger.Info("starting server", "addr", srv.Addr) // This line is usually in main() before calling serve()
+	// --- HTTPS ---
+	reloader, err := newCertReloader(app.config.TLS.CertFile, app.config.TLS.KeyFile, app.logger)
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = newTLSConfig(reloader)
+
+	// Stop background goroutines when the main server returns.
+	done := make(chan struct{})
+	defer close(done)
+	go reloader.watch(app.config.TLS.ReloadInterval, done)
+
+	if app.config.TLS.RedirectAddr != "" {
+		redirectSrv := &http.Server{
+			Addr:         app.config.TLS.RedirectAddr,
+			Handler:      redirectToHTTPS(app.config.Addr),
+			ErrorLog:     errorLog,
+			IdleTimeout:  app.config.Server.IdleTimeout,
+			ReadTimeout:  app.config.Server.ReadTimeout,
+			WriteTimeout: app.config.Server.WriteTimeout,
+		}
+		go func() {
+			app.logger.Info("starting HTTP redirect server", "address", redirectSrv.Addr)
+			err := redirectSrv.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("HTTP redirect server error", "error", err)
+			}
+		}()
+		defer redirectSrv.Close()
+	}
 
-	// Start the HTTP server. ListenAndServe blocks until an error occurs
-	// (e.g., port already in use) or the server is shut down gracefully.
-	return srv.ListenAndServe()
-}
\ No newline at end of file
+	// Certificates come from TLSConfig.GetCertificate, so no file names are passed here.
+	return srv.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,73 @@
+// cmd/web/session.go
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// sessionCookie identifies a browser that has loaded one of the app's pages. It
+// lets the app's own scripts and service worker use the JSON API without a
+// personal token. It is not a login: the pages themselves are open to anyone
+// who can reach the app, so neither it nor a token's scopes are an access
+// boundary until the app has authentication.
+const sessionCookie = "session"
+
+// csrfHeader carries the CSRF token that goes with the session cookie. Other
+// sites can neither read the token nor, without CORS, send the header.
+const csrfHeader = "X-CSRF-Token"
+
+// sessionIDLength is the length of a session ID: 32 random bytes, base64url.
+const sessionIDLength = 43
+
+// browserSession returns the CSRF token for r's session, starting a session
+// (and setting its cookie on w) when r has none.
+func (app *application) browserSession(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil && len(cookie.Value) == sessionIDLength {
+		return app.sign("csrf", cookie.Value), nil
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		Secure:   r.TLS != nil || strings.HasPrefix(app.config.BaseURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return app.sign("csrf", id), nil
+}
+
+// validBrowserSession reports whether r carries a session cookie and the CSRF
+// token that goes with it.
+func (app *application) validBrowserSession(r *http.Request) bool {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || len(cookie.Value) != sessionIDLength {
+		return false
+	}
+	token := r.Header.Get(csrfHeader)
+	return token != "" && app.verifySignature(token, "csrf", cookie.Value)
+}
+
+// showCSRFToken returns the CSRF token for the browser's session, for the
+// service worker, which has no page to read it from.
+func (app *application) showCSRFToken(w http.ResponseWriter, r *http.Request) {
+	token, err := app.browserSession(w, r)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"csrf_token": token})
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
@@ -0,0 +1,148 @@
+// cmd/web/share_test.go
+
+//go:build cgo
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// TestSharePasscodeAudit checks that passcode attempts on a share link are in
+// the audit log, right and wrong, and that only the right one counts as a view.
+// It also checks that the page gives the visitor none of the app's navigation,
+// service worker or API session.
+func TestSharePasscodeAudit(t *testing.T) {
+	app := newEmailTestApp(t, newFakeSMTP(t))
+	app.noteShares = app.models.NoteShares
+	app.auditEvents = app.models.AuditEvents
+	app.shareUnlocks = newShareUnlockLimiter()
+
+	note := &data.MoodNote{Title: "Shared", Content: "Body", OccurredAt: time.Now().Add(-time.Hour)}
+	err := app.moodNotes.Insert(note, data.AuditInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	share, err := app.noteShares.Insert(note.ID, time.Time{}, "open sesame", data.AuditInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		passcode   string
+		wantStatus int
+	}{
+		{"guess", http.StatusUnauthorized},
+		{"open sesame", http.StatusOK},
+	} {
+		form := url.Values{"passcode": {tt.passcode}}
+		r := httptest.NewRequest(http.MethodPost, "/s/"+share.Token, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetPathValue("token", share.Token)
+		rr := httptest.NewRecorder()
+		app.unlockSharedNote(rr, r)
+		if rr.Code != tt.wantStatus {
+			t.Errorf("passcode %q: status = %d; want %d", tt.passcode, rr.Code, tt.wantStatus)
+		}
+		if strings.Contains(rr.Body.String(), "/settings/") {
+			t.Errorf("passcode %q: shared page links to the owner's settings", tt.passcode)
+		}
+		// No manifest means main.js installs no service worker.
+		if strings.Contains(rr.Body.String(), `rel="manifest"`) || strings.Contains(rr.Body.String(), "csrf-token") {
+			t.Errorf("passcode %q: shared page links the manifest or a CSRF token", tt.passcode)
+		}
+		if cookies := rr.Result().Cookies(); len(cookies) != 0 {
+			t.Errorf("passcode %q: shared page set cookies %v", tt.passcode, cookies)
+		}
+	}
+
+	events, _, err := app.auditEvents.GetPage(data.AuditFilter{TargetType: "share", TargetID: share.ID, Actor: shareVisitorActor}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	want := []string{data.AuditShareUnlock, data.AuditShareUnlockFail} // Newest first
+	if strings.Join(actions, " ") != strings.Join(want, " ") {
+		t.Errorf("visitor events = %v; want %v", actions, want)
+	}
+
+	shares, err := app.noteShares.GetAllForNote(note.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 1 || shares[0].Views != 1 {
+		t.Errorf("views = %+v; want one share viewed once", shares)
+	}
+}
+
+// TestSharePasscodeAttemptLimit checks that a share link stops checking
+// passcodes after shareUnlockAttempts tries, whichever addresses they come from.
+func TestSharePasscodeAttemptLimit(t *testing.T) {
+	app := newEmailTestApp(t, newFakeSMTP(t))
+	app.noteShares = app.models.NoteShares
+	app.auditEvents = app.models.AuditEvents
+	app.shareUnlocks = newShareUnlockLimiter()
+
+	note := &data.MoodNote{Title: "Shared", Content: "The secret part", OccurredAt: time.Now().Add(-time.Hour)}
+	err := app.moodNotes.Insert(note, data.AuditInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	share, err := app.noteShares.Insert(note.ID, time.Time{}, "1234", data.AuditInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := app.noteShares.Insert(note.ID, time.Time{}, "1234", data.AuditInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlock := func(token, passcode string, n int) *httptest.ResponseRecorder {
+		form := url.Values{"passcode": {passcode}}
+		r := httptest.NewRequest(http.MethodPost, "/s/"+token, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = fmt.Sprintf("198.51.100.%d:1234", n)
+		r.SetPathValue("token", token)
+		rr := httptest.NewRecorder()
+		app.unlockSharedNote(rr, r)
+		return rr
+	}
+
+	for n := range shareUnlockAttempts {
+		if rr := unlock(share.Token, fmt.Sprintf("%04d", n), n); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: status = %d; want %d", n+1, rr.Code, http.StatusUnauthorized)
+		}
+	}
+	// Even the right passcode, from a new address, now waits.
+	rr := unlock(share.Token, "1234", 99)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("after %d guesses: status = %d, Retry-After %q; want 429 with Retry-After", shareUnlockAttempts, rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if strings.Contains(rr.Body.String(), "The secret part") || !strings.Contains(rr.Body.String(), "tried too many times") {
+		t.Errorf("locked share page:\n%s", rr.Body.String())
+	}
+	// Other links to the same note have attempts of their own.
+	if rr := unlock(other.Token, "1234", 99); rr.Code != http.StatusOK {
+		t.Errorf("other share: status = %d; want %d", rr.Code, http.StatusOK)
+	}
+
+	// Only checked passcodes are in the audit log.
+	fails, _, err := app.auditEvents.GetPage(data.AuditFilter{Action: data.AuditShareUnlockFail}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fails) != shareUnlockAttempts {
+		t.Errorf("%d unlock_fail events; want %d", len(fails), shareUnlockAttempts)
+	}
+}
@@ -0,0 +1,120 @@
+// cmd/web/smtp_test.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is an SMTP server on a loopback port that keeps what it receives.
+// It offers neither STARTTLS nor AUTH, so net/smtp sends in the clear.
+type fakeSMTP struct {
+	ln   net.Listener
+	host string
+	port int
+
+	mu       sync.Mutex
+	messages []*mail.Message
+	rejects  int // How many more messages to refuse after DATA
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, host: host}
+	s.port, _ = strconv.Atoi(port)
+
+	var wg sync.WaitGroup
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		wg.Wait()
+	})
+	return s
+}
+
+// rejectNext makes the server refuse the next n messages with a permanent error.
+func (s *fakeSMTP) rejectNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejects = n
+}
+
+// received returns the messages accepted so far.
+func (s *fakeSMTP) received() []*mail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*mail.Message(nil), s.messages...)
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(line string) { tp.PrintfLine("%s", line) }
+
+	reply("220 localhost ESMTP fake")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			body, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			rejected := s.rejects > 0
+			if rejected {
+				s.rejects--
+			} else if msg, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(body))); err == nil {
+				s.messages = append(s.messages, msg)
+			}
+			s.mu.Unlock()
+			if rejected {
+				reply("554 Transaction failed")
+			} else {
+				reply("250 Queued")
+			}
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
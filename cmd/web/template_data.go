@@ -2,6 +2,8 @@
 package main
 
 import (
+	"fmt"
+	"slices"
 	"time" // Added for CurrentYear
 
 	"github.com/mickali02/mood-notes-app/internal/data"
@@ -13,12 +15,49 @@ type TemplateData struct {
 	CurrentYear int              // Example: To display in footer
 	Flash       string           // For success/error messages (implement later with sessions)
 	Notes       []*data.MoodNote // For the home page list
-	Note        *data.MoodNote   // For pre-filling the edit form
+	Note        *data.MoodNote   // For pre-filling the edit form and the detail page
+	PrevNote    *data.MoodNote   // Older neighbour of Note on the detail page (nil if none)
+	NextNote    *data.MoodNote   // Newer neighbour of Note on the detail page (nil if none)
 
 	// Form Handling - use 'any' for flexibility or specific structs
 	// This allows passing either MoodNoteCreateForm or MoodNoteEditForm
 	Form any
 
+	NoteGroups []data.NoteGroup // Home page notes bucketed by day/week/month in the reader's timezone
+	GroupBy    data.Period      // Which period NoteGroups uses
+
+	Shares     []*data.NoteShare // Live share links of Note on the sharing page
+	ShareURL   string            // Link just created on the sharing page (shown once)
+	ShareToken string            // Token of the share link being viewed on a shared page
+	Public     bool              // A page for share link visitors: the layout leaves out the app's navigation, manifest and session
+
+	AuditEvents  []*data.AuditEvent // One page of the activity log
+	AuditFilter  data.AuditFilter   // Filter applied to AuditEvents
+	AuditActions []string           // Choices for the action filter
+	PageInfo     data.PageInfo      // Position of the current page in a paginated list
+	PrevPageURL  string             // Empty on the first page
+	NextPageURL  string             // Empty on the last page
+
+	DataExports []*data.DataExport // Recent "download all my data" requests
+	Erasure     *data.DataErasure  // Scheduled erasure, nil if none
+
+	NoteTemplates    []*data.NoteTemplate   // The owner's templates (picker and management page)
+	BuiltinTemplates []data.BuiltinTemplate // Templates that ship with the app
+	Prompt           string                 // Prompt of the day on the new entry form
+
+	Draft *data.Draft // Autosaved draft the note form offers to restore, nil if none
+
+	Attachments []*data.Attachment // Images and audio clips of Note on the detail page
+
+	APITokens   []*data.APIToken // Personal API tokens on the settings page
+	NewAPIToken string           // Token just created on the settings page (shown once)
+
+	// Set by render() for every page.
+	CSPNonce  string         // Nonce for inline <script>/<style> tags allowed by the Content-Security-Policy
+	CSRFToken string         // Lets main.js call the JSON API with the browser's session; see session.go
+	Location  *time.Location // Reader's timezone; all note timestamps are converted to it before rendering
+	Now       time.Time      // Current time in Location (e.g. the max for date inputs)
+
 	// You might add other general page data here later, e.g.,
 	// IsAuthenticated bool
 }
@@ -30,22 +69,204 @@ func newTemplateData() *TemplateData {
 	}
 }
 
+// localize converts every note timestamp in td to loc, so templates (and humanDate)
+// show the writer's local time without needing the location passed around.
+func (td *TemplateData) localize(loc *time.Location) {
+	td.Location = loc
+	notes := append([]*data.MoodNote{td.Note, td.PrevNote, td.NextNote}, td.Notes...)
+	for _, g := range td.NoteGroups {
+		notes = append(notes, g.Notes...)
+	}
+	for _, n := range notes {
+		if n == nil {
+			continue
+		}
+		n.CreatedAt = n.CreatedAt.In(loc)
+		n.UpdatedAt = n.UpdatedAt.In(loc)
+		n.OccurredAt = n.OccurredAt.In(loc)
+	}
+	for _, e := range td.AuditEvents {
+		e.OccurredAt = e.OccurredAt.In(loc)
+	}
+	for _, e := range td.DataExports {
+		e.RequestedAt = e.RequestedAt.In(loc)
+		e.CompletedAt = e.CompletedAt.In(loc)
+		e.ExpiresAt = e.ExpiresAt.In(loc)
+	}
+	if td.Erasure != nil {
+		td.Erasure.RequestedAt = td.Erasure.RequestedAt.In(loc)
+		td.Erasure.ScheduledFor = td.Erasure.ScheduledFor.In(loc)
+	}
+	for _, a := range td.Attachments {
+		a.CreatedAt = a.CreatedAt.In(loc)
+	}
+	if td.Draft != nil {
+		td.Draft.SavedAt = td.Draft.SavedAt.In(loc)
+	}
+	for _, t := range td.NoteTemplates {
+		t.CreatedAt = t.CreatedAt.In(loc)
+		t.UpdatedAt = t.UpdatedAt.In(loc)
+	}
+	for _, t := range td.APITokens {
+		t.CreatedAt = t.CreatedAt.In(loc)
+		t.ExpiresAt = t.ExpiresAt.In(loc)
+		t.LastUsedAt = t.LastUsedAt.In(loc)
+	}
+	for _, s := range td.Shares {
+		s.CreatedAt = s.CreatedAt.In(loc)
+		s.ExpiresAt = s.ExpiresAt.In(loc)
+		s.LastViewedAt = s.LastViewedAt.In(loc)
+	}
+}
+
 // --- Form Structs (for type safety and clarity in handlers/templates) ---
 
 // MoodNoteCreateForm holds the data submitted from the new note form + validation.
 type MoodNoteCreateForm struct {
-	Title   string `form:"title"`   // Tag matches form field name
-	Content string `form:"content"` // Tag matches form field name
+	Title      string `form:"title"`       // Tag matches form field name
+	Content    string `form:"content"`     // Tag matches form field name
+	OccurredAt string `form:"occurred_at"` // Raw datetime-local value, kept as text so it can be redisplayed
 	// Embed validator to carry validation errors.
 	validator.Validator
 }
 
 // MoodNoteEditForm holds data for editing, including ID and Version + validation.
 type MoodNoteEditForm struct {
-	ID      int64 `form:"id"`      // From hidden form field or URL param
+	ID         int64  `form:"id"` // From hidden form field or URL param
+	Title      string `form:"title"`
+	Content    string `form:"content"`
+	OccurredAt string `form:"occurred_at"` // Raw datetime-local value in the writer's timezone
+	Version    int    `form:"version"`     // From hidden form field for optimistic locking
+	// Embed validator to carry validation errors.
+	validator.Validator
+}
+
+// Action returns the URL the create form posts to.
+func (f MoodNoteCreateForm) Action() string {
+	return "/note/new"
+}
+
+// IsEdit lets note_form.tmpl tell the two form types apart.
+func (f MoodNoteCreateForm) IsEdit() bool {
+	return false
+}
+
+// DraftSlot names the slot the form's draft is autosaved to.
+func (f MoodNoteCreateForm) DraftSlot() string {
+	return data.DraftSlotNew
+}
+
+// Action returns the URL the edit form posts to.
+func (f MoodNoteEditForm) Action() string {
+	return fmt.Sprintf("/note/edit/%d", f.ID)
+}
+
+// IsEdit lets note_form.tmpl tell the two form types apart.
+func (f MoodNoteEditForm) IsEdit() bool {
+	return true
+}
+
+// DraftSlot names the slot the form's draft is autosaved to.
+func (f MoodNoteEditForm) DraftSlot() string {
+	return data.DraftSlot(f.ID)
+}
+
+// UnsubscribeForm carries a verified unsubscribe link to the confirmation page.
+type UnsubscribeForm struct {
+	List      string // Email kind, e.g. "weekly-digest"
+	ListName  string // Human-readable name of the list
+	Email     string
+	Signature string
+	Done      bool // True once the opt-out has been recorded
+}
+
+// VerifyEmailForm carries the token from an email verification link.
+type VerifyEmailForm struct {
+	Token string
+	Email string // Set once the token has been used
+	Done  bool   // True once the address has been verified
+	validator.Validator
+}
+
+// ShareExpiry is one of the lifetimes offered for a new share link (or API token).
+type ShareExpiry struct {
+	Value    string        // Form value, e.g. "7d"
+	Label    string        // Shown in the select box
+	Duration time.Duration // Zero for links that never expire
+}
+
+// NoteShareForm holds the options for a new share link + validation.
+type NoteShareForm struct {
+	Expiry   string `form:"expiry"`
+	Passcode string `form:"passcode"` // Optional; never redisplayed
+	validator.Validator
+}
+
+// ExpiryOptions returns the choices for the expiry select box.
+func (f NoteShareForm) ExpiryOptions() []ShareExpiry {
+	return shareExpiries
+}
+
+// APITokenForm holds the name, scopes and lifetime of a new API token + validation.
+type APITokenForm struct {
+	Name   string   `form:"name"`
+	Scopes []string `form:"scopes"`
+	Expiry string   `form:"expiry"`
+	validator.Validator
+}
+
+// ScopeOptions returns the scopes offered as checkboxes.
+func (f APITokenForm) ScopeOptions() []string {
+	return data.APIScopes
+}
+
+// HasScope tells api_tokens.tmpl which scope checkboxes to tick.
+func (f APITokenForm) HasScope(scope string) bool {
+	return slices.Contains(f.Scopes, scope)
+}
+
+// ExpiryOptions returns the choices for the expiry select box.
+func (f APITokenForm) ExpiryOptions() []ShareExpiry {
+	return apiTokenExpiries
+}
+
+// SharedNoteUnlockForm asks for the passcode of a protected share link.
+type SharedNoteUnlockForm struct {
+	Passcode string `form:"passcode"`
+	validator.Validator
+}
+
+// DataErasureForm asks the owner to type a confirmation word before erasing everything.
+type DataErasureForm struct {
+	Confirm string `form:"confirm"`
+	validator.Validator
+}
+
+// AttachmentForm reports problems with a file uploaded on the note detail page.
+// The file itself is streamed, not kept in the form.
+type AttachmentForm struct {
+	validator.Validator
+}
+
+// NoteTemplateForm holds a template being created or edited + validation.
+type NoteTemplateForm struct {
+	ID      int64  `form:"id"` // Zero when creating
+	Name    string `form:"name"`
 	Title   string `form:"title"`
 	Content string `form:"content"`
-	Version int    `form:"version"` // From hidden form field for optimistic locking
-	// Embed validator to carry validation errors.
+	Version int    `form:"version"` // For optimistic locking, as on the note form
 	validator.Validator
-}
\ No newline at end of file
+}
+
+// Action returns the URL the template form posts to.
+func (f NoteTemplateForm) Action() string {
+	if f.IsEdit() {
+		return fmt.Sprintf("/settings/templates/edit/%d", f.ID)
+	}
+	return "/settings/templates/new"
+}
+
+// IsEdit tells template_form.tmpl whether an existing template is being edited.
+func (f NoteTemplateForm) IsEdit() bool {
+	return f.ID > 0
+}
@@ -9,27 +9,108 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/mickali02/mood-notes-app/internal/data"
+	"github.com/mickali02/mood-notes-app/internal/markdown"
 	"github.com/mickali02/mood-notes-app/ui" // Import the ui package with embedded files
-	// internal/data import might be removed if no template funcs need it directly
 )
 
+// noteRenderer turns note content (Markdown) into sanitised HTML.
+// It caches up to 1000 rendered notes, keyed by ID and version.
+var noteRenderer = markdown.New(1000)
+
 // Define a template function map
+// NOTE: there is deliberately no "safeHTML" helper. The only route from user
+// content to unescaped HTML is the sanitising markdown renderer below.
 var functions = template.FuncMap{
 	"humanDate": humanDate,
-	"safeHTML": func(s string) template.HTML {
-		return template.HTML(s)
-	},
+	"groupDate": groupDate,
+	"markdown":  renderNoteContent,
+	"truncate":  truncate,
+	"excerpt":   excerpt,
+	"fileSize":  fileSize,
+	"asset":     asset,
 	// Add more functions if needed
 }
 
+// truncate shortens s to at most n characters (runes, not bytes, so multi-byte
+// characters are never cut in half), preferring to break at a word boundary.
+// Usage in templates: {{.Title | truncate 60}}
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if n <= 0 || len(runes) <= n {
+		return s
+	}
+	cut := runes[:n]
+	// Back up to the last space, unless that would throw away more than half the text.
+	for i := len(cut) - 1; i >= n/2; i-- {
+		if unicode.IsSpace(cut[i]) {
+			cut = cut[:i]
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(cut), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
+
+// excerpt returns a plain-text preview of a note's content of at most n characters.
+// It works on the rendered text rather than the Markdown source, so a cut can't
+// leave an unclosed "**" or half a link behind.
+// Usage in templates: {{excerpt 200 .}}
+func excerpt(n int, note *data.MoodNote) (string, error) {
+	if note == nil {
+		return "", nil
+	}
+	text, err := noteRenderer.NoteText(note.ID, note.Version, note.Content)
+	if err != nil {
+		return "", err
+	}
+	return truncate(n, text), nil
+}
+
+// renderNoteContent renders a saved note's Markdown content as sanitised HTML.
+func renderNoteContent(note *data.MoodNote) (template.HTML, error) {
+	if note == nil {
+		return "", nil
+	}
+	return noteRenderer.RenderNote(note.ID, note.Version, note.Content)
+}
+
 // humanDate formats a time.Time object nicely for display.
 func humanDate(t time.Time) string {
 	if t.IsZero() {
 		return ""
 	}
-	// Example format: "Monday, Jan 02, 2006 at 03:04 PM" (Uses local time)
-	return t.Local().Format("Monday, Jan 02, 2006 at 03:04 PM")
+	// Example format: "Monday, Jan 02, 2006 at 03:04 PM"
+	// Times arrive already converted to the reader's timezone (see TemplateData.localize),
+	// so the server's own zone never leaks into what the writer sees.
+	return t.Format("Monday, Jan 02, 2006 at 03:04 PM")
+}
+
+// fileSize formats a size in bytes for people, e.g. "2.5 MB".
+func fileSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/(1<<20)), ".0") + " MB"
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", (n+1<<9)>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// groupDate labels the start of a day, week or month heading on the home page.
+func groupDate(start time.Time, p data.Period) string {
+	switch p {
+	case data.PeriodMonth:
+		return start.Format("January 2006")
+	case data.PeriodWeek:
+		return "Week of " + start.Format("Monday, Jan 02, 2006")
+	default:
+		return start.Format("Monday, Jan 02, 2006")
+	}
 }
 
 // newTemplateCache parses all template files (*.tmpl) from the embedded filesystem (ui.Files)
@@ -50,15 +131,10 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	for _, page := range pages {
 		name := filepath.Base(page) // Get the filename (e.g., "home.tmpl")
 
-		// 3. Create a new template set for this page, add functions.
-		// Start by parsing the specific page file itself.
-		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, page)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing page %s: %w", name, err)
-		}
-
-		// 4. Parse the base layout template into the set, using the simpler name.
-		ts, err = ts.ParseFS(ui.Files, "html/layouts/base.tmpl") // CHANGED path
+		// 3. Create a new template set for this page, add functions, and parse the
+		// base layout into it first. The layout's {{block}} defaults must be parsed
+		// BEFORE the page, otherwise they overwrite the page's "title"/"main" definitions.
+		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, "html/layouts/base.tmpl") // CHANGED path
 		if err != nil {
 			// Check if the base layout exists - critical error if not
 			if errors.Is(err, fs.ErrNotExist) {
@@ -67,7 +143,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 			return nil, fmt.Errorf("error parsing layout for %s: %w", name, err)
 		}
 
-		// 5. Find and parse all partial templates (*.tmpl) into the set.
+		// 4. Find and parse all partial templates (*.tmpl) into the set.
 		partials, err := fs.Glob(ui.Files, "html/partials/*.tmpl") // CHANGED pattern
 		if err != nil {
 			// If Glob itself fails (other than not finding files)
@@ -85,6 +161,12 @@ func newTemplateCache() (map[string]*template.Template, error) {
 			}
 		}
 
+		// 5. Parse the specific page file last so its blocks win.
+		ts, err = ts.ParseFS(ui.Files, page)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing page %s: %w", name, err)
+		}
+
 		// 6. Add the fully parsed template set to the cache map.
 		// The key is the page filename, e.g., "home.tmpl"
 		cache[name] = ts
@@ -0,0 +1,82 @@
+// cmd/web/templates_test.go
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		s    string
+		want string
+	}{
+		{"short enough", 10, "calm day", "calm day"},
+		{"exactly n", 8, "calm day", "calm day"},
+		{"no limit", 0, "calm day", "calm day"},
+		{"at a space", 10, "a calm day by the sea", "a calm…"},
+		{"no space in the second half", 6, "wonderful", "wonder…"},
+		{"trailing punctuation", 12, "tired, sad; hopeful", "tired, sad…"},
+		{"accented", 7, "café crème brûlée", "café…"},
+		{"accented mid-word", 3, "éèêëē", "éèê…"},
+		{"cjk", 4, "今日はとても良い日でした", "今日はと…"},
+		{"emoji", 3, "😀😢😡😴", "😀😢😡…"},
+		{"emoji words", 8, "feeling 😀 then 😢 later", "feeling…"},
+		{"emoji at the cut", 9, "so happy 🥳🥳🥳 today", "so happy…"},
+		{"flag emoji", 2, "🇧🇿🇧🇿", "🇧🇿…"},
+		{"combining accent", 3, "e\u0301e\u0301e\u0301", "e\u0301e…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.n, tt.s)
+			if !utf8.ValidString(got) {
+				t.Fatalf("truncate(%d, %q) = %q, which is not valid UTF-8", tt.n, tt.s, got)
+			}
+			if got != tt.want {
+				t.Errorf("truncate(%d, %q) = %q; want %q", tt.n, tt.s, got, tt.want)
+			}
+			if body := []rune(got); tt.n > 0 && len(body) > tt.n+1 {
+				t.Errorf("truncate(%d, %q) is %d characters long", tt.n, tt.s, len(body))
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		content string
+		want    string
+	}{
+		{"link cut mid-text", 12, "Read [this lovely post](https://example.com/post) today", "Read this…"},
+		{"link url never shown", 40, "See [the park](https://example.com/a-very-long-url)", "See the park"},
+		{"emphasis", 14, "I felt **really quite** *calm* today", "I felt really…"},
+		{"unclosed emphasis", 9, "**bold and never closed", "**bold…"},
+		{"code span", 15, "Ran `go test ./...` and it passed", "Ran go test…"},
+		{"heading and list", 30, "# Morning\n\n- coffee\n- *walk*\n- journal", "Morning coffee walk journal"},
+		{"entities", 20, "Fish &amp; chips & peas", "Fish & chips & peas"},
+		{"emoji in emphasis", 6, "**😀😀😀😀😀😀😀**", "😀😀😀😀😀😀…"},
+		{"raw html", 30, "<b>bold</b> <script>alert(1)</script> plain", "bold alert(1) plain"}, // Tags dropped, text kept as text
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The renderer caches by (id, version), so give every case its own ID.
+			note := &data.MoodNote{ID: int64(1_000_000 + i), Version: 1, Content: tt.content}
+			got, err := excerpt(tt.n, note)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !utf8.ValidString(got) {
+				t.Fatalf("excerpt = %q, which is not valid UTF-8", got)
+			}
+			if got != tt.want {
+				t.Errorf("excerpt(%d, %q) = %q; want %q", tt.n, tt.content, got, tt.want)
+			}
+		})
+	}
+}
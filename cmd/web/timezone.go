@@ -0,0 +1,51 @@
+// cmd/web/timezone.go
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// timezoneCookie holds the IANA timezone reported by the reader's browser.
+// It is set by /static/js/main.js; there are no user accounts yet, so the
+// browser is where the writer's zone is remembered.
+const timezoneCookie = "tz"
+
+// timezoneRX accepts IANA names such as "America/Belize" or "Etc/GMT+6".
+var timezoneRX = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_+\-]*(/[A-Za-z0-9_+\-]+)*$`)
+
+// locationCache avoids re-reading zoneinfo for every request.
+var locationCache sync.Map // map[string]*time.Location
+
+// loadLocation returns the named timezone, using the cache when possible.
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+// location returns the timezone to show dates in for this request: the browser's
+// zone from the tz cookie when it is valid, otherwise the configured default.
+func (app *application) location(r *http.Request) *time.Location {
+	cookie, err := r.Cookie(timezoneCookie)
+	if err != nil {
+		return app.defaultLocation
+	}
+	name := cookie.Value
+	if len(name) > 64 || name == "Local" || !timezoneRX.MatchString(name) {
+		return app.defaultLocation
+	}
+	loc, err := loadLocation(name)
+	if err != nil {
+		return app.defaultLocation
+	}
+	return loc
+}
@@ -0,0 +1,90 @@
+// cmd/web/timezone_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+func TestLocation(t *testing.T) {
+	app := &application{defaultLocation: time.UTC}
+	tests := []struct {
+		cookie string // "" sends none
+		want   string
+	}{
+		{"", "UTC"},
+		{"America/New_York", "America/New_York"},
+		{"America/Argentina/Buenos_Aires", "America/Argentina/Buenos_Aires"},
+		{"Etc/GMT+6", "Etc/GMT+6"},
+		{"Australia/Lord_Howe", "Australia/Lord_Howe"},
+		{"Local", "UTC"},                 // The server's zone, not the reader's
+		{"Mars/Olympus_Mons", "UTC"},     // Unknown
+		{"../../etc/passwd", "UTC"},      // Not a zone name
+		{"America/New_York/..", "UTC"},   // Not a zone name
+		{strings.Repeat("A", 65), "UTC"}, // Too long
+		{"America/" + strings.Repeat("A", 60), "UTC"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: timezoneCookie, Value: tt.cookie})
+		}
+		if got := app.location(r).String(); got != tt.want {
+			t.Errorf("tz cookie %q: location %s; want %s", tt.cookie, got, tt.want)
+		}
+	}
+}
+
+// TestLocationDays checks that the reader's zone decides which local day an
+// entry falls on, including on days when the clocks change.
+func TestLocationDays(t *testing.T) {
+	app := &application{defaultLocation: time.UTC}
+	tests := []struct {
+		zone      string
+		at        string // An entry's time
+		wantStart string // Of its local day
+		wantHours float64
+	}{
+		{"America/New_York", "2026-03-08T12:00:00Z", "2026-03-08T00:00:00-05:00", 23},
+		{"America/New_York", "2026-03-09T03:59:00Z", "2026-03-08T00:00:00-05:00", 23},
+		{"America/New_York", "2026-11-01T05:30:00Z", "2026-11-01T00:00:00-04:00", 25}, // The first 01:30
+		{"America/New_York", "2026-11-01T06:30:00Z", "2026-11-01T00:00:00-04:00", 25}, // The second
+		{"Europe/London", "2026-03-29T00:30:00Z", "2026-03-29T00:00:00Z", 23},
+		{"Europe/London", "2026-10-25T23:30:00Z", "2026-10-25T00:00:00+01:00", 25},
+		{"America/Sao_Paulo", "2018-11-04T03:00:00Z", "2018-11-04T01:00:00-02:00", 23}, // Began at 01:00
+		{"America/Sao_Paulo", "2019-02-17T02:30:00Z", "2019-02-16T00:00:00-02:00", 25}, // The second 23:30
+		{"Australia/Lord_Howe", "2026-10-03T12:00:00Z", "2026-10-03T00:00:00+10:30", 24},
+		{"Australia/Lord_Howe", "2026-10-04T12:00:00Z", "2026-10-04T00:00:00+10:30", 23.5}, // 23:00 after a half-hour jump
+		{"UTC", "2026-03-08T12:00:00Z", "2026-03-08T00:00:00Z", 24},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: timezoneCookie, Value: tt.zone})
+		loc := app.location(r)
+		if loc.String() != tt.zone {
+			t.Fatalf("tz cookie %q: location %s", tt.zone, loc)
+		}
+
+		at, err := time.Parse(time.RFC3339, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := time.Parse(time.RFC3339, tt.wantStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := data.PeriodStart(at, loc, data.PeriodDay)
+		if !start.Equal(want) {
+			t.Errorf("%s, %s: day starts %s; want %s", tt.zone, tt.at, start, want.In(loc))
+			continue
+		}
+		if hours := data.PeriodEnd(start, data.PeriodDay).Sub(start).Hours(); hours != tt.wantHours {
+			t.Errorf("%s, day starting %s: %v hours long; want %v", tt.zone, start, hours, tt.wantHours)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// cmd/web/tls.go
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newTLSConfig returns a tls.Config restricted to modern protocol versions and ciphers.
+// Certificates are served by the reloader so they can change without a restart.
+func newTLSConfig(reloader *certReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		// Only used for TLS 1.2; TLS 1.3 suites are not configurable and are all strong.
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		GetCertificate: reloader.GetCertificate,
+	}
+}
+
+// --- Certificate Hot-Reload ---
+
+// certReloader keeps the current certificate in memory and reloads it
+// whenever the certificate or key file changes on disk.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time // Modification times of the files the current cert was loaded from
+	keyMod  time.Time
+}
+
+// newCertReloader loads the certificate pair once, failing if it cannot be read.
+func newCertReloader(certFile, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+	err := cr.reload()
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// GetCertificate satisfies tls.Config.GetCertificate.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// reload reads the certificate pair from disk and swaps it in.
+func (cr *certReloader) reload() error {
+	certMod, keyMod, err := cr.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.certMod = certMod
+	cr.keyMod = keyMod
+	cr.mu.Unlock()
+	return nil
+}
+
+// modTimes returns the current modification times of the certificate and key files.
+func (cr *certReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(cr.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("checking TLS certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(cr.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("checking TLS key: %w", err)
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// changed reports whether either file has been modified since the last successful load.
+func (cr *certReloader) changed() (bool, error) {
+	certMod, keyMod, err := cr.modTimes()
+	if err != nil {
+		return false, err
+	}
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return !certMod.Equal(cr.certMod) || !keyMod.Equal(cr.keyMod), nil
+}
+
+// watch polls the files every interval until done is closed. Polling keeps us on the
+// standard library and copes with the rename-based updates used by certbot and Kubernetes.
+// A failed reload (e.g. the key was written before the new cert) keeps the old certificate
+// and is retried on the next tick.
+func (cr *certReloader) watch(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			changed, err := cr.changed()
+			if err != nil {
+				cr.logger.Error("TLS certificate check failed", "error", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			err = cr.reload()
+			if err != nil {
+				cr.logger.Error("TLS certificate reload failed, keeping previous certificate", "error", err)
+				continue
+			}
+			cr.logger.Info("TLS certificate reloaded", "cert", cr.certFile)
+		}
+	}
+}
+
+// --- HTTP to HTTPS Redirect ---
+
+// redirectToHTTPS returns a handler that sends every plain-HTTP request to the
+// same path on the HTTPS listener at httpsAddr.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		// Only add the port when HTTPS isn't on the default one.
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+
+		w.Header().Set("Connection", "close")
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,215 @@
+// cmd/web/tls_test.go
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a fresh self-signed certificate for localhost to
+// certFile and keyFile and returns it.
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	if err == nil {
+		err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// touch moves a file's modification time forward, so a rewrite within the
+// file system's timestamp granularity still counts as a change.
+func touch(t *testing.T, name string, at time.Time) {
+	t.Helper()
+	err := os.Chtimes(name, at, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedSerial(t *testing.T, cr *certReloader) int64 {
+	t.Helper()
+	cert, err := cr.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, 1)
+
+	cr, err := newCertReloader(certFile, keyFile, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedSerial(t, cr); got != 1 {
+		t.Fatalf("serving certificate %d; want 1", got)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go cr.watch(10*time.Millisecond, done)
+
+	waitForSerial := func(want int64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for servedSerial(t, cr) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("still serving certificate %d; want %d", servedSerial(t, cr), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A renewed certificate is picked up.
+	writeTestCert(t, certFile, keyFile, 2)
+	next := time.Now().Add(time.Minute)
+	touch(t, certFile, next)
+	touch(t, keyFile, next)
+	waitForSerial(2)
+
+	// A broken pair (a half-finished renewal) keeps the last good certificate...
+	err = os.WriteFile(certFile, []byte("not a certificate"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	touch(t, certFile, next.Add(time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if got := servedSerial(t, cr); got != 2 {
+		t.Fatalf("serving certificate %d after a failed reload; want 2", got)
+	}
+
+	// ...until the renewal is complete.
+	writeTestCert(t, certFile, keyFile, 3)
+	next = next.Add(2 * time.Minute)
+	touch(t, certFile, next)
+	touch(t, keyFile, next)
+	waitForSerial(3)
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		httpsAddr string
+		host      string
+		target    string
+		want      string
+	}{
+		{":443", "example.com", "/notes?page=2", "https://example.com/notes?page=2"},
+		{":443", "example.com:80", "/", "https://example.com/"},
+		{":8443", "example.com:8080", "/note/view/1", "https://example.com:8443/note/view/1"},
+		{"0.0.0.0:8443", "[::1]:8080", "/", "https://[::1]:8443/"},
+		{":443", "[::1]", "/", "https://[::1]/"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r.Host = tt.host
+		rr := httptest.NewRecorder()
+		redirectToHTTPS(tt.httpsAddr).ServeHTTP(rr, r)
+
+		if rr.Code != http.StatusMovedPermanently {
+			t.Errorf("%s%s with HTTPS on %s: status %d; want %d", tt.host, tt.target, tt.httpsAddr, rr.Code, http.StatusMovedPermanently)
+		}
+		if got := rr.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s%s with HTTPS on %s: redirected to %q; want %q", tt.host, tt.target, tt.httpsAddr, got, tt.want)
+		}
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	cert := writeTestCert(t, certFile, keyFile, 1)
+	cr, err := newCertReloader(certFile, keyFile, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{}
+	app.config.TLS.HSTSMaxAge = 24 * time.Hour
+	handler := app.hsts(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Served like serve does, rather than with httptest's own certificate.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpsSrv := &http.Server{Handler: handler, TLSConfig: newTLSConfig(cr)}
+	go httpsSrv.ServeTLS(ln, "", "")
+	defer httpsSrv.Close()
+	httpsURL := "https://" + ln.Addr().String()
+	httpSrv := httptest.NewServer(handler)
+	defer httpSrv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{httpsURL, "max-age=86400; includeSubDomains"},
+		{httpSrv.URL, ""},
+	}
+	for _, tt := range tests {
+		resp, err := client.Get(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("Strict-Transport-Security"); got != tt.want {
+			t.Errorf("GET %s: Strict-Transport-Security %q; want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// cmd/web/verification.go
+package main
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/data"
+)
+
+// verificationKind identifies verification emails in email_sends, which also
+// limits them to one per address per local day.
+const verificationKind = "email-verification"
+
+// verificationTokenTTL is how long the link in a verification email works.
+const verificationTokenTTL = 3 * 24 * time.Hour
+
+// verificationEmailData is passed to ui/email/verify_email.tmpl.
+type verificationEmailData struct {
+	Email     string
+	VerifyURL string
+	Expires   string // e.g. "Wednesday, Oct 21"
+}
+
+// recipientVerified reports whether scheduled emails may go to recipient. Until
+// someone follows the link in a verification email, nothing else is sent there,
+// so a mistyped address never receives journal excerpts. An unverified
+// recipient is sent that email instead, at most once per local day.
+func (app *application) recipientVerified(recipient string, now time.Time, loc *time.Location) (bool, error) {
+	verified, err := app.verifiedEmails.Exists(recipient)
+	if err != nil || verified {
+		return verified, err
+	}
+
+	dayStart := data.PeriodStart(now, loc, data.PeriodDay)
+	claimed, err := app.emailSends.Claim(verificationKind, recipient, dayStart)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	err = app.sendVerification(recipient, loc)
+	if err != nil {
+		// Give the claim back so the next tick retries.
+		releaseErr := app.emailSends.Release(verificationKind, recipient, dayStart)
+		if releaseErr != nil {
+			app.logger.Error("could not release verification claim", "error", releaseErr)
+		}
+		return false, err
+	}
+
+	app.logger.Info("verification email sent", "recipient", recipient)
+	return false, nil
+}
+
+// sendVerification emails recipient a single-use link that verifies the address.
+func (app *application) sendVerification(recipient string, loc *time.Location) error {
+	token, err := app.tokens.New(data.TokenScopeEmailVerification, recipient, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+	return app.mailer.Send(recipient, "verify_email.tmpl", verificationEmailData{
+		Email:     recipient,
+		VerifyURL: app.config.BaseURL + "/email/verify?token=" + url.QueryEscape(token.Plaintext),
+		Expires:   token.Expiry.In(loc).Format("Monday, Jan 02"),
+	})
+}
@@ -3,3 +3,19 @@ module github.com/mickali02/mood-notes-app
 go 1.23.5
 
 require github.com/lib/pq v1.10.9
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/microcosm-cc/bluemonday v1.0.27
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/crypto v0.31.0
+	golang.org/x/image v0.25.0
+	golang.org/x/time v0.8.0
+)
+
+require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+	golang.org/x/net v0.26.0 // indirect
+)
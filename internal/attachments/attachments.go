@@ -0,0 +1,98 @@
+// internal/attachments/attachments.go
+
+// Package attachments checks and prepares files uploaded to a note: it works
+// out what a file really is from its first bytes, strips location data from
+// images and makes their thumbnails. It only deals with bytes; storing them is
+// up to internal/blobstore and recording them up to internal/data.
+package attachments
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+)
+
+// Errors returned for uploads the app won't store.
+var (
+	ErrUnsupportedType = errors.New("attachments: unsupported file type")
+	ErrBadImage        = errors.New("attachments: image could not be read")
+	ErrImageTooLarge   = errors.New("attachments: image has too many pixels")
+)
+
+// Kinds of attachment, matching the values stored in the attachments table.
+const (
+	KindImage = "image"
+	KindAudio = "audio"
+)
+
+// SniffLen is how many leading bytes Sniff needs (fewer is fine for short files).
+const SniffLen = 512
+
+// Sniff returns the kind and content type of a file from its first bytes. The
+// name and Content-Type sent with the upload are ignored: they are easy to fake,
+// and the content type is what the browser is later told when the file is served.
+func Sniff(head []byte) (kind, contentType string, err error) {
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+	switch ct := http.DetectContentType(head); ct {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return KindImage, ct, nil
+	case "audio/mpeg":
+		return KindAudio, ct, nil
+	case "audio/wave":
+		return KindAudio, "audio/wav", nil
+	case "application/ogg":
+		return KindAudio, "audio/ogg", nil
+	case "video/webm":
+		// Browsers record voice memos as WebM; an <audio> element plays only its sound.
+		return KindAudio, "audio/webm", nil
+	case "video/mp4":
+		// Only accept MP4 files that say they are audio (AAC voice memos, .m4a).
+		if len(head) >= 12 && bytes.Equal(head[8:12], []byte("M4A ")) {
+			return KindAudio, "audio/mp4", nil
+		}
+	}
+	return "", "", ErrUnsupportedType
+}
+
+// Image is an uploaded image made ready to store.
+type Image struct {
+	Data      []byte // The upload with location data removed
+	Width     int    // Size as displayed, i.e. after EXIF orientation
+	Height    int
+	Thumbnail []byte // JPEG, at most ThumbnailSize pixels on each side
+}
+
+// PrepareImage strips location data from an image of the given content type (as
+// returned by Sniff) and makes its thumbnail. Decoding the image also checks that
+// it is what it claims to be, so a file that merely starts like an image is
+// rejected with ErrBadImage.
+func PrepareImage(data []byte, contentType string) (*Image, error) {
+	var (
+		clean       []byte
+		orientation int
+		err         error
+	)
+	switch contentType {
+	case "image/jpeg":
+		clean, orientation, err = stripJPEG(data)
+	case "image/png":
+		clean, orientation, err = stripPNG(data)
+	case "image/webp":
+		clean, orientation, err = stripWebP(data)
+	case "image/gif":
+		clean = data // GIF has no EXIF
+	default:
+		return nil, ErrUnsupportedType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	thumb, width, height, err := thumbnail(clean, orientation)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Data: clean, Width: width, Height: height, Thumbnail: thumb}, nil
+}
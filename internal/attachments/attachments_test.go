@@ -0,0 +1,332 @@
+// internal/attachments/attachments_test.go
+package attachments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// gpsLatitude is the GPSLatitude value in the test EXIF block (51° 30' 26.47"),
+// as three little-endian rationals. It must not survive stripping.
+var gpsLatitude = []byte{
+	51, 0, 0, 0, 1, 0, 0, 0,
+	30, 0, 0, 0, 1, 0, 0, 0,
+	0x57, 0x0A, 0, 0, 100, 0, 0, 0,
+}
+
+// xmpPacket mentions a location the way editors copy it from EXIF.
+const xmpPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description exif:GPSLatitude="51,30.441N"/></rdf:RDF></x:xmpmeta>`
+
+// testExif returns a little-endian TIFF block with an orientation in IFD0 and a
+// GPS IFD holding a latitude reference and the latitude.
+func testExif(orientation uint16) []byte {
+	b := []byte("II*\x00")
+	b = binary.LittleEndian.AppendUint32(b, 8)
+	entry := func(tag, typ uint16, count, value uint32) {
+		b = binary.LittleEndian.AppendUint16(b, tag)
+		b = binary.LittleEndian.AppendUint16(b, typ)
+		b = binary.LittleEndian.AppendUint32(b, count)
+		b = binary.LittleEndian.AppendUint32(b, value)
+	}
+
+	// IFD0 at 8: two entries, ending at 38.
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	entry(tagOrientation, 3, 1, uint32(orientation))
+	entry(tagGPSIFD, 4, 1, 38)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+
+	// GPS IFD at 38: "N" fits in its entry, the latitude is stored at 68.
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	entry(0x0001, 2, 2, binary.LittleEndian.Uint32([]byte("N\x00\x00\x00")))
+	entry(0x0002, 5, 3, 68)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	return append(b, gpsLatitude...)
+}
+
+// halves returns a w×h image, red on the left and blue on the right.
+func halves(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// testJPEG encodes img with EXIF and XMP APP1 segments after the SOI marker.
+func testJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app1 := func(payload []byte) []byte {
+		seg := []byte{0xFF, 0xE1}
+		seg = binary.BigEndian.AppendUint16(seg, uint16(2+len(payload)))
+		return append(seg, payload...)
+	}
+	encoded := buf.Bytes()
+	out := append([]byte{}, encoded[:2]...)
+	out = append(out, app1(append(bytes.Clone(exifPrefix), testExif(orientation)...))...)
+	out = append(out, app1(append(bytes.Clone(xmpPrefixes[0]), xmpPacket...))...)
+	return append(out, encoded[2:]...)
+}
+
+// pngChunk returns a PNG chunk with its length and CRC.
+func pngChunk(typ string, data []byte) []byte {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	c = append(c, typ...)
+	c = append(c, data...)
+	return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+}
+
+// testPNG encodes img with eXIf and XMP iTXt chunks after IHDR.
+func testPNG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	ihdrEnd := len(pngSignature) + 25
+	out := append([]byte{}, encoded[:ihdrEnd]...)
+	out = append(out, pngChunk("eXIf", testExif(orientation))...)
+	out = append(out, pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"+xmpPacket))...)
+	return append(out, encoded[ihdrEnd:]...)
+}
+
+// gpsEntries returns how many entries the GPS IFD of a TIFF block still has.
+func gpsEntries(t *testing.T, tiff []byte) int {
+	t.Helper()
+	entries, ok := exifEntries(tiff, binary.LittleEndian, binary.LittleEndian.Uint32(tiff[4:]))
+	if !ok {
+		t.Fatal("IFD0 unreadable after stripping")
+	}
+	for _, e := range entries {
+		if binary.LittleEndian.Uint16(e) == tagGPSIFD {
+			gps, ok := exifEntries(tiff, binary.LittleEndian, binary.LittleEndian.Uint32(e[8:]))
+			if !ok {
+				t.Fatal("GPS IFD unreadable after stripping")
+			}
+			return len(gps)
+		}
+	}
+	return 0
+}
+
+// checkStripped fails if clean still holds the location or lost the orientation.
+func checkStripped(t *testing.T, clean []byte, exifAt int) {
+	t.Helper()
+	if bytes.Contains(clean, gpsLatitude) {
+		t.Error("GPS latitude survived stripping")
+	}
+	if bytes.Contains(clean, []byte("xmpmeta")) || bytes.Contains(clean, []byte("ns.adobe.com/xap")) {
+		t.Error("XMP packet survived stripping")
+	}
+	if exifAt < 0 {
+		t.Fatal("EXIF block missing after stripping")
+	}
+	tiff := clean[exifAt:]
+	if n := gpsEntries(t, tiff); n != 0 {
+		t.Errorf("GPS IFD has %d entries after stripping; want 0", n)
+	}
+	if o, ok := scrubExif(bytes.Clone(tiff)); !ok || o != 6 {
+		t.Errorf("orientation after stripping = %d, %v; want 6", o, ok)
+	}
+}
+
+func TestPrepareImageStripsLocation(t *testing.T) {
+	img := halves(64, 32)
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+	}{
+		{"jpeg", "image/jpeg", testJPEG(t, img, 6)},
+		{"png", "image/png", testPNG(t, img, 6)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Contains(tt.data, gpsLatitude) || !bytes.Contains(tt.data, []byte("xmpmeta")) {
+				t.Fatal("fixture has no location to strip")
+			}
+			kind, ct, err := Sniff(tt.data)
+			if err != nil || kind != KindImage || ct != tt.contentType {
+				t.Fatalf("Sniff = %q, %q, %v", kind, ct, err)
+			}
+
+			got, err := PrepareImage(tt.data, tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkStripped(t, got.Data, bytes.Index(got.Data, []byte("II*\x00")))
+
+			// The stored file still decodes, unchanged in size.
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(got.Data))
+			if err != nil {
+				t.Fatalf("stored image doesn't decode: %v", err)
+			}
+			_, _, err = image.Decode(bytes.NewReader(got.Data))
+			if err != nil {
+				t.Fatalf("stored image doesn't decode: %v", err)
+			}
+			if cfg.Width != 64 || cfg.Height != 32 {
+				t.Errorf("stored image is %dx%d; want 64x32", cfg.Width, cfg.Height)
+			}
+
+			// Orientation 6 turns the image clockwise: displayed 32x64, red on top.
+			if got.Width != 32 || got.Height != 64 {
+				t.Errorf("displayed size = %dx%d; want 32x64", got.Width, got.Height)
+			}
+			thumb, err := jpeg.Decode(bytes.NewReader(got.Thumbnail))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := thumb.Bounds(); b.Dx() != 32 || b.Dy() != 64 {
+				t.Fatalf("thumbnail is %dx%d; want 32x64", b.Dx(), b.Dy())
+			}
+			top, bottom := thumb.At(16, 8), thumb.At(16, 56)
+			if r, _, b, _ := top.RGBA(); r < 0xC000 || b > 0x4000 {
+				t.Errorf("top of thumbnail = %v; want red", top)
+			}
+			if r, _, b, _ := bottom.RGBA(); b < 0xC000 || r > 0x4000 {
+				t.Errorf("bottom of thumbnail = %v; want blue", bottom)
+			}
+		})
+	}
+}
+
+func TestStripWebP(t *testing.T) {
+	chunk := func(fourcc string, data []byte) []byte {
+		c := append([]byte(fourcc), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+		c = append(c, data...)
+		if len(data)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagEXIF | webpFlagXMP
+	var body []byte
+	body = append(body, "WEBP"...)
+	body = append(body, chunk("VP8X", vp8x)...)
+	body = append(body, chunk("VP8L", []byte{0x2F, 0, 0, 0, 0})...) // Not decoded here
+	body = append(body, chunk("EXIF", append(bytes.Clone(exifPrefix), testExif(6)...))...)
+	body = append(body, chunk("XMP ", []byte(xmpPacket))...)
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	data = append(data, body...)
+
+	clean, orientation, err := stripWebP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orientation != 6 {
+		t.Errorf("orientation = %d; want 6", orientation)
+	}
+	checkStripped(t, clean, bytes.Index(clean, []byte("II*\x00")))
+	if size := binary.LittleEndian.Uint32(clean[4:]); int(size) != len(clean)-8 {
+		t.Errorf("RIFF size = %d; want %d", size, len(clean)-8)
+	}
+	if flags := clean[12+8]; flags != webpFlagEXIF {
+		t.Errorf("VP8X flags = %#x; want only EXIF (%#x)", flags, webpFlagEXIF)
+	}
+}
+
+func TestStripDropsUnreadableExif(t *testing.T) {
+	exif := testExif(6)
+	binary.LittleEndian.PutUint32(exif[4:], 1<<20) // IFD0 past the end
+	app1 := append([]byte{0xFF, 0xE1}, binary.BigEndian.AppendUint16(nil, uint16(2+len(exifPrefix)+len(exif)))...)
+	app1 = append(append(app1, exifPrefix...), exif...)
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, halves(8, 8), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(append([]byte{0xFF, 0xD8}, app1...), buf.Bytes()[2:]...)
+
+	clean, _, err := stripJPEG(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(clean, exifPrefix) || bytes.Contains(clean, gpsLatitude) {
+		t.Error("unreadable EXIF block kept")
+	}
+}
+
+func TestPrepareImageTooLarge(t *testing.T) {
+	// Only the header is needed: the size is checked before decoding the pixels.
+	ihdr := binary.BigEndian.AppendUint32(nil, 10_000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 5_000) // 50MP
+	ihdr = append(ihdr, 8, 2, 0, 0, 0)                // 8-bit RGB
+	data := append(bytes.Clone(pngSignature), pngChunk("IHDR", ihdr)...)
+	data = append(data, pngChunk("IEND", nil)...)
+
+	_, err := PrepareImage(data, "image/png")
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("PrepareImage of a 50MP image = %v; want ErrImageTooLarge", err)
+	}
+}
+
+func TestPrepareImageRejectsFakes(t *testing.T) {
+	// Starts like a JPEG, but isn't one.
+	_, err := PrepareImage([]byte("\xFF\xD8\xFF\xE0 not really a photo"), "image/jpeg")
+	if !errors.Is(err, ErrBadImage) {
+		t.Errorf("PrepareImage of a fake JPEG = %v; want ErrBadImage", err)
+	}
+	_, err = PrepareImage([]byte("GIF89a"), "image/bmp")
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("PrepareImage of image/bmp = %v; want ErrUnsupportedType", err)
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     string
+		wantKind string
+		wantType string
+	}{
+		{"jpeg", "\xFF\xD8\xFF\xE0", KindImage, "image/jpeg"},
+		{"png", "\x89PNG\r\n\x1a\n", KindImage, "image/png"},
+		{"gif", "GIF89a", KindImage, "image/gif"},
+		{"webp", "RIFF\x00\x00\x00\x00WEBPVP8 ", KindImage, "image/webp"},
+		{"mp3", "ID3\x03", KindAudio, "audio/mpeg"},
+		{"wav", "RIFF\x00\x00\x00\x00WAVEfmt ", KindAudio, "audio/wav"},
+		{"ogg", "OggS\x00", KindAudio, "audio/ogg"},
+		{"webm", "\x1A\x45\xDF\xA3", KindAudio, "audio/webm"},
+		{"m4a", "\x00\x00\x00\x1cftypM4A \x00\x00\x00\x00M4A mp42isom", KindAudio, "audio/mp4"},
+		{"mp4 video", "\x00\x00\x00\x1cftypisom\x00\x00\x02\x00isomiso2mp41", "", ""},
+		{"html", "<!DOCTYPE html><script>", "", ""},
+		{"svg", `<svg xmlns="http://www.w3.org/2000/svg">`, "", ""},
+		{"pdf", "%PDF-1.7", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, ct, err := Sniff([]byte(tt.head))
+			if tt.wantKind == "" {
+				if !errors.Is(err, ErrUnsupportedType) {
+					t.Errorf("Sniff = %q, %q, %v; want ErrUnsupportedType", kind, ct, err)
+				}
+				return
+			}
+			if err != nil || kind != tt.wantKind || ct != tt.wantType {
+				t.Errorf("Sniff = %q, %q, %v; want %q, %q", kind, ct, err, tt.wantKind, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+// internal/attachments/exif.go
+package attachments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Phones write where a photo was taken into its EXIF GPS block, and editors may
+// copy it into XMP. The functions below remove both while leaving the rest of the
+// file (and the rest of the EXIF data, such as orientation) byte for byte alone.
+// They also return the EXIF orientation (1-8, or 0 when absent), which the
+// thumbnail needs because it is re-encoded without EXIF.
+
+// exifPrefix starts the EXIF block in JPEG APP1 segments (and some WebP files).
+var exifPrefix = []byte("Exif\x00\x00")
+
+// xmpPrefixes start XMP packets in JPEG APP1 segments.
+var xmpPrefixes = [][]byte{
+	[]byte("http://ns.adobe.com/xap/1.0/\x00"),
+	[]byte("http://ns.adobe.com/xmp/extension/\x00"),
+}
+
+// --- JPEG ---
+
+// stripJPEG removes GPS data from the EXIF segment and drops XMP segments.
+func stripJPEG(b []byte) ([]byte, int, error) {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return nil, 0, ErrBadImage
+	}
+	out := make([]byte, 0, len(b))
+	out = append(out, 0xFF, 0xD8)
+	orientation := 0
+
+	for i := 2; ; {
+		if i+2 > len(b) || b[i] != 0xFF {
+			return nil, 0, ErrBadImage
+		}
+		marker := b[i+1]
+		switch {
+		case marker == 0xFF: // Fill byte
+			i++
+			continue
+		case marker == 0xD9: // End of image
+			return append(out, b[i:]...), orientation, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // No length
+			out = append(out, b[i:i+2]...)
+			i += 2
+			continue
+		}
+
+		if i+4 > len(b) {
+			return nil, 0, ErrBadImage
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(b[i+2:]))
+		if end < i+4 || end > len(b) {
+			return nil, 0, ErrBadImage
+		}
+		segment := bytes.Clone(b[i:end])
+		payload := segment[4:]
+
+		keep := true
+		if marker == 0xE1 { // APP1
+			switch {
+			case bytes.HasPrefix(payload, exifPrefix):
+				o, ok := scrubExif(payload[len(exifPrefix):])
+				keep = ok
+				if ok {
+					orientation = o
+				}
+			case hasAnyPrefix(payload, xmpPrefixes):
+				keep = false
+			}
+		}
+		if keep {
+			out = append(out, segment...)
+		}
+
+		if marker == 0xDA { // Start of scan: the compressed data follows, copy the rest as is
+			return append(out, b[end:]...), orientation, nil
+		}
+		i = end
+	}
+}
+
+// hasAnyPrefix reports whether b starts with any of prefixes.
+func hasAnyPrefix(b []byte, prefixes [][]byte) bool {
+	for _, p := range prefixes {
+		if bytes.HasPrefix(b, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- PNG ---
+
+// pngSignature starts every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// stripPNG removes GPS data from the eXIf chunk and drops XMP iTXt chunks.
+func stripPNG(b []byte) ([]byte, int, error) {
+	if !bytes.HasPrefix(b, pngSignature) {
+		return nil, 0, ErrBadImage
+	}
+	out := make([]byte, 0, len(b))
+	out = append(out, pngSignature...)
+	orientation := 0
+
+	for i := len(pngSignature); i < len(b); {
+		if i+12 > len(b) {
+			return nil, 0, ErrBadImage
+		}
+		n := binary.BigEndian.Uint32(b[i:])
+		if n > uint32(len(b)-i-12) {
+			return nil, 0, ErrBadImage
+		}
+		end := i + 12 + int(n)
+		chunk := bytes.Clone(b[i:end])
+		typ, data := string(chunk[4:8]), chunk[8:8+n]
+
+		keep := true
+		switch typ {
+		case "eXIf":
+			o, ok := scrubExif(data)
+			keep = ok
+			if ok {
+				orientation = o
+				binary.BigEndian.PutUint32(chunk[8+n:], crc32.ChecksumIEEE(chunk[4:8+n]))
+			}
+		case "iTXt":
+			keep = !bytes.HasPrefix(data, []byte("XML:com.adobe.xmp\x00"))
+		}
+		if keep {
+			out = append(out, chunk...)
+		}
+		i = end
+		if typ == "IEND" {
+			break
+		}
+	}
+	return out, orientation, nil
+}
+
+// --- WebP ---
+
+// VP8X header flags for the metadata chunks.
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// stripWebP removes GPS data from the EXIF chunk and drops the XMP chunk.
+func stripWebP(b []byte) ([]byte, int, error) {
+	if len(b) < 12 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "WEBP" {
+		return nil, 0, ErrBadImage
+	}
+	out := make([]byte, 12, len(b))
+	copy(out, b[:12])
+	orientation := 0
+	vp8x := -1 // Offset of the VP8X chunk in out, if there is one
+	dropped := byte(0)
+
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(b[4:]))
+	if riffEnd > len(b) {
+		return nil, 0, ErrBadImage
+	}
+	for i := 12; i < riffEnd; {
+		if i+8 > riffEnd {
+			return nil, 0, ErrBadImage
+		}
+		n := int(binary.LittleEndian.Uint32(b[i+4:]))
+		end := i + 8 + n + n%2 // Chunks are padded to an even size
+		if n < 0 || end > riffEnd {
+			return nil, 0, ErrBadImage
+		}
+		chunk := bytes.Clone(b[i:end])
+		data := chunk[8 : 8+n]
+
+		keep := true
+		switch string(chunk[0:4]) {
+		case "VP8X":
+			vp8x = len(out)
+		case "EXIF":
+			o, ok := scrubExif(bytes.TrimPrefix(data, exifPrefix))
+			keep = ok
+			if ok {
+				orientation = o
+			} else {
+				dropped |= webpFlagEXIF
+			}
+		case "XMP ":
+			keep = false
+			dropped |= webpFlagXMP
+		}
+		if keep {
+			out = append(out, chunk...)
+		}
+		i = end
+	}
+
+	if vp8x >= 0 && len(out) > vp8x+8 {
+		out[vp8x+8] &^= dropped
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, orientation, nil
+}
+
+// --- EXIF ---
+
+// EXIF tags used here.
+const (
+	tagOrientation = 0x0112
+	tagGPSIFD      = 0x8825
+)
+
+// exifTypeSizes is the size in bytes of one value of each TIFF field type.
+var exifTypeSizes = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// scrubExif blanks the GPS IFD of a TIFF-structured EXIF block in place and
+// returns the orientation from IFD0. Blanking keeps every other offset in the
+// block valid. ok is false if the block can't be parsed; the caller then drops
+// it entirely, since it can't be shown to hold no location.
+func scrubExif(tiff []byte) (orientation int, ok bool) {
+	if len(tiff) < 8 {
+		return 0, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[0:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	ifd0 := order.Uint32(tiff[4:])
+	entries, ok := exifEntries(tiff, order, ifd0)
+	if !ok {
+		return 0, false
+	}
+	for _, e := range entries {
+		switch order.Uint16(e[0:]) {
+		case tagOrientation:
+			orientation = int(order.Uint16(e[8:]))
+		case tagGPSIFD:
+			if !blankIFD(tiff, order, order.Uint32(e[8:])) {
+				return 0, false
+			}
+		}
+	}
+	if orientation < 1 || orientation > 8 {
+		orientation = 0
+	}
+	return orientation, true
+}
+
+// exifEntries returns the 12-byte entries of the IFD at offset.
+func exifEntries(tiff []byte, order binary.ByteOrder, offset uint32) ([][]byte, bool) {
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return nil, false
+	}
+	n := uint32(order.Uint16(tiff[offset:]))
+	end := uint64(offset) + 2 + 12*uint64(n)
+	if end > uint64(len(tiff)) {
+		return nil, false
+	}
+	entries := make([][]byte, n)
+	for i := range entries {
+		start := offset + 2 + 12*uint32(i)
+		entries[i] = tiff[start : start+12]
+	}
+	return entries, true
+}
+
+// blankIFD zeroes every value stored outside the IFD at offset, then the IFD
+// itself, leaving an IFD with no entries and no next IFD.
+func blankIFD(tiff []byte, order binary.ByteOrder, offset uint32) bool {
+	entries, ok := exifEntries(tiff, order, offset)
+	if !ok {
+		return false
+	}
+	for _, e := range entries {
+		size, known := exifTypeSizes[order.Uint16(e[2:])]
+		if !known {
+			return false
+		}
+		total := uint64(size) * uint64(order.Uint32(e[4:]))
+		if total <= 4 {
+			continue // Stored in the entry itself
+		}
+		start := uint64(order.Uint32(e[8:]))
+		if start+total > uint64(len(tiff)) {
+			return false
+		}
+		clear(tiff[start : start+total])
+	}
+
+	end := uint64(offset) + 2 + 12*uint64(len(entries)) + 4
+	if end > uint64(len(tiff)) {
+		end = uint64(len(tiff))
+	}
+	clear(tiff[offset:end])
+	return true
+}
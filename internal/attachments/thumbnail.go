@@ -0,0 +1,110 @@
+// internal/attachments/thumbnail.go
+package attachments
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register the WebP decoder
+)
+
+const (
+	// ThumbnailSize is the longest side of a thumbnail, in pixels. Notes show
+	// thumbnails at half this size, so they stay sharp on high-DPI screens.
+	ThumbnailSize = 480
+
+	// MaxPixels caps the images accepted. Decoding needs a few bytes per pixel,
+	// so this bounds the memory one upload can take (and stops decompression bombs).
+	MaxPixels = 40_000_000
+
+	thumbnailQuality = 80
+)
+
+// thumbnail decodes an image and returns a JPEG thumbnail of it, turned the way
+// the EXIF orientation says, plus the image's displayed width and height.
+func thumbnail(data []byte, orientation int) ([]byte, int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, ErrBadImage
+	}
+	if cfg.Width < 1 || cfg.Height < 1 {
+		return nil, 0, 0, ErrBadImage
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, 0, 0, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, ErrBadImage
+	}
+
+	// Fit the image in a ThumbnailSize square, never scaling it up.
+	w, h := cfg.Width, cfg.Height
+	if w > ThumbnailSize || h > ThumbnailSize {
+		if w >= h {
+			w, h = ThumbnailSize, max(1, h*ThumbnailSize/w)
+		} else {
+			w, h = max(1, w*ThumbnailSize/h), ThumbnailSize
+		}
+	}
+	// JPEG has no transparency, so flatten onto white rather than black.
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	oriented := orient(dst, orientation)
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, oriented, &jpeg.Options{Quality: thumbnailQuality})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	width, height := cfg.Width, cfg.Height
+	if orientation >= 5 { // Orientations 5-8 turn the image on its side
+		width, height = height, width
+	}
+	return buf.Bytes(), width, height, nil
+}
+
+// orient applies an EXIF orientation (1-8) to img. Orientation 2 is a mirror
+// image, 3 is upside down, 6 needs turning clockwise, and so on.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored
+				sx, sy = w-1-x, y
+			case 3: // Upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirrored upside down
+				sx, sy = x, h-1-y
+			case 5: // Mirrored, on its side
+				sx, sy = y, x
+			case 6: // Needs turning clockwise
+				sx, sy = y, h-1-x
+			case 7: // Mirrored, on its other side
+				sx, sy = w-1-y, h-1-x
+			case 8: // Needs turning anticlockwise
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,188 @@
+// internal/blobstore/blobstore.go
+
+// Package blobstore stores attachment files (blobs) outside the database.
+//
+// Store is the interface the app codes against. Local keeps blobs in a
+// directory on the server's disk and is the only implementation for now; an
+// S3-compatible store can be added behind the same interface later, and Local
+// pointed at a temporary directory stands in for it in the meantime.
+//
+// Keys are opaque, slash-separated names chosen by the caller (see NewKey).
+package blobstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// ErrNotFound is returned when no blob has the requested key.
+var ErrNotFound = errors.New("blobstore: blob not found")
+
+// Store saves, reads and deletes blobs by key.
+type Store interface {
+	// Put stores everything read from r under key, replacing any blob already
+	// there, and returns the number of bytes written. A blob is either stored
+	// completely or not at all.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+
+	// Open returns the blob stored under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (Blob, error)
+
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Blob is an open, seekable blob, e.g. for http.ServeContent. Close it when done.
+type Blob interface {
+	io.ReadSeekCloser
+	Size() int64
+	ModTime() time.Time
+}
+
+// keyRX limits keys to names that are safe as relative file paths and object names.
+var keyRX = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$`)
+
+// ValidKey reports whether key can be used with a Store.
+func ValidKey(key string) bool {
+	return len(key) <= 200 && keyRX.MatchString(key)
+}
+
+// NewKey returns a random key with the given prefix, e.g. "attachments/3f/3fa9...".
+// The two-character directory keeps any one directory from growing too large.
+func NewKey(prefix string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	name := hex.EncodeToString(b)
+	return prefix + "/" + name[:2] + "/" + name, nil
+}
+
+// --- Local Filesystem ---
+
+// Local stores blobs as files under Root.
+type Local struct {
+	Root string
+}
+
+// NewLocal returns a Local store rooted at dir, creating the directory if needed.
+func NewLocal(dir string) (*Local, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: %w", err)
+	}
+	return &Local{Root: dir}, nil
+}
+
+// path returns the file holding key's blob.
+func (l *Local) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("blobstore: invalid key %q", key)
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(key)), nil
+}
+
+// Put implements Store. The blob is written to a temporary file in the same
+// directory and renamed into place, so readers never see a partial file.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	n, err := io.Copy(tmp, contextReader{ctx, r})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Open implements Store.
+func (l *Local) Open(ctx context.Context, key string) (Blob, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return localBlob{f, info}, nil
+}
+
+// Delete implements Store.
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// localBlob is an open file and its details.
+type localBlob struct {
+	*os.File
+	info fs.FileInfo
+}
+
+// Size implements Blob.
+func (b localBlob) Size() int64 { return b.info.Size() }
+
+// ModTime implements Blob.
+func (b localBlob) ModTime() time.Time { return b.info.ModTime() }
+
+// contextReader stops a long copy once ctx is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader.
+func (cr contextReader) Read(p []byte) (int, error) {
+	err := cr.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,212 @@
+// internal/data/api_tokens.go
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/validator"
+)
+
+// Scopes an API token can be given. Each /v1 route requires one.
+const (
+	ScopeNotesRead  = "notes:read"
+	ScopeNotesWrite = "notes:write"
+	ScopeExport     = "export"
+)
+
+// APIScopes lists every scope, in the order the settings page offers them.
+var APIScopes = []string{ScopeNotesRead, ScopeNotesWrite, ScopeExport}
+
+// APIToken is a personal token for the /v1 JSON API. There are no user accounts,
+// so every token acts for the app's single owner, limited to its scopes.
+type APIToken struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`   // Zero means the token never expires
+	LastUsedAt time.Time `json:"last_used_at"` // Zero until the token is first used
+
+	// Token is the secret itself. Only a hash is stored, so it is set on the
+	// value returned by Insert and nowhere else.
+	Token string `json:"-"`
+}
+
+// apiTokenPrefix marks the tokens as this app's, so they are easy to recognise
+// in a script (or in a leaked file, for secret scanners).
+const apiTokenPrefix = "ffp_"
+
+// apiTokenLength is the length of a token: the prefix and 32 random bytes, base64url.
+const apiTokenLength = len(apiTokenPrefix) + 43
+
+// apiTokenNameMaxLength bounds the label shown on the settings page.
+const apiTokenNameMaxLength = 100
+
+// HasScope reports whether the token grants scope.
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// ValidateAPIToken checks the name and scopes chosen for a new token.
+func ValidateAPIToken(v *validator.Validator, t *APIToken) {
+	v.Check(validator.NotBlank(t.Name), "name", "must be provided")
+	v.Check(validator.MaxLength(t.Name, apiTokenNameMaxLength), "name", fmt.Sprintf("must not be more than %d characters long", apiTokenNameMaxLength))
+	v.Check(len(t.Scopes) > 0, "scopes", "must include at least one scope")
+	for _, s := range t.Scopes {
+		v.Check(slices.Contains(APIScopes, s), "scopes", "must be from the listed scopes")
+	}
+}
+
+// APITokenModel stores API tokens.
+type APITokenModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// hashAPIToken returns the value stored in api_tokens.token_hash. As with share
+// links, a fast hash is enough for 256 random bits.
+func hashAPIToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// Insert creates a token with t's name, scopes and expiry (zero for none) and
+// sets t's ID, CreatedAt and plaintext Token.
+func (m *APITokenModel) Insert(t *APIToken, audit AuditInfo) error {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+	t.Token = apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	var expires sql.NullTime
+	if !t.ExpiresAt.IsZero() {
+		expires = sql.NullTime{Time: t.ExpiresAt, Valid: true}
+	}
+
+	query := `
+		INSERT INTO api_tokens (name, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, query, t.Name, hashAPIToken(t.Token), strings.Join(t.Scopes, " "), expires).Scan(&t.ID, scanTime(&t.CreatedAt))
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditTokenCreate, t.ID, 0, 0)
+	})
+}
+
+// GetByToken returns the unexpired token whose secret is token.
+func (m *APITokenModel) GetByToken(token string) (*APIToken, error) {
+	if len(token) != apiTokenLength || !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, errors.New("API token not found")
+	}
+
+	query := `
+		SELECT id, name, scopes, created_at, expires_at, last_used_at
+		FROM api_tokens
+		WHERE token_hash = $1
+		AND (expires_at IS NULL OR expires_at > NOW())`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	t, err := scanAPIToken(m.DB.QueryRowContext(ctx, query, hashAPIToken(token)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("API token not found")
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetAll returns every token, expired ones included, newest first.
+func (m *APITokenModel) GetAll() ([]*APIToken, error) {
+	query := `
+		SELECT id, name, scopes, created_at, expires_at, last_used_at
+		FROM api_tokens
+		ORDER BY created_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// scanAPIToken reads the columns selected by the APITokenModel queries.
+func scanAPIToken(row interface{ Scan(...any) error }) (*APIToken, error) {
+	var (
+		t          APIToken
+		scopes     string
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+	)
+	err := row.Scan(&t.ID, &t.Name, &scopes, &t.CreatedAt, &expiresAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	t.ExpiresAt = expiresAt.Time
+	t.LastUsedAt = lastUsedAt.Time
+	return &t, nil
+}
+
+// RecordUse sets a token's last-used time to now.
+func (m *APITokenModel) RecordUse(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// Delete revokes a token immediately. Its creation and revocation stay in the audit log.
+func (m *APITokenModel) Delete(id int64, audit AuditInfo) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return errors.New("API token not found")
+		}
+		return recordAudit(ctx, tx, audit, AuditTokenRevoke, id, 0, 0)
+	})
+}
@@ -0,0 +1,228 @@
+// internal/data/attachments.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Attachment kinds.
+const (
+	AttachmentImage = "image"
+	AttachmentAudio = "audio"
+)
+
+// Attachment describes an image or audio clip attached to a note. The file and
+// its thumbnail are kept in the blob store under BlobKey and ThumbKey, both
+// sealed with data key KeyID (see NoteCipher.SealStream).
+type Attachment struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	NoteID      int64     `json:"note_id"`
+	Kind        string    `json:"kind"`         // AttachmentImage or AttachmentAudio
+	ContentType string    `json:"content_type"` // Sniffed from the file's bytes
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width,omitempty"` // Images only
+	Height      int       `json:"height,omitempty"`
+	BlobKey     string    `json:"-"`
+	ThumbKey    string    `json:"-"` // Empty for audio
+	KeyID       int64     `json:"-"` // 0 when the blobs are stored without encryption
+}
+
+// IsImage reports whether the attachment is an image.
+func (a *Attachment) IsImage() bool { return a.Kind == AttachmentImage }
+
+// AttachmentModel stores attachment records. Deleting an attachment, or the note
+// it belongs to, only detaches the record (note_id becomes NULL); the data job
+// then deletes the blobs and the record with Detached and Remove. That way a
+// blob is never left behind by a transaction that rolled back after deleting it.
+type AttachmentModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// attachmentColumns is the column list scanned by scanAttachment.
+const attachmentColumns = `id, created_at, note_id, kind, content_type, filename, size, width, height, blob_key, thumb_key, key_id`
+
+// scanAttachment reads one row selected with attachmentColumns.
+func scanAttachment(row interface{ Scan(...any) error }) (*Attachment, error) {
+	var (
+		a        Attachment
+		noteID   sql.NullInt64
+		thumbKey sql.NullString
+		keyID    sql.NullInt64
+	)
+	err := row.Scan(&a.ID, &a.CreatedAt, &noteID, &a.Kind, &a.ContentType, &a.Filename, &a.Size, &a.Width, &a.Height, &a.BlobKey, &thumbKey, &keyID)
+	if err != nil {
+		return nil, err
+	}
+	a.NoteID = noteID.Int64
+	a.ThumbKey = thumbKey.String
+	a.KeyID = keyID.Int64
+	return &a, nil
+}
+
+// Insert records an attachment whose blobs are already stored, recording it in
+// the audit log. The note must still exist.
+func (m *AttachmentModel) Insert(a *Attachment, audit AuditInfo) error {
+	query := `
+		INSERT INTO attachments (note_id, kind, content_type, filename, size, width, height, blob_key, thumb_key, key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`
+
+	args := []any{
+		a.NoteID, a.Kind, a.ContentType, a.Filename, a.Size, a.Width, a.Height, a.BlobKey,
+		sql.NullString{String: a.ThumbKey, Valid: a.ThumbKey != ""},
+		sql.NullInt64{Int64: a.KeyID, Valid: a.KeyID != 0},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&a.ID, scanTime(&a.CreatedAt))
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return errors.New("mood note record not found")
+			}
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditAttachmentAdd, a.ID, 0, 0)
+	})
+}
+
+// Get returns one attachment that still belongs to a note.
+func (m *AttachmentModel) Get(id int64) (*Attachment, error) {
+	if id < 1 {
+		return nil, errors.New("attachment not found")
+	}
+
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = $1 AND note_id IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	a, err := scanAttachment(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("attachment not found")
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetForNote returns a note's attachments, oldest first.
+func (m *AttachmentModel) GetForNote(noteID int64) ([]*Attachment, error) {
+	return m.list(`SELECT `+attachmentColumns+` FROM attachments WHERE note_id = $1 ORDER BY id`, noteID)
+}
+
+// GetAll returns every attachment that belongs to a note (for the data export).
+func (m *AttachmentModel) GetAll() ([]*Attachment, error) {
+	return m.list(`SELECT ` + attachmentColumns + ` FROM attachments WHERE note_id IS NOT NULL ORDER BY note_id, id`)
+}
+
+// Detached returns up to limit attachments whose note or record was deleted and
+// whose blobs are waiting to be removed.
+func (m *AttachmentModel) Detached(limit int) ([]*Attachment, error) {
+	return m.list(`SELECT `+attachmentColumns+` FROM attachments WHERE note_id IS NULL ORDER BY id LIMIT $1`, limit)
+}
+
+// NotOnKey returns up to limit attachments (still on a note) whose blobs are not
+// sealed with data key keyID, for the re-encryption job.
+func (m *AttachmentModel) NotOnKey(keyID int64, limit int) ([]*Attachment, error) {
+	return m.list(`
+		SELECT `+attachmentColumns+` FROM attachments
+		WHERE note_id IS NOT NULL AND (key_id IS NULL OR key_id <> $1)
+		ORDER BY id
+		LIMIT $2`, keyID, limit)
+}
+
+// Rekey records that an attachment's blobs were sealed again, with data key keyID,
+// under new keys. It fails if the attachment changed meanwhile; the caller then
+// deletes the new blobs, and otherwise the old ones.
+func (m *AttachmentModel) Rekey(a *Attachment, blobKey, thumbKey string, keyID int64) error {
+	query := `
+		UPDATE attachments SET blob_key = $1, thumb_key = $2, key_id = $3
+		WHERE id = $4 AND blob_key = $5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query,
+		blobKey, sql.NullString{String: thumbKey, Valid: thumbKey != ""}, sql.NullInt64{Int64: keyID, Valid: keyID != 0},
+		a.ID, a.BlobKey)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("attachment not found")
+	}
+	return nil
+}
+
+// list runs a query selecting attachmentColumns and returns the rows.
+func (m *AttachmentModel) list(query string, args ...any) ([]*Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// Delete detaches an attachment from its note, recording it in the audit log.
+// It disappears from the note at once; its blobs are removed by the data job.
+func (m *AttachmentModel) Delete(id int64, audit AuditInfo) error {
+	query := `
+		UPDATE attachments SET note_id = NULL
+		WHERE id = $1 AND note_id IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		result, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.New("attachment not found")
+		}
+		return recordAudit(ctx, tx, audit, AuditAttachmentDelete, id, 0, 0)
+	})
+}
+
+// Remove deletes a detached attachment's record once its blobs are gone.
+func (m *AttachmentModel) Remove(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM attachments WHERE id = $1 AND note_id IS NULL`, id)
+	return err
+}
@@ -0,0 +1,249 @@
+// internal/data/audit.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditInfo says who made a change and from where. Handlers build one per request
+// and pass it to every model method that changes data.
+type AuditInfo struct {
+	Actor     string
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// AuditEvent is one row of the audit log.
+type AuditEvent struct {
+	ID            int64     `json:"id"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	Actor         string    `json:"actor"`
+	Action        string    `json:"action"`
+	TargetType    string    `json:"target_type"`
+	TargetID      int64     `json:"target_id"`
+	VersionBefore *int      `json:"version_before"` // nil where versions don't apply (e.g. creating a note)
+	VersionAfter  *int      `json:"version_after"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	RequestID     string    `json:"request_id"`
+}
+
+// Audit actions. The prefix before the dot is the target type.
+const (
+	AuditNoteCreate       = "note.create"
+	AuditNoteUpdate       = "note.update"
+	AuditNoteDelete       = "note.delete"
+	AuditShareCreate      = "share.create"
+	AuditShareRevoke      = "share.revoke"
+	AuditTemplateCreate   = "template.create"
+	AuditTemplateUpdate   = "template.update"
+	AuditTemplateDelete   = "template.delete"
+	AuditAttachmentAdd    = "attachment.add"
+	AuditAttachmentDelete = "attachment.delete"
+	AuditDataExport       = "data.export"
+	AuditEraseSchedule    = "data.erase_schedule"
+	AuditEraseCancel      = "data.erase_cancel"
+	AuditTokenCreate      = "token.create"
+	AuditTokenRevoke      = "token.revoke"
+
+	// Sign-in style events. They change no data, so they are written by
+	// AuditModel.Record rather than alongside a change.
+	AuditShareUnlock     = "share.unlock"      // Right passcode for a share link
+	AuditShareUnlockFail = "share.unlock_fail" // Wrong passcode for a share link
+	AuditTokenReject     = "token.reject"      // Unknown, expired or under-scoped API token; at most one a minute per IP
+)
+
+// AuditActions lists every action, e.g. for a filter drop-down.
+var AuditActions = []string{
+	AuditNoteCreate, AuditNoteUpdate, AuditNoteDelete,
+	AuditShareCreate, AuditShareRevoke, AuditShareUnlock, AuditShareUnlockFail,
+	AuditTemplateCreate, AuditTemplateUpdate, AuditTemplateDelete,
+	AuditAttachmentAdd, AuditAttachmentDelete,
+	AuditDataExport, AuditEraseSchedule, AuditEraseCancel,
+	AuditTokenCreate, AuditTokenRevoke, AuditTokenReject,
+}
+
+// AuditTargetTypes lists the target types, i.e. the action prefixes.
+var AuditTargetTypes = []string{"note", "share", "template", "attachment", "data", "token"}
+
+// recordAudit writes an audit event inside tx, so it commits or rolls back together
+// with the change it describes. before/after are note versions; pass 0 when unknown.
+func recordAudit(ctx context.Context, tx DBTX, info AuditInfo, action string, targetID int64, before, after int) error {
+	targetType, _, _ := strings.Cut(action, ".")
+	query := `
+		INSERT INTO audit_events (actor, action, target_type, target_id, version_before, version_after, ip, user_agent, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := tx.ExecContext(ctx, query,
+		info.Actor, action, targetType, targetID,
+		sql.NullInt64{Int64: int64(before), Valid: before > 0},
+		sql.NullInt64{Int64: int64(after), Valid: after > 0},
+		info.IP, info.UserAgent, info.RequestID,
+	)
+	if err != nil {
+		return fmt.Errorf("recording audit event %s: %w", action, err)
+	}
+	return nil
+}
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	Action     string
+	TargetType string
+	TargetID   int64
+	Actor      string
+}
+
+// PageInfo describes one page of a paginated listing.
+type PageInfo struct {
+	Page         int
+	PageSize     int
+	TotalRecords int
+	LastPage     int
+}
+
+// HasPrev reports whether there is a page before this one.
+func (p PageInfo) HasPrev() bool { return p.Page > 1 }
+
+// HasNext reports whether there is a page after this one.
+func (p PageInfo) HasNext() bool { return p.Page < p.LastPage }
+
+// newPageInfo works out the page numbers for a listing of total records.
+func newPageInfo(page, pageSize, total int) PageInfo {
+	if total == 0 {
+		return PageInfo{Page: page, PageSize: pageSize}
+	}
+	return PageInfo{
+		Page:         page,
+		PageSize:     pageSize,
+		TotalRecords: total,
+		LastPage:     (total + pageSize - 1) / pageSize,
+	}
+}
+
+// AuditModel reads the audit log. Changes to data are written by recordAudit in
+// the same transaction; Record writes the events that stand on their own.
+type AuditModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// Record writes an event that isn't part of a data change, such as a failed
+// passcode. targetID is 0 when the target is unknown (e.g. a made-up token).
+func (m *AuditModel) Record(info AuditInfo, action string, targetID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return recordAudit(ctx, m.DB, info, action, targetID, 0, 0)
+}
+
+// GetPage returns one page of events matching filter, newest first.
+func (m *AuditModel) GetPage(filter AuditFilter, page, pageSize int) ([]*AuditEvent, PageInfo, error) {
+	if page < 1 {
+		page = 1
+	}
+	// COUNT(*) OVER() returns the total alongside each row, saving a second query.
+	query := `
+		SELECT COUNT(*) OVER(), id, occurred_at, actor, action, target_type, target_id,
+			version_before, version_after, ip, user_agent, request_id
+		FROM audit_events
+		WHERE ($1 = '' OR action = $1)
+		AND ($2 = '' OR target_type = $2)
+		AND ($3 = 0 OR target_id = $3)
+		AND ($4 = '' OR actor = $4)
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $5 OFFSET $6`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filter.Action, filter.TargetType, filter.TargetID, filter.Actor, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	defer rows.Close()
+
+	total := 0
+	var events []*AuditEvent
+	for rows.Next() {
+		e, err := scanAuditEvent(rows, &total)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+		events = append(events, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	// A page past the end has no rows to carry the total; ask for it directly.
+	if len(events) == 0 && page > 1 {
+		err = m.DB.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM audit_events
+			WHERE ($1 = '' OR action = $1)
+			AND ($2 = '' OR target_type = $2)
+			AND ($3 = 0 OR target_id = $3)
+			AND ($4 = '' OR actor = $4)`, filter.Action, filter.TargetType, filter.TargetID, filter.Actor).Scan(&total)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+	}
+	return events, newPageInfo(page, pageSize, total), nil
+}
+
+// GetAll returns the whole audit log, oldest first.
+func (m *AuditModel) GetAll() ([]*AuditEvent, error) {
+	query := `
+		SELECT id, occurred_at, actor, action, target_type, target_id,
+			version_before, version_after, ip, user_agent, request_id
+		FROM audit_events
+		ORDER BY occurred_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*AuditEvent
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// scanAuditEvent reads one audit_events row. Any extra destinations (such as a
+// window-function total) come before the event columns.
+func scanAuditEvent(rows *sql.Rows, extra ...any) (*AuditEvent, error) {
+	var (
+		e             AuditEvent
+		before, after sql.NullInt64
+	)
+	dest := append(extra, &e.ID, &e.OccurredAt, &e.Actor, &e.Action, &e.TargetType, &e.TargetID,
+		&before, &after, &e.IP, &e.UserAgent, &e.RequestID)
+	err := rows.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	if before.Valid {
+		v := int(before.Int64)
+		e.VersionBefore = &v
+	}
+	if after.Valid {
+		v := int(after.Int64)
+		e.VersionAfter = &v
+	}
+	return &e, nil
+}
@@ -0,0 +1,809 @@
+// internal/data/conformance_test.go
+package data
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/validator"
+)
+
+// These tests run once per storage backend (see testdb_test.go). They exercise
+// every query the models share, so a query that only works on one backend fails here.
+
+func TestMoodNotesConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		for _, encrypted := range []bool{false, true} {
+			name := "plaintext"
+			var cipher *NoteCipher
+			if encrypted {
+				name, cipher = "encrypted", newTestCipher(t, db)
+			}
+			t.Run(name, func(t *testing.T) {
+				db.Exec("DELETE FROM mood_notes")
+				m := &MoodNoteModel{DB: db, Cipher: cipher}
+				day := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+
+				first := insertTestNote(t, m, &MoodNote{Title: "First", Content: "Calm morning", OccurredAt: day})
+				second := insertTestNote(t, m, &MoodNote{Title: "Second", Content: "Busy afternoon", OccurredAt: day.Add(6 * time.Hour)})
+				third := insertTestNote(t, m, &MoodNote{Title: "Third", Content: "Same time as the second", OccurredAt: day.Add(6 * time.Hour)})
+				if first.ID == 0 || first.Version != 1 || first.CreatedAt.IsZero() || first.UpdatedAt.IsZero() {
+					t.Fatalf("Insert didn't fill in the generated fields: %+v", first)
+				}
+
+				got, err := m.Get(first.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Title != "First" || got.Content != "Calm morning" || !got.OccurredAt.Equal(day) || got.Version != 1 {
+					t.Errorf("Get = %+v", got)
+				}
+				if !got.CreatedAt.Equal(first.CreatedAt.Truncate(time.Microsecond)) && !got.CreatedAt.Equal(first.CreatedAt) {
+					t.Errorf("Get CreatedAt = %v, Insert returned %v", got.CreatedAt, first.CreatedAt)
+				}
+
+				var storedTitle string
+				db.QueryRow("SELECT title FROM mood_notes WHERE id = $1", first.ID).Scan(&storedTitle)
+				if encrypted == (storedTitle == "First") {
+					t.Errorf("stored title = %q (encrypted: %v)", storedTitle, encrypted)
+				}
+
+				// Newest first; notes with the same entry date by ID.
+				all, err := m.GetAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				var titles []string
+				for _, n := range all {
+					titles = append(titles, n.Title)
+				}
+				if strings.Join(titles, ",") != "Third,Second,First" {
+					t.Errorf("GetAll order = %v", titles)
+				}
+				recent, err := m.GetRecent(2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(recent) != 2 || recent[0].ID != third.ID || recent[1].ID != second.ID || recent[1].Content != "Busy afternoon" {
+					t.Errorf("GetRecent(2) = %+v", recent)
+				}
+
+				prev, next, err := m.GetNeighbours(second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if prev == nil || prev.ID != first.ID || next == nil || next.ID != third.ID || next.Title != "Third" {
+					t.Errorf("GetNeighbours(second) = %+v, %+v", prev, next)
+				}
+				prev, next, err = m.GetNeighbours(first)
+				if err != nil || prev != nil || next == nil {
+					t.Errorf("GetNeighbours(first) = %+v, %+v, %v", prev, next, err)
+				}
+
+				n, err := m.CountCreatedBetween(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+				if err != nil || n != 3 {
+					t.Errorf("CountCreatedBetween = %d, %v; want 3", n, err)
+				}
+
+				// Optimistic locking.
+				stale := *got
+				got.Content = "Calm morning, then rain"
+				err = m.Update(got, testAudit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Version != 2 || got.UpdatedAt.Before(got.CreatedAt) {
+					t.Errorf("after Update: version %d, updated %v, created %v", got.Version, got.UpdatedAt, got.CreatedAt)
+				}
+				err = m.Update(&stale, testAudit)
+				if err == nil || err.Error() != "mood note record not found or version mismatch" {
+					t.Errorf("stale Update error = %v", err)
+				}
+
+				// Notes written offline are deduplicated by their client ID.
+				offline := insertTestNote(t, m, &MoodNote{Title: "Offline", Content: "On the train", OccurredAt: day, ClientID: "c-123"})
+				err = m.Insert(&MoodNote{Title: "Offline", Content: "On the train", OccurredAt: day, ClientID: "c-123"}, testAudit)
+				if err == nil || err.Error() != "duplicate mood note client ID" {
+					t.Errorf("duplicate client ID error = %v", err)
+				}
+				byClient, err := m.GetByClientID("c-123")
+				if err != nil || byClient.ID != offline.ID {
+					t.Errorf("GetByClientID = %+v, %v", byClient, err)
+				}
+
+				err = m.Delete(second.ID, testAudit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = m.Get(second.ID)
+				if err == nil || err.Error() != "mood note record not found" {
+					t.Errorf("Get after Delete error = %v", err)
+				}
+				err = m.Delete(second.ID, testAudit)
+				if err == nil || err.Error() != "mood note record not found or already deleted" {
+					t.Errorf("second Delete error = %v", err)
+				}
+			})
+		}
+	})
+}
+
+func TestReencryptNotesConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		plain := &MoodNoteModel{DB: db}
+		note := insertTestNote(t, plain, &MoodNote{Title: "Before", Content: "Written before encryption", OccurredAt: time.Now().Add(-time.Hour)})
+
+		cipher := newTestCipher(t, db)
+		m := &MoodNoteModel{DB: db, Cipher: cipher}
+		n, err := m.ReencryptNotes(10)
+		if err != nil || n != 1 {
+			t.Fatalf("ReencryptNotes = %d, %v; want 1", n, err)
+		}
+
+		err = cipher.RotateDataKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err = m.ReencryptNotes(10)
+		if err != nil || n != 1 {
+			t.Fatalf("ReencryptNotes after rotation = %d, %v; want 1", n, err)
+		}
+		n, err = m.ReencryptNotes(10)
+		if err != nil || n != 0 {
+			t.Fatalf("ReencryptNotes with nothing to do = %d, %v; want 0", n, err)
+		}
+
+		deleted, err := cipher.DeleteUnusedDataKeys()
+		if err != nil || deleted != 1 {
+			t.Errorf("DeleteUnusedDataKeys = %d, %v; want 1", deleted, err)
+		}
+		got, err := m.Get(note.ID)
+		if err != nil || got.Content != "Written before encryption" || got.Version != note.Version {
+			t.Errorf("Get after re-encryption = %+v, %v", got, err)
+		}
+	})
+}
+
+func TestDigestStatsConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		m := &MoodNoteModel{DB: db}
+		start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+		end := start.AddDate(0, 0, 7)
+		for _, at := range []time.Time{
+			start.AddDate(0, 0, -3),   // Previous week
+			start,                     // First instant of the period
+			start.Add(36 * time.Hour), // Tuesday
+			start.Add(37 * time.Hour), // Tuesday again
+			end.Add(-time.Second),     // Last second of the period
+			end,                       // Next week
+			end.AddDate(-1, 0, -1).Add(10 * time.Hour), // One year before the last day
+		} {
+			insertTestNote(t, m, &MoodNote{Title: "Entry", Content: "Some words", OccurredAt: at})
+		}
+
+		stats, err := m.DigestStats(start, PeriodWeek, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Entries != 4 || stats.PreviousEntries != 1 || stats.DaysJournaled != 3 {
+			t.Errorf("entries %d, previous %d, days %d; want 4, 1, 3", stats.Entries, stats.PreviousEntries, stats.DaysJournaled)
+		}
+		if stats.YearAgo == nil {
+			t.Error("YearAgo = nil")
+		}
+
+		// New York springs forward on Sunday 8 March 2026, so the week before
+		// the one starting on 9 March is 167 hours long. A late entry on Sunday
+		// 1 March belongs to neither week.
+		loc := mustLoadLocation(t, "America/New_York")
+		db.Exec("DELETE FROM mood_notes")
+		for _, at := range []string{"2026-03-01T23:30:00-05:00", "2026-03-02T00:30:00-05:00", "2026-03-09T09:00:00-04:00"} {
+			insertTestNote(t, m, &MoodNote{Title: "Entry", Content: "Some words", OccurredAt: mustParseTime(t, at)})
+		}
+		stats, err = m.DigestStats(mustParseTime(t, "2026-03-09T00:00:00-04:00"), PeriodWeek, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Entries != 1 || stats.PreviousEntries != 1 {
+			t.Errorf("after spring forward: entries %d, previous %d; want 1, 1", stats.Entries, stats.PreviousEntries)
+		}
+	})
+}
+
+func TestNoteTemplatesConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		m := &NoteTemplateModel{DB: db}
+		tmpl := &NoteTemplate{Name: "Gratitude", Content: "Three good things:"}
+		err := m.Insert(tmpl, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tmpl.ID == 0 || tmpl.Version != 1 || tmpl.CreatedAt.IsZero() {
+			t.Errorf("Insert = %+v", tmpl)
+		}
+		err = m.Insert(&NoteTemplate{Name: "Gratitude", Content: "Again"}, testAudit)
+		if err == nil || err.Error() != "duplicate note template name" {
+			t.Errorf("duplicate name error = %v", err)
+		}
+
+		stale := *tmpl
+		tmpl.Content = "Three good things today:"
+		err = m.Update(tmpl, testAudit)
+		if err != nil || tmpl.Version != 2 {
+			t.Fatalf("Update = %v, version %d", err, tmpl.Version)
+		}
+		err = m.Update(&stale, testAudit)
+		if err == nil || err.Error() != "note template not found or version mismatch" {
+			t.Errorf("stale Update error = %v", err)
+		}
+
+		got, err := m.Get(tmpl.ID)
+		if err != nil || got.Content != "Three good things today:" {
+			t.Errorf("Get = %+v, %v", got, err)
+		}
+		err = m.Delete(tmpl.ID, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Get(tmpl.ID)
+		if err == nil || err.Error() != "note template not found" {
+			t.Errorf("Get after Delete error = %v", err)
+		}
+	})
+}
+
+func TestDraftsConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		notes := &MoodNoteModel{DB: db}
+		note := insertTestNote(t, notes, &MoodNote{Title: "Note", Content: "Body", OccurredAt: time.Now().Add(-time.Hour)})
+
+		m := &DraftModel{DB: db, Cipher: newTestCipher(t, db)}
+		d := &Draft{Slot: DraftSlotNew, Title: "Half", Content: "Half-writ", OccurredAt: "2026-03-01T09:30"}
+		err := m.Save("owner", d, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		firstSave := d.SavedAt
+		if firstSave.IsZero() {
+			t.Error("Save didn't set SavedAt")
+		}
+
+		// Saving the same slot again replaces the draft.
+		d.Content = "Half-written"
+		err = m.Save("owner", d, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.Get("owner", DraftSlotNew)
+		if err != nil || got == nil || got.Content != "Half-written" || got.Title != "Half" {
+			t.Fatalf("Get = %+v, %v", got, err)
+		}
+		if got.SavedAt.Before(firstSave.Truncate(time.Microsecond)) {
+			t.Errorf("SavedAt went backwards: %v then %v", firstSave, got.SavedAt)
+		}
+
+		edit := &Draft{Slot: DraftSlot(note.ID), BaseVersion: note.Version, Title: "Note", Content: "Body, edited"}
+		err = m.Save("owner", edit, time.Hour)
+		if err != nil || edit.NoteID != note.ID {
+			t.Fatalf("Save for note = %v, note ID %d", err, edit.NoteID)
+		}
+		err = m.Save("owner", &Draft{Slot: DraftSlot(note.ID + 100), Content: "x"}, time.Hour)
+		if err == nil || err.Error() != "mood note record not found" {
+			t.Errorf("draft of a missing note error = %v", err)
+		}
+
+		all, err := m.GetAll()
+		if err != nil || len(all) != 2 {
+			t.Errorf("GetAll = %d drafts, %v; want 2", len(all), err)
+		}
+
+		// Expired drafts are invisible, then purged.
+		err = m.Save("owner", &Draft{Slot: DraftSlotNew, Content: "old"}, -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = m.Get("owner", DraftSlotNew)
+		if err != nil || got != nil {
+			t.Errorf("Get of an expired draft = %+v, %v", got, err)
+		}
+		n, err := m.PurgeExpired()
+		if err != nil || n != 1 {
+			t.Errorf("PurgeExpired = %d, %v; want 1", n, err)
+		}
+
+		err = m.Discard("owner", DraftSlot(note.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := countRows(t, db, "drafts"); n != 0 {
+			t.Errorf("%d drafts left after Discard", n)
+		}
+	})
+}
+
+func TestNoteSharesConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		notes := &MoodNoteModel{DB: db}
+		note := insertTestNote(t, notes, &MoodNote{Title: "Shared", Content: "Body", OccurredAt: time.Now().Add(-time.Hour)})
+
+		m := &NoteShareModel{DB: db}
+		share, err := m.Insert(note.ID, time.Now().Add(time.Hour), "open sesame", testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.GetByToken(share.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != share.ID || !got.HasPasscode || !got.CheckPasscode("open sesame") || got.CheckPasscode("wrong") {
+			t.Errorf("GetByToken = %+v", got)
+		}
+
+		err = m.RecordView(share.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ = m.GetByToken(share.Token)
+		if got.Views != 1 || got.LastViewedAt.IsZero() {
+			t.Errorf("after RecordView: views %d, last viewed %v", got.Views, got.LastViewedAt)
+		}
+
+		expired, err := m.Insert(note.ID, time.Now().Add(-time.Minute), "", testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.GetByToken(expired.Token)
+		if err == nil || err.Error() != "share link not found" {
+			t.Errorf("GetByToken of an expired share error = %v", err)
+		}
+
+		live, err := m.GetAllForNote(note.ID)
+		if err != nil || len(live) != 1 || live[0].ID != share.ID {
+			t.Errorf("GetAllForNote = %+v, %v; want only the live share", live, err)
+		}
+		all, err := m.GetAll()
+		if err != nil || len(all) != 2 {
+			t.Errorf("GetAll = %d shares, %v; want 2", len(all), err)
+		}
+
+		err = m.Revoke(note.ID, share.ID, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.GetByToken(share.Token)
+		if err == nil {
+			t.Error("revoked share still found")
+		}
+		err = m.Revoke(note.ID, share.ID, testAudit)
+		if err == nil || err.Error() != "share link not found or already revoked" {
+			t.Errorf("second Revoke error = %v", err)
+		}
+	})
+}
+
+func TestAttachmentsConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		notes := &MoodNoteModel{DB: db}
+		note := insertTestNote(t, notes, &MoodNote{Title: "Photo", Content: "Body", OccurredAt: time.Now().Add(-time.Hour)})
+
+		m := &AttachmentModel{DB: db}
+		newAttachment := func(noteID int64, key string) *Attachment {
+			return &Attachment{
+				NoteID: noteID, Kind: AttachmentImage, ContentType: "image/png", Filename: key + ".png",
+				Size: 1024, Width: 640, Height: 480, BlobKey: key, ThumbKey: key + "-thumb",
+			}
+		}
+
+		err := m.Insert(newAttachment(note.ID+100, "orphan"), testAudit)
+		if err == nil || err.Error() != "mood note record not found" {
+			t.Errorf("Insert for a missing note error = %v", err)
+		}
+
+		photo, sketch := newAttachment(note.ID, "photo"), newAttachment(note.ID, "sketch")
+		for _, a := range []*Attachment{photo, sketch} {
+			err = m.Insert(a, testAudit)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := m.Get(photo.ID)
+		if err != nil || got.NoteID != note.ID || got.BlobKey != "photo" || got.ThumbKey != "photo-thumb" || got.Width != 640 {
+			t.Errorf("Get = %+v, %v", got, err)
+		}
+
+		// Deleting an attachment, or its note, detaches it for the data job.
+		err = m.Delete(photo.ID, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Get(photo.ID)
+		if err == nil || err.Error() != "attachment not found" {
+			t.Errorf("Get after Delete error = %v", err)
+		}
+		err = notes.Delete(note.ID, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		detached, err := m.Detached(10)
+		if err != nil || len(detached) != 2 {
+			t.Fatalf("Detached = %d, %v; want 2", len(detached), err)
+		}
+		for _, a := range detached {
+			err = m.Remove(a.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n := countRows(t, db, "attachments"); n != 0 {
+			t.Errorf("%d attachments left after Remove", n)
+		}
+	})
+}
+
+func TestAttachmentKeysConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		cipher := newTestCipher(t, db)
+		notes := &MoodNoteModel{DB: db, Cipher: cipher}
+		note := insertTestNote(t, notes, &MoodNote{Title: "Photo", Content: "Body", OccurredAt: time.Now().Add(-time.Hour)})
+
+		m := &AttachmentModel{DB: db}
+		plain := &Attachment{NoteID: note.ID, Kind: AttachmentAudio, ContentType: "audio/ogg", Filename: "a.ogg", Size: 1, BlobKey: "attachments/aa/plain"}
+		sealed := &Attachment{NoteID: note.ID, Kind: AttachmentAudio, ContentType: "audio/ogg", Filename: "b.ogg", Size: 1, BlobKey: "attachments/bb/sealed", KeyID: cipher.ActiveKeyID()}
+		for _, a := range []*Attachment{plain, sealed} {
+			err := m.Insert(a, testAudit)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := m.Get(sealed.ID)
+		if err != nil || got.KeyID != cipher.ActiveKeyID() {
+			t.Errorf("Get = %+v, %v", got, err)
+		}
+
+		pending, err := m.NotOnKey(cipher.ActiveKeyID(), 10)
+		if err != nil || len(pending) != 1 || pending[0].ID != plain.ID {
+			t.Fatalf("NotOnKey = %+v, %v; want only the plaintext attachment", pending, err)
+		}
+		err = m.Rekey(pending[0], "attachments/cc/resealed", "", cipher.ActiveKeyID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Rekey(pending[0], "attachments/dd/again", "", cipher.ActiveKeyID())
+		if err == nil || err.Error() != "attachment not found" {
+			t.Errorf("Rekey of a moved attachment error = %v", err)
+		}
+		pending, err = m.NotOnKey(cipher.ActiveKeyID(), 10)
+		if err != nil || len(pending) != 0 {
+			t.Errorf("NotOnKey after Rekey = %+v, %v", pending, err)
+		}
+
+		// Attachments keep their data key from being deleted after a rotation.
+		oldKeyID := cipher.ActiveKeyID()
+		err = cipher.RotateDataKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = notes.ReencryptNotes(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deleted, err := cipher.DeleteUnusedDataKeys()
+		if err != nil || deleted != 0 {
+			t.Errorf("DeleteUnusedDataKeys = %d, %v; want 0 while attachments use key %d", deleted, err, oldKeyID)
+		}
+		pending, err = m.NotOnKey(cipher.ActiveKeyID(), 10)
+		if err != nil || len(pending) != 2 {
+			t.Errorf("NotOnKey after rotation = %d attachments, %v; want 2", len(pending), err)
+		}
+	})
+}
+
+func TestAuditLogConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		notes := &MoodNoteModel{DB: db}
+		var note *MoodNote
+		for range 5 {
+			note = insertTestNote(t, notes, &MoodNote{Title: "Note", Content: "Body", OccurredAt: time.Now().Add(-time.Hour)})
+		}
+		note.Title = "Renamed"
+		err := notes.Update(note, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m := &AuditModel{DB: db}
+		events, page, err := m.GetPage(AuditFilter{Action: AuditNoteCreate}, 2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || page.TotalRecords != 5 || page.LastPage != 3 {
+			t.Errorf("GetPage = %d events, %+v; want 2 of 5 on 3 pages", len(events), page)
+		}
+
+		events, _, err = m.GetPage(AuditFilter{TargetType: "note", TargetID: note.ID}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].Action != AuditNoteUpdate {
+			t.Fatalf("events for note %d = %+v", note.ID, events)
+		}
+		e := events[0]
+		if e.VersionBefore == nil || *e.VersionBefore != 1 || e.VersionAfter == nil || *e.VersionAfter != 2 ||
+			e.Actor != testAudit.Actor || e.IP != testAudit.IP || e.RequestID != testAudit.RequestID {
+			t.Errorf("update event = %+v", e)
+		}
+		if events[1].VersionBefore != nil {
+			t.Errorf("create event has a version before: %d", *events[1].VersionBefore)
+		}
+
+		// Record writes events that go with no change, and the actor filter finds them.
+		err = m.Record(AuditInfo{Actor: "API client", IP: "203.0.113.9"}, AuditTokenReject, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, page, err = m.GetPage(AuditFilter{Actor: "API client"}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || page.TotalRecords != 1 || events[0].Action != AuditTokenReject ||
+			events[0].TargetType != "token" || events[0].TargetID != 0 || events[0].VersionAfter != nil {
+			t.Errorf("events by API client = %+v, %+v", events, page)
+		}
+		_, page, err = m.GetPage(AuditFilter{Actor: testAudit.Actor}, 9, 10)
+		if err != nil || page.TotalRecords != 6 {
+			t.Errorf("page past the end for %q = %+v, %v; want 6 records", testAudit.Actor, page, err)
+		}
+	})
+}
+
+func TestEmailSendsConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		m := &EmailSendModel{DB: db}
+		week := time.Date(2026, 3, 2, 0, 0, 0, 0, time.FixedZone("UTC+10", 10*60*60))
+
+		claimed, err := m.Claim("digest", "me@example.com", week)
+		if err != nil || !claimed {
+			t.Fatalf("first Claim = %v, %v", claimed, err)
+		}
+		claimed, err = m.Claim("digest", "me@example.com", week)
+		if err != nil || claimed {
+			t.Errorf("second Claim = %v, %v; want false", claimed, err)
+		}
+		claimed, err = m.Claim("reminder", "me@example.com", week)
+		if err != nil || !claimed {
+			t.Errorf("Claim of another kind = %v, %v", claimed, err)
+		}
+
+		err = m.Release("digest", "me@example.com", week)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claimed, err = m.Claim("digest", "me@example.com", week)
+		if err != nil || !claimed {
+			t.Errorf("Claim after Release = %v, %v", claimed, err)
+		}
+
+		u := &UnsubscribeModel{DB: db}
+		for range 2 {
+			err = u.Insert("digest", "me@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for kind, want := range map[string]bool{"digest": true, "reminder": false} {
+			got, err := u.Exists(kind, "me@example.com")
+			if err != nil || got != want {
+				t.Errorf("Exists(%q) = %v, %v; want %v", kind, got, err, want)
+			}
+		}
+	})
+}
+
+func TestDataExportsConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		m := &DataExportModel{DB: db}
+		e, err := m.Insert(testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := m.ClaimPending()
+		if err != nil || id != e.ID {
+			t.Fatalf("ClaimPending = %d, %v; want %d", id, err, e.ID)
+		}
+		id, err = m.ClaimPending()
+		if err != nil || id != 0 {
+			t.Errorf("second ClaimPending = %d, %v; want 0", id, err)
+		}
+
+		err = m.Complete(e.ID, DataExportBlobKey(e.ID), 0, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.GetReady(e.ID)
+		if err != nil || got.BlobKey != "exports/"+strconv.FormatInt(e.ID, 10) || got.KeyID != 0 || got.ExpiresAt.IsZero() {
+			t.Errorf("GetReady = %+v, %v", got, err)
+		}
+
+		recent, err := m.GetRecent(5)
+		if err != nil || len(recent) != 1 || !recent[0].Ready() {
+			t.Errorf("GetRecent = %+v, %v", recent, err)
+		}
+		status, err := m.Get(e.ID)
+		if err != nil || status.Status != "ready" || status.CompletedAt.IsZero() {
+			t.Errorf("Get = %+v, %v", status, err)
+		}
+		_, err = m.Get(e.ID + 1)
+		if err == nil || err.Error() != "data export not found" {
+			t.Errorf("Get of a missing export error = %v", err)
+		}
+
+		expired, err := m.Expired(10)
+		if err != nil || len(expired) != 0 {
+			t.Errorf("Expired before expiry = %+v, %v", expired, err)
+		}
+		_, err = db.Exec("UPDATE data_exports SET expires_at = $1", time.Now().Add(-time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.GetReady(e.ID)
+		if err == nil || err.Error() != "data export not found or expired" {
+			t.Errorf("GetReady after expiry error = %v", err)
+		}
+		expired, err = m.Expired(10)
+		if err != nil || len(expired) != 1 || expired[0].BlobKey != DataExportBlobKey(e.ID) {
+			t.Fatalf("Expired = %+v, %v", expired, err)
+		}
+		err = m.MarkExpired(e.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expired, err = m.Expired(10)
+		if err != nil || len(expired) != 0 {
+			t.Errorf("Expired after MarkExpired = %+v, %v", expired, err)
+		}
+	})
+}
+
+func TestAPITokensConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		m := &APITokenModel{DB: db}
+		token := &APIToken{Name: "Backup script", Scopes: []string{ScopeNotesRead, ScopeExport}, ExpiresAt: time.Now().Add(time.Hour)}
+		err := m.Insert(token, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.ID == 0 || token.CreatedAt.IsZero() || len(token.Token) != apiTokenLength {
+			t.Fatalf("Insert set %+v", token)
+		}
+		if n := countRows(t, db, "audit_events"); n != 1 {
+			t.Errorf("%d audit events after Insert; want 1", n)
+		}
+
+		got, err := m.GetByToken(token.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != token.ID || got.Name != token.Name || !got.HasScope(ScopeExport) || got.HasScope(ScopeNotesWrite) || !got.LastUsedAt.IsZero() {
+			t.Errorf("GetByToken = %+v", got)
+		}
+		for _, wrong := range []string{"", token.Token[:len(token.Token)-1] + "x", "ffp_short", strings.Repeat("x", apiTokenLength)} {
+			_, err = m.GetByToken(wrong)
+			if err == nil || err.Error() != "API token not found" {
+				t.Errorf("GetByToken(%q) error = %v", wrong, err)
+			}
+		}
+
+		err = m.RecordUse(token.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ = m.GetByToken(token.Token)
+		if got.LastUsedAt.IsZero() {
+			t.Error("RecordUse didn't set the last-used time")
+		}
+
+		expired := &APIToken{Name: "Old", Scopes: []string{ScopeNotesWrite}, ExpiresAt: time.Now().Add(-time.Minute)}
+		err = m.Insert(expired, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.GetByToken(expired.Token)
+		if err == nil || err.Error() != "API token not found" {
+			t.Errorf("GetByToken of an expired token error = %v", err)
+		}
+		never := &APIToken{Name: "Phone", Scopes: []string{ScopeNotesRead, ScopeNotesWrite}}
+		err = m.Insert(never, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = m.GetByToken(never.Token)
+		if err != nil || !got.ExpiresAt.IsZero() {
+			t.Errorf("GetByToken of a token without expiry = %+v, %v", got, err)
+		}
+
+		all, err := m.GetAll()
+		if err != nil || len(all) != 3 || all[0].ID != never.ID {
+			t.Errorf("GetAll = %+v, %v; want all 3, newest first", all, err)
+		}
+
+		err = m.Delete(token.ID, testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.GetByToken(token.Token)
+		if err == nil {
+			t.Error("deleted token still found")
+		}
+		err = m.Delete(token.ID, testAudit)
+		if err == nil || err.Error() != "API token not found" {
+			t.Errorf("second Delete error = %v", err)
+		}
+		events, _, err := (&AuditModel{DB: db}).GetPage(AuditFilter{TargetType: "token"}, 1, 10)
+		if err != nil || len(events) != 4 || events[0].Action != AuditTokenRevoke {
+			t.Errorf("token audit events = %+v, %v; want 3 creations and a revocation", events, err)
+		}
+	})
+}
+
+func TestTokensConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		m := &TokenModel{DB: db}
+		token, err := m.New(TokenScopeEmailVerification, "me@example.com", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := validator.NewValidator()
+		ValidateTokenPlaintext(v, token.Plaintext)
+		if !v.ValidData() {
+			t.Fatalf("New returned a token that fails validation: %v", v.Errors)
+		}
+
+		_, err = m.Consume("password-reset", token.Plaintext)
+		if err == nil || err.Error() != "token not found" {
+			t.Errorf("Consume with the wrong scope error = %v", err)
+		}
+		email, err := m.Consume(TokenScopeEmailVerification, token.Plaintext)
+		if err != nil || email != "me@example.com" {
+			t.Fatalf("Consume = %q, %v", email, err)
+		}
+		_, err = m.Consume(TokenScopeEmailVerification, token.Plaintext)
+		if err == nil || err.Error() != "token not found" {
+			t.Errorf("second Consume error = %v", err)
+		}
+
+		// Expired tokens don't work, and the next New clears them out.
+		expired, err := m.New(TokenScopeEmailVerification, "me@example.com", -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.Consume(TokenScopeEmailVerification, expired.Plaintext)
+		if err == nil || err.Error() != "token not found" {
+			t.Errorf("Consume of an expired token error = %v", err)
+		}
+		_, err = m.New(TokenScopeEmailVerification, "me@example.com", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := countRows(t, db, "tokens"); n != 1 {
+			t.Errorf("%d tokens stored; want 1", n)
+		}
+
+		verified := &VerifiedEmailModel{DB: db}
+		for i := 0; i < 2; i++ {
+			err = verified.Insert("me@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for email, want := range map[string]bool{"me@example.com": true, "you@example.com": false} {
+			got, err := verified.Exists(email)
+			if err != nil || got != want {
+				t.Errorf("Exists(%q) = %v, %v; want %v", email, got, err, want)
+			}
+		}
+	})
+}
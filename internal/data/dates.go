@@ -0,0 +1,110 @@
+// internal/data/dates.go
+package data
+
+import (
+	"time"
+)
+
+// Period is a calendar unit used to group notes for listings and statistics.
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week" // Weeks start on Monday (ISO 8601)
+	PeriodMonth Period = "month"
+)
+
+// ParsePeriod converts a query string value into a Period, falling back to PeriodDay.
+func ParsePeriod(s string) Period {
+	switch Period(s) {
+	case PeriodWeek, PeriodMonth:
+		return Period(s)
+	default:
+		return PeriodDay
+	}
+}
+
+// startOfDay returns the first instant of the given day in loc (day may be out of
+// range, as with time.Date). That is midnight, except where DST begins at midnight
+// (Brazil until 2019, Cuba, Chile): there the day begins at 01:00, and time.Date
+// may resolve the missing midnight to 23:00 the day before instead.
+func startOfDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if t.Hour() != 0 && t.Day() != time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Day() {
+		// Still in the zone before the gap; the day starts when that zone ends.
+		_, t = t.ZoneBounds()
+	}
+	return t
+}
+
+// PeriodStart returns the start of the day, week or month containing t, as seen in loc.
+// Boundaries are built from the calendar date rather than by subtracting hours, so they
+// stay at the start of the local day even on days that are 23 or 25 hours long because of DST.
+func PeriodStart(t time.Time, loc *time.Location, p Period) time.Time {
+	t = t.In(loc)
+	year, month, day := t.Date()
+
+	switch p {
+	case PeriodMonth:
+		return startOfDay(year, month, 1, loc)
+	case PeriodWeek:
+		// time.Weekday has Sunday = 0; shift so Monday = 0.
+		offset := (int(t.Weekday()) + 6) % 7
+		return startOfDay(year, month, day-offset, loc)
+	default:
+		return startOfDay(year, month, day, loc)
+	}
+}
+
+// PeriodEnd returns the start of the period after the one starting at start, in
+// start's location. Like PeriodStart it works from the calendar date rather than
+// adding to start, whose wall clock isn't midnight on a day where DST begins at
+// midnight and would carry that hour into the next period.
+func PeriodEnd(start time.Time, p Period) time.Time {
+	year, month, day := start.Date()
+	switch p {
+	case PeriodMonth:
+		return startOfDay(year, month+1, 1, start.Location())
+	case PeriodWeek:
+		return startOfDay(year, month, day+7, start.Location())
+	default:
+		return startOfDay(year, month, day+1, start.Location())
+	}
+}
+
+// PeriodBefore returns the start of the period before the one starting at start,
+// in start's location. It counts back calendar days rather than subtracting the
+// period's length, which is an hour off when the period includes a DST change.
+func PeriodBefore(start time.Time, p Period) time.Time {
+	year, month, day := start.Date()
+	switch p {
+	case PeriodMonth:
+		return startOfDay(year, month-1, 1, start.Location())
+	case PeriodWeek:
+		return startOfDay(year, month, day-7, start.Location())
+	default:
+		return startOfDay(year, month, day-1, start.Location())
+	}
+}
+
+// NoteGroup is a run of notes that fall in the same day, week or month.
+type NoteGroup struct {
+	Start time.Time // Start of the period in the reader's timezone
+	End   time.Time // Start of the following period
+	Notes []*MoodNote
+}
+
+// GroupNotes buckets notes by their entry date (OccurredAt) in loc. Notes must already be sorted
+// (newest first, as GetAll returns them); the groups keep that order.
+func GroupNotes(notes []*MoodNote, loc *time.Location, p Period) []NoteGroup {
+	var groups []NoteGroup
+	for _, n := range notes {
+		start := PeriodStart(n.OccurredAt, loc, p)
+		if len(groups) == 0 || !groups[len(groups)-1].Start.Equal(start) {
+			groups = append(groups, NoteGroup{Start: start, End: PeriodEnd(start, p)})
+		}
+		last := &groups[len(groups)-1]
+		last.Notes = append(last.Notes, n)
+	}
+	return groups
+}
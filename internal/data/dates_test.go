@@ -0,0 +1,256 @@
+// internal/data/dates_test.go
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone %s: %v", name, err)
+	}
+	return loc
+}
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// Transitions used below:
+//   - America/New_York springs forward at 02:00 on 8 March 2026 and falls back at
+//     02:00 on 1 November 2026.
+//   - America/Sao_Paulo sprang forward at midnight on 4 November 2018, so that day
+//     began at 01:00, and fell back at midnight on 17 February 2019, so 16 February
+//     ran until midnight twice.
+//   - Australia/Lord_Howe moves by half an hour, at 02:00, on 4 October 2026.
+func TestPeriodStartAndEnd(t *testing.T) {
+	tests := []struct {
+		name      string
+		zone      string
+		t         string
+		period    Period
+		wantStart string
+		wantEnd   string
+	}{
+		{"ordinary day", "America/New_York", "2026-03-05T12:00:00-05:00", PeriodDay,
+			"2026-03-05T00:00:00-05:00", "2026-03-06T00:00:00-05:00"},
+		{"spring forward day", "America/New_York", "2026-03-08T12:00:00-04:00", PeriodDay,
+			"2026-03-08T00:00:00-05:00", "2026-03-09T00:00:00-04:00"},
+		{"before the spring gap", "America/New_York", "2026-03-08T01:59:00-05:00", PeriodDay,
+			"2026-03-08T00:00:00-05:00", "2026-03-09T00:00:00-04:00"},
+		{"fall back day", "America/New_York", "2026-11-01T12:00:00-05:00", PeriodDay,
+			"2026-11-01T00:00:00-04:00", "2026-11-02T00:00:00-05:00"},
+		{"second 01:30 of fall back day", "America/New_York", "2026-11-01T01:30:00-05:00", PeriodDay,
+			"2026-11-01T00:00:00-04:00", "2026-11-02T00:00:00-05:00"},
+		{"midnight spring forward", "America/Sao_Paulo", "2018-11-04T12:00:00-02:00", PeriodDay,
+			"2018-11-04T01:00:00-02:00", "2018-11-05T00:00:00-02:00"},
+		{"day before midnight spring forward", "America/Sao_Paulo", "2018-11-03T23:59:00-03:00", PeriodDay,
+			"2018-11-03T00:00:00-03:00", "2018-11-04T01:00:00-02:00"},
+		{"midnight fall back", "America/Sao_Paulo", "2019-02-16T23:30:00-03:00", PeriodDay,
+			"2019-02-16T00:00:00-02:00", "2019-02-17T00:00:00-03:00"},
+		{"half hour spring forward", "Australia/Lord_Howe", "2026-10-04T12:00:00+11:00", PeriodDay,
+			"2026-10-04T00:00:00+10:30", "2026-10-05T00:00:00+11:00"},
+
+		{"week with spring forward", "America/New_York", "2026-03-08T12:00:00-04:00", PeriodWeek,
+			"2026-03-02T00:00:00-05:00", "2026-03-09T00:00:00-04:00"},
+		{"week with fall back", "America/New_York", "2026-10-28T12:00:00-04:00", PeriodWeek,
+			"2026-10-26T00:00:00-04:00", "2026-11-02T00:00:00-05:00"},
+		{"week with midnight spring forward", "America/Sao_Paulo", "2018-11-04T12:00:00-02:00", PeriodWeek,
+			"2018-10-29T00:00:00-03:00", "2018-11-05T00:00:00-02:00"},
+		{"week starting after midnight spring forward", "America/Sao_Paulo", "2018-11-07T12:00:00-02:00", PeriodWeek,
+			"2018-11-05T00:00:00-02:00", "2018-11-12T00:00:00-02:00"},
+
+		{"month with spring forward", "America/New_York", "2026-03-31T23:00:00-04:00", PeriodMonth,
+			"2026-03-01T00:00:00-05:00", "2026-04-01T00:00:00-04:00"},
+		{"month with fall back", "America/New_York", "2026-11-15T12:00:00-05:00", PeriodMonth,
+			"2026-11-01T00:00:00-04:00", "2026-12-01T00:00:00-05:00"},
+		{"month with midnight fall back", "America/Sao_Paulo", "2019-02-28T12:00:00-03:00", PeriodMonth,
+			"2019-02-01T00:00:00-02:00", "2019-03-01T00:00:00-03:00"},
+		{"UTC instant in another local day", "America/New_York", "2026-03-09T03:00:00Z", PeriodDay,
+			"2026-03-08T00:00:00-05:00", "2026-03-09T00:00:00-04:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := mustLoadLocation(t, tt.zone)
+			start := PeriodStart(mustParseTime(t, tt.t), loc, tt.period)
+			if want := mustParseTime(t, tt.wantStart); !start.Equal(want) {
+				t.Errorf("PeriodStart = %s; want %s", start, want.In(loc))
+			}
+			if start.Location() != loc {
+				t.Errorf("PeriodStart is in %s; want %s", start.Location(), loc)
+			}
+			end := PeriodEnd(start, tt.period)
+			if want := mustParseTime(t, tt.wantEnd); !end.Equal(want) {
+				t.Errorf("PeriodEnd = %s; want %s", end, want.In(loc))
+			}
+		})
+	}
+}
+
+func TestPeriodBefore(t *testing.T) {
+	tests := []struct {
+		name   string
+		zone   string
+		start  string
+		period Period
+		want   string
+	}{
+		{"ordinary week", "America/New_York", "2026-03-02T00:00:00-05:00", PeriodWeek, "2026-02-23T00:00:00-05:00"},
+		{"week after spring forward", "America/New_York", "2026-03-09T00:00:00-04:00", PeriodWeek, "2026-03-02T00:00:00-05:00"},
+		{"week after fall back", "America/New_York", "2026-11-02T00:00:00-05:00", PeriodWeek, "2026-10-26T00:00:00-04:00"},
+		{"week after midnight spring forward", "America/Sao_Paulo", "2018-11-05T00:00:00-02:00", PeriodWeek, "2018-10-29T00:00:00-03:00"},
+		{"week after half hour spring forward", "Australia/Lord_Howe", "2026-10-05T00:00:00+11:00", PeriodWeek, "2026-09-28T00:00:00+10:30"},
+		{"day after spring forward", "America/New_York", "2026-03-09T00:00:00-04:00", PeriodDay, "2026-03-08T00:00:00-05:00"},
+		{"day after midnight spring forward", "America/Sao_Paulo", "2018-11-05T00:00:00-02:00", PeriodDay, "2018-11-04T01:00:00-02:00"},
+		{"month after spring forward", "America/New_York", "2026-04-01T00:00:00-04:00", PeriodMonth, "2026-03-01T00:00:00-05:00"},
+		{"january", "America/New_York", "2026-01-01T00:00:00-05:00", PeriodMonth, "2025-12-01T00:00:00-05:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := mustLoadLocation(t, tt.zone)
+			got := PeriodBefore(mustParseTime(t, tt.start).In(loc), tt.period)
+			if want := mustParseTime(t, tt.want); !got.Equal(want) {
+				t.Errorf("PeriodBefore = %s; want %s", got, want.In(loc))
+			}
+		})
+	}
+}
+
+// TestPeriodsTile checks, for every day of the years above, that each period ends
+// where the next begins, so no entry falls between two groups or into both.
+func TestPeriodsTile(t *testing.T) {
+	zones := []string{"UTC", "America/New_York", "America/Sao_Paulo", "Australia/Lord_Howe", "Asia/Kolkata", "Pacific/Chatham"}
+	for _, zone := range zones {
+		loc := mustLoadLocation(t, zone)
+		for _, p := range []Period{PeriodDay, PeriodWeek, PeriodMonth} {
+			start := PeriodStart(time.Date(2018, time.January, 1, 12, 0, 0, 0, loc), loc, p)
+			for start.Year() < 2027 {
+				end := PeriodEnd(start, p)
+				if !end.After(start) {
+					t.Fatalf("%s %s starting %s ends at %s", zone, p, start, end)
+				}
+				next := PeriodStart(end, loc, p)
+				if !next.Equal(end) {
+					t.Fatalf("%s %s starting %s ends at %s, but the next one starts at %s", zone, p, start, end, next)
+				}
+				if last := PeriodStart(end.Add(-time.Nanosecond), loc, p); !last.Equal(start) {
+					t.Fatalf("%s %s starting %s: its last instant belongs to the %s starting %s", zone, p, start, p, last)
+				}
+				if before := PeriodBefore(end, p); !before.Equal(start) {
+					t.Fatalf("%s %s starting %s: the one before the next starts at %s", zone, p, start, before)
+				}
+				start = end
+			}
+		}
+	}
+}
+
+func TestGroupNotes(t *testing.T) {
+	note := func(id int64, at string) *MoodNote {
+		return &MoodNote{ID: id, OccurredAt: mustParseTime(t, at)}
+	}
+	tests := []struct {
+		name   string
+		zone   string
+		period Period
+		notes  []*MoodNote // Newest first
+		want   [][]int64   // IDs per group
+	}{
+		{
+			"both 01:30s of a fall back day", "America/New_York", PeriodDay,
+			[]*MoodNote{
+				note(5, "2026-11-02T00:10:00-05:00"),
+				note(4, "2026-11-01T23:50:00-05:00"),
+				note(3, "2026-11-01T01:30:00-05:00"),
+				note(2, "2026-11-01T01:30:00-04:00"),
+				note(1, "2026-10-31T23:30:00-04:00"),
+			},
+			[][]int64{{5}, {4, 3, 2}, {1}},
+		},
+		{
+			"around a spring forward gap", "America/New_York", PeriodDay,
+			[]*MoodNote{
+				note(3, "2026-03-09T00:00:00-04:00"),
+				note(2, "2026-03-08T03:00:00-04:00"),
+				note(1, "2026-03-08T01:59:00-05:00"),
+			},
+			[][]int64{{3}, {2, 1}},
+		},
+		{
+			"midnight spring forward", "America/Sao_Paulo", PeriodDay,
+			[]*MoodNote{
+				note(3, "2018-11-05T00:00:00-02:00"),
+				note(2, "2018-11-04T01:00:00-02:00"),
+				note(1, "2018-11-03T23:59:00-03:00"),
+			},
+			[][]int64{{3}, {2}, {1}},
+		},
+		{
+			"midnight fall back", "America/Sao_Paulo", PeriodDay,
+			[]*MoodNote{
+				note(3, "2019-02-17T00:00:00-03:00"),
+				note(2, "2019-02-16T23:30:00-03:00"), // The second 23:30
+				note(1, "2019-02-16T23:30:00-02:00"),
+			},
+			[][]int64{{3}, {2, 1}},
+		},
+		{
+			"weeks across fall back", "America/New_York", PeriodWeek,
+			[]*MoodNote{
+				note(3, "2026-11-02T00:00:00-05:00"), // Monday
+				note(2, "2026-11-01T23:59:00-05:00"), // Sunday, after the change
+				note(1, "2026-10-26T00:00:00-04:00"), // Monday
+			},
+			[][]int64{{3}, {2, 1}},
+		},
+		{
+			"same instant, different local day", "Asia/Kolkata", PeriodDay,
+			[]*MoodNote{
+				note(2, "2026-03-08T19:00:00Z"), // 9 March, 00:30 in Kolkata
+				note(1, "2026-03-08T18:00:00Z"), // 8 March, 23:30
+			},
+			[][]int64{{2}, {1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := mustLoadLocation(t, tt.zone)
+			groups := GroupNotes(tt.notes, loc, tt.period)
+			var got [][]int64
+			for i, g := range groups {
+				var ids []int64
+				for _, n := range g.Notes {
+					ids = append(ids, n.ID)
+					if n.OccurredAt.Before(g.Start) || !n.OccurredAt.Before(g.End) {
+						t.Errorf("note %d at %s is outside its group [%s, %s)", n.ID, n.OccurredAt, g.Start, g.End)
+					}
+				}
+				got = append(got, ids)
+				if i > 0 && !g.End.Equal(groups[i-1].Start) && g.End.After(groups[i-1].Start) {
+					t.Errorf("group %d ends at %s, after the next one starts at %s", i, g.End, groups[i-1].Start)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("groups %v; want %v", got, tt.want)
+			}
+			for i := range got {
+				if len(got[i]) != len(tt.want[i]) {
+					t.Fatalf("groups %v; want %v", got, tt.want)
+				}
+				for j := range got[i] {
+					if got[i][j] != tt.want[i][j] {
+						t.Fatalf("groups %v; want %v", got, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+// internal/data/drafts.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/validator"
+)
+
+// DraftSlotNew is the draft slot of the new entry form. Drafts of an existing
+// note use the note's ID as their slot (see DraftSlot).
+const DraftSlotNew = "new"
+
+// DraftSlot returns the draft slot for editing the note with the given ID, or
+// DraftSlotNew when id is 0.
+func DraftSlot(id int64) string {
+	if id == 0 {
+		return DraftSlotNew
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// ParseDraftSlot returns the note ID a slot belongs to (0 for DraftSlotNew).
+func ParseDraftSlot(slot string) (int64, error) {
+	if slot == DraftSlotNew {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(slot, 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid draft slot")
+	}
+	return id, nil
+}
+
+// Draft is an autosaved copy of the note form. The fields hold the form's raw
+// values, which need not be valid yet; they are validated when the note is saved.
+type Draft struct {
+	Slot        string    `json:"slot"`
+	NoteID      int64     `json:"note_id,omitempty"`      // 0 for a new entry
+	BaseVersion int       `json:"base_version,omitempty"` // Version of the note when editing started
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	OccurredAt  string    `json:"occurred_at"` // datetime-local value in the writer's timezone
+	SavedAt     time.Time `json:"saved_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// ValidateDraft only checks sizes: a draft may be blank or half-written.
+func ValidateDraft(v *validator.Validator, d *Draft, limits MoodNoteLimits) {
+	v.Check(validator.MaxLength(d.Title, limits.TitleMaxLength), "title", fmt.Sprintf("must not be more than %d characters long", limits.TitleMaxLength))
+	v.Check(validator.MaxLength(d.Content, limits.ContentMaxLength), "content", fmt.Sprintf("must not be more than %d characters long", limits.ContentMaxLength))
+	v.Check(len(d.OccurredAt) <= 32, "occurred_at", "must be a valid date and time")
+}
+
+// DraftModel stores drafts. They are saved every few seconds while someone
+// types, so unlike notes they are not recorded in the audit log.
+type DraftModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+	Cipher       *NoteCipher // Encrypts title and content like notes; nil stores plaintext
+}
+
+// Save creates or replaces owner's draft in d.Slot, keeping it for ttl.
+func (m *DraftModel) Save(owner string, d *Draft, ttl time.Duration) error {
+	noteID, err := ParseDraftSlot(d.Slot)
+	if err != nil {
+		return err
+	}
+	title, content, keyID := d.Title, d.Content, sql.NullInt64{}
+	if m.Cipher != nil {
+		var id int64
+		title, id, err = m.Cipher.sealColumn("drafts.title", d.Title)
+		if err != nil {
+			return err
+		}
+		content, _, err = m.Cipher.sealColumn("drafts.content", d.Content)
+		if err != nil {
+			return err
+		}
+		keyID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	query := `
+		INSERT INTO drafts (owner, slot, note_id, base_version, title, content, occurred_at, key_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (owner, slot) DO UPDATE
+		SET base_version = EXCLUDED.base_version, title = EXCLUDED.title, content = EXCLUDED.content,
+			occurred_at = EXCLUDED.occurred_at, key_id = EXCLUDED.key_id, saved_at = NOW(), expires_at = EXCLUDED.expires_at
+		RETURNING saved_at`
+
+	args := []any{
+		owner, d.Slot,
+		sql.NullInt64{Int64: noteID, Valid: noteID > 0},
+		sql.NullInt64{Int64: int64(d.BaseVersion), Valid: d.BaseVersion > 0},
+		title, content, d.OccurredAt, keyID,
+		time.Now().Add(ttl),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(scanTime(&d.SavedAt))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return errors.New("mood note record not found")
+		}
+		return err
+	}
+	d.NoteID = noteID
+	return nil
+}
+
+// Get returns owner's unexpired draft in slot, or nil if there is none.
+func (m *DraftModel) Get(owner, slot string) (*Draft, error) {
+	query := `
+		SELECT slot, note_id, base_version, title, content, occurred_at, key_id, saved_at, expires_at
+		FROM drafts
+		WHERE owner = $1 AND slot = $2 AND expires_at > NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	d, err := m.scan(m.DB.QueryRowContext(ctx, query, owner, slot))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetAll returns every unexpired draft, newest first (for the data export).
+func (m *DraftModel) GetAll() ([]*Draft, error) {
+	query := `
+		SELECT slot, note_id, base_version, title, content, occurred_at, key_id, saved_at, expires_at
+		FROM drafts
+		WHERE expires_at > NOW()
+		ORDER BY saved_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drafts []*Draft
+	for rows.Next() {
+		d, err := m.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// scan reads one drafts row selected as in Get, decrypting it if needed.
+func (m *DraftModel) scan(row interface{ Scan(...any) error }) (*Draft, error) {
+	var (
+		d           Draft
+		noteID      sql.NullInt64
+		baseVersion sql.NullInt64
+		keyID       sql.NullInt64
+	)
+	err := row.Scan(&d.Slot, &noteID, &baseVersion, &d.Title, &d.Content, &d.OccurredAt, &keyID, &d.SavedAt, &d.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	d.NoteID = noteID.Int64
+	d.BaseVersion = int(baseVersion.Int64)
+	if keyID.Valid {
+		if m.Cipher == nil {
+			return nil, errors.New("draft is encrypted but no master key is configured")
+		}
+		d.Title, err = m.Cipher.openColumn("drafts.title", d.Title, keyID.Int64)
+		if err != nil {
+			return nil, err
+		}
+		d.Content, err = m.Cipher.openColumn("drafts.content", d.Content, keyID.Int64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &d, nil
+}
+
+// Discard deletes owner's draft in slot, if there is one.
+func (m *DraftModel) Discard(owner, slot string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM drafts WHERE owner = $1 AND slot = $2`, owner, slot)
+	return err
+}
+
+// PurgeExpired deletes drafts whose time is up and returns how many it deleted.
+func (m *DraftModel) PurgeExpired() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM drafts WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
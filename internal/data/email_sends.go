@@ -0,0 +1,50 @@
+// internal/data/email_sends.go
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// EmailSendModel records which scheduled emails have gone out, so a restart
+// (or two app instances) can never send the same email twice.
+type EmailSendModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// Claim atomically records that the kind email for recipient and the period
+// starting on periodStart (a local date) is being sent. It returns false if it
+// was already claimed, in which case the caller must not send it.
+func (m *EmailSendModel) Claim(kind, recipient string, periodStart time.Time) (bool, error) {
+	query := `
+		INSERT INTO email_sends (kind, recipient, period_start)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, recipient, period_start) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, kind, recipient, periodStart.Format("2006-01-02"))
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// Release removes a claim after a failed send so it is retried later.
+func (m *EmailSendModel) Release(kind, recipient string, periodStart time.Time) error {
+	query := `
+		DELETE FROM email_sends
+		WHERE kind = $1 AND recipient = $2 AND period_start = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, kind, recipient, periodStart.Format("2006-01-02"))
+	return err
+}
@@ -0,0 +1,87 @@
+// internal/data/journal.go
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"time"
+)
+
+// BuiltinTemplate is a note template that ships with the app. It is read-only
+// and addressed by its slug (e.g. /note/new?template=gratitude).
+type BuiltinTemplate struct {
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// JournalLibrary holds the built-in templates and reflective prompts.
+type JournalLibrary struct {
+	Templates []BuiltinTemplate
+	Prompts   []string
+}
+
+// LoadJournalLibrary reads templates.json and prompts.json from fsys (the
+// journal directory embedded in the ui package).
+func LoadJournalLibrary(fsys fs.FS) (*JournalLibrary, error) {
+	lib := &JournalLibrary{}
+	err := readJSONFile(fsys, "templates.json", &lib.Templates)
+	if err != nil {
+		return nil, err
+	}
+	err = readJSONFile(fsys, "prompts.json", &lib.Prompts)
+	if err != nil {
+		return nil, err
+	}
+	if len(lib.Prompts) == 0 {
+		return nil, errors.New("prompts.json holds no prompts")
+	}
+
+	seen := map[string]bool{}
+	for _, t := range lib.Templates {
+		if t.Slug == "" || t.Name == "" || t.Content == "" {
+			return nil, fmt.Errorf("templates.json: template %q needs a slug, name and content", t.Slug)
+		}
+		if seen[t.Slug] {
+			return nil, fmt.Errorf("templates.json: duplicate slug %q", t.Slug)
+		}
+		seen[t.Slug] = true
+	}
+	return lib, nil
+}
+
+// readJSONFile decodes the JSON file name in fsys into v.
+func readJSONFile(fsys fs.FS, name string, v any) error {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Template returns the built-in template with the given slug, or nil.
+func (lib *JournalLibrary) Template(slug string) *BuiltinTemplate {
+	for i := range lib.Templates {
+		if lib.Templates[i].Slug == slug {
+			return &lib.Templates[i]
+		}
+	}
+	return nil
+}
+
+// PromptOfTheDay picks a prompt for user on day's calendar date (in day's
+// location, so it changes at the writer's midnight). The same user and date
+// always get the same prompt, while different users see different ones.
+func (lib *JournalLibrary) PromptOfTheDay(user string, day time.Time) string {
+	h := fnv.New32a()
+	h.Write([]byte(user + "|" + day.Format(time.DateOnly)))
+	return lib.Prompts[h.Sum32()%uint32(len(lib.Prompts))]
+}
@@ -0,0 +1,207 @@
+// internal/data/models.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DBTX is what the models run their queries on: the connection pool, or a
+// transaction shared by several models (see Models.WithTx).
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Models groups every model so that one user action can change several tables
+// atomically with WithTx. Each field can still be used on its own.
+type Models struct {
+	DB *sql.DB
+
+	MoodNotes      *MoodNoteModel
+	NoteShares     *NoteShareModel
+	Templates      *NoteTemplateModel
+	Drafts         *DraftModel
+	Attachments    *AttachmentModel
+	AuditEvents    *AuditModel
+	EmailSends     *EmailSendModel
+	Unsubscribes   *UnsubscribeModel
+	DataExports    *DataExportModel
+	DataErasures   *DataErasureModel
+	APITokens      *APITokenModel
+	Tokens         *TokenModel
+	VerifiedEmails *VerifiedEmailModel
+}
+
+// NewModels returns the models backed by the pool db. cipher may be nil (notes stored in plaintext).
+func NewModels(db *sql.DB, queryTimeout time.Duration, cipher *NoteCipher) Models {
+	return Models{
+		DB:             db,
+		MoodNotes:      &MoodNoteModel{DB: db, QueryTimeout: queryTimeout, Cipher: cipher},
+		NoteShares:     &NoteShareModel{DB: db, QueryTimeout: queryTimeout},
+		Templates:      &NoteTemplateModel{DB: db, QueryTimeout: queryTimeout},
+		Drafts:         &DraftModel{DB: db, QueryTimeout: queryTimeout, Cipher: cipher},
+		Attachments:    &AttachmentModel{DB: db, QueryTimeout: queryTimeout},
+		AuditEvents:    &AuditModel{DB: db, QueryTimeout: queryTimeout},
+		EmailSends:     &EmailSendModel{DB: db, QueryTimeout: queryTimeout},
+		Unsubscribes:   &UnsubscribeModel{DB: db, QueryTimeout: queryTimeout},
+		DataExports:    &DataExportModel{DB: db, QueryTimeout: queryTimeout},
+		DataErasures:   &DataErasureModel{DB: db, QueryTimeout: queryTimeout, Cipher: cipher},
+		APITokens:      &APITokenModel{DB: db, QueryTimeout: queryTimeout},
+		Tokens:         &TokenModel{DB: db, QueryTimeout: queryTimeout},
+		VerifiedEmails: &VerifiedEmailModel{DB: db, QueryTimeout: queryTimeout},
+	}
+}
+
+// withDB returns copies of the models that run their queries on db.
+func (m Models) withDB(db DBTX) Models {
+	notes, shares, templates, drafts, attachments := *m.MoodNotes, *m.NoteShares, *m.Templates, *m.Drafts, *m.Attachments
+	audit, sends, unsubscribes := *m.AuditEvents, *m.EmailSends, *m.Unsubscribes
+	exports, erasures, apiTokens := *m.DataExports, *m.DataErasures, *m.APITokens
+	tokens, verified := *m.Tokens, *m.VerifiedEmails
+	notes.DB, shares.DB, templates.DB, drafts.DB, attachments.DB = db, db, db, db, db
+	audit.DB, sends.DB, unsubscribes.DB = db, db, db
+	exports.DB, erasures.DB, apiTokens.DB = db, db, db
+	tokens.DB, verified.DB = db, db
+	return Models{
+		DB:             m.DB,
+		MoodNotes:      &notes,
+		NoteShares:     &shares,
+		Templates:      &templates,
+		Drafts:         &drafts,
+		Attachments:    &attachments,
+		AuditEvents:    &audit,
+		EmailSends:     &sends,
+		Unsubscribes:   &unsubscribes,
+		DataExports:    &exports,
+		DataErasures:   &erasures,
+		APITokens:      &apiTokens,
+		Tokens:         &tokens,
+		VerifiedEmails: &verified,
+	}
+}
+
+// WithTx runs fn with models that all share one transaction, and commits it if fn
+// returns nil. If fn returns an error or panics, everything it did is rolled back
+// (a panic is then re-raised). Model methods that normally open their own
+// transaction join this one instead.
+//
+// If the database aborts the transaction because of a serialization failure or
+// deadlock (or SQLite is busy), fn is run again from the start, up to
+// txMaxAttempts times in all, so fn must not have side effects outside the database.
+func (m Models) WithTx(ctx context.Context, fn func(tx Models) error) error {
+	return runTx(ctx, m.DB, func(tx *sql.Tx) error {
+		return fn(m.withDB(tx))
+	})
+}
+
+// defaultQueryTimeout is used when a model is created without an explicit QueryTimeout.
+const defaultQueryTimeout = 3 * time.Second
+
+// queryTimeout returns the timeout to apply to a single database query for a
+// model whose QueryTimeout field is d: d itself, or defaultQueryTimeout when zero.
+func queryTimeout(d time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultQueryTimeout
+	}
+	return d
+}
+
+// --- Transactions ---
+
+// txMaxAttempts is how many times a transaction is tried before a retryable error is returned.
+const txMaxAttempts = 3
+
+// inTx runs fn in a transaction on db. When db is already a transaction (the
+// model belongs to a Models.WithTx unit of work), fn simply joins it and the
+// outer WithTx commits or rolls back.
+func inTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	switch db := db.(type) {
+	case *sql.Tx:
+		return fn(db)
+	case *sql.DB:
+		return runTx(ctx, db, func(tx *sql.Tx) error { return fn(tx) })
+	default:
+		return fmt.Errorf("data: cannot begin a transaction on %T", db)
+	}
+}
+
+// runTx begins a transaction on db, runs fn and commits, retrying the whole
+// transaction when it fails for a reason that retrying can fix.
+func runTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= txMaxAttempts; attempt++ {
+		err = runTxOnce(ctx, db, fn)
+		if err == nil || !retryableTxError(err) {
+			return err
+		}
+		// Back off a little longer each time so competing transactions can finish.
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+	return err
+}
+
+// runTxOnce is one attempt of runTx. The deferred Rollback also runs when fn
+// panics, so a panicking unit of work never leaves its changes behind.
+func runTxOnce(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op after Commit
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" // unique_violation
+	}
+	return sqliteUniqueViolation(err)
+}
+
+// isForeignKeyViolation reports whether err is a foreign key constraint violation.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23503" // foreign_key_violation
+	}
+	return sqliteForeignKeyViolation(err)
+}
+
+// errDataKeyErased means a note was sealed with a data key that a concurrent
+// EraseIfDue deleted. The cipher has moved to the new key by the time the
+// erasure commits, so the transaction can be retried.
+var errDataKeyErased = errors.New("data: the note's data key was erased while it was being saved")
+
+// retryableTxError reports whether err means the transaction was aborted only
+// because of concurrent transactions, so running it again may succeed.
+func retryableTxError(err error) bool {
+	if errors.Is(err, errDataKeyErased) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", "40P01": // serialization_failure, deadlock_detected
+			return true
+		}
+	}
+	return sqliteRetryable(err)
+}
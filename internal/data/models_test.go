@@ -0,0 +1,124 @@
+// internal/data/models_test.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestWithTx(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		models := NewModels(db, 0, nil)
+		note := &MoodNote{Title: "Note", Content: "Body", OccurredAt: time.Now().Add(-time.Hour)}
+		tmpl := &NoteTemplate{Name: "Template", Content: "Body"}
+		unitOfWork := func(tx Models) error {
+			err := tx.MoodNotes.Insert(note, testAudit)
+			if err != nil {
+				return err
+			}
+			return tx.Templates.Insert(tmpl, testAudit)
+		}
+		counts := func() [3]int {
+			return [3]int{countRows(t, db, "mood_notes"), countRows(t, db, "note_templates"), countRows(t, db, "audit_events")}
+		}
+
+		t.Run("rollback on error", func(t *testing.T) {
+			errStop := errors.New("stop")
+			err := models.WithTx(context.Background(), func(tx Models) error {
+				err := unitOfWork(tx)
+				if err != nil {
+					return err
+				}
+				return errStop
+			})
+			if !errors.Is(err, errStop) {
+				t.Fatalf("WithTx error = %v; want %v", err, errStop)
+			}
+			if got := counts(); got != [3]int{} {
+				t.Errorf("rows after rollback (notes, templates, audit events) = %v", got)
+			}
+		})
+
+		t.Run("rollback on panic", func(t *testing.T) {
+			func() {
+				defer func() {
+					if v := recover(); v != "boom" {
+						t.Errorf("recovered %v; want the panic re-raised", v)
+					}
+				}()
+				models.WithTx(context.Background(), func(tx Models) error {
+					err := unitOfWork(tx)
+					if err != nil {
+						t.Fatal(err)
+					}
+					panic("boom")
+				})
+			}()
+			if got := counts(); got != [3]int{} {
+				t.Errorf("rows after panic (notes, templates, audit events) = %v", got)
+			}
+		})
+
+		t.Run("commit", func(t *testing.T) {
+			err := models.WithTx(context.Background(), unitOfWork)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := counts(); got != [3]int{1, 1, 2} {
+				t.Errorf("rows after commit (notes, templates, audit events) = %v; want [1 1 2]", got)
+			}
+		})
+	})
+}
+
+func TestWithTxRetries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		models := NewModels(db, 0, nil)
+		tests := []struct {
+			name      string
+			err       error
+			failures  int // How many attempts fail with err
+			wantCalls int
+			wantErr   bool
+		}{
+			{"serialization failure", &pq.Error{Code: "40001"}, 2, 3, false},
+			{"deadlock", &pq.Error{Code: "40P01"}, 1, 2, false},
+			{"keeps failing", &pq.Error{Code: "40001"}, txMaxAttempts, txMaxAttempts, true},
+			{"not retryable", &pq.Error{Code: "23505"}, 1, 1, true},
+			{"plain error", errors.New("invalid"), 1, 1, true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				db.Exec("DELETE FROM note_templates")
+				calls := 0
+				err := models.WithTx(context.Background(), func(tx Models) error {
+					calls++
+					// Each attempt's changes must be rolled back before the next.
+					err := tx.Templates.Insert(&NoteTemplate{Name: "Only once", Content: "Body"}, testAudit)
+					if err != nil {
+						return err
+					}
+					if calls <= tt.failures {
+						return tt.err
+					}
+					return nil
+				})
+				if calls != tt.wantCalls || (err != nil) != tt.wantErr {
+					t.Fatalf("%d calls, error %v; want %d calls, error: %v", calls, err, tt.wantCalls, tt.wantErr)
+				}
+				want := 1
+				if tt.wantErr {
+					want = 0
+				}
+				if n := countRows(t, db, "note_templates"); n != want {
+					t.Errorf("%d templates; want %d", n, want)
+				}
+			})
+		}
+	})
+}
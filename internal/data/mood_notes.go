@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	// Ensure this import path is correct for your project
@@ -12,39 +13,126 @@ import (
 
 // MoodNote struct represents a single mood note entry in the database.
 type MoodNote struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Version   int       `json:"version"`
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`  // Audit timestamp: when the row was inserted
+	UpdatedAt  time.Time `json:"updated_at"`  // Audit timestamp: when the row last changed
+	OccurredAt time.Time `json:"occurred_at"` // The moment the entry is about; can be backdated by the writer
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Version    int       `json:"version"`
+	ClientID   string    `json:"-"` // Set by the browser for entries written offline; only used by Insert
 }
 
+// MoodNoteLimits holds the configurable size limits applied when validating a mood note.
+type MoodNoteLimits struct {
+	TitleMaxLength   int
+	ContentMaxLength int
+}
+
+// MinOccurredAt is the earliest entry date a mood note may have. Anything
+// before it is a typo (year 0026 for 2026) or a client sending nonsense, and
+// would sort to the end of every listing.
+var MinOccurredAt = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
 // ValidateMoodNote checks the mood note fields against validation rules.
-func ValidateMoodNote(v *validator.Validator, note *MoodNote) {
+func ValidateMoodNote(v *validator.Validator, note *MoodNote, limits MoodNoteLimits) {
 	v.Check(validator.NotBlank(note.Title), "title", "must be provided")
 	v.Check(validator.NotBlank(note.Content), "content", "must be provided")
-	v.Check(validator.MaxLength(note.Title, 150), "title", "must not be more than 150 characters long")
-	v.Check(validator.MaxLength(note.Content, 5000), "content", "must not be more than 5000 characters long")
+	v.Check(validator.MaxLength(note.Title, limits.TitleMaxLength), "title", fmt.Sprintf("must not be more than %d characters long", limits.TitleMaxLength))
+	v.Check(validator.MaxLength(note.Content, limits.ContentMaxLength), "content", fmt.Sprintf("must not be more than %d characters long", limits.ContentMaxLength))
+	v.Check(!note.OccurredAt.IsZero(), "occurred_at", "must be provided")
+	v.Check(!note.OccurredAt.Before(MinOccurredAt), "occurred_at", fmt.Sprintf("must not be before %d", MinOccurredAt.Year()))
+	// Allow a minute of slack: form inputs only have minute precision and clocks drift.
+	v.Check(!note.OccurredAt.After(time.Now().Add(time.Minute)), "occurred_at", "must not be in the future")
 }
 
 // MoodNoteModel struct provides methods for interacting with mood note data.
 type MoodNoteModel struct {
-	DB *sql.DB
+	DB           DBTX
+	QueryTimeout time.Duration
+	Cipher       *NoteCipher // Encrypts title and content at rest; nil stores plaintext
+}
+
+// --- Encryption ---
+
+// sealNote returns the title and content as they should be stored, plus the data
+// key that sealed them (NULL when encryption is off). Insert and Update call it
+// inside their transaction, so that a retry after EraseIfDue reseals with the new key.
+func (m *MoodNoteModel) sealNote(note *MoodNote) (title, content string, keyID sql.NullInt64, err error) {
+	if m.Cipher == nil {
+		return note.Title, note.Content, keyID, nil
+	}
+	title, id, err := m.Cipher.seal("title", note.Title)
+	if err != nil {
+		return "", "", keyID, err
+	}
+	content, _, err = m.Cipher.seal("content", note.Content)
+	if err != nil {
+		return "", "", keyID, err
+	}
+	return title, content, sql.NullInt64{Int64: id, Valid: true}, nil
+}
+
+// sealedWriteError returns errDataKeyErased if writing a note sealed with keyID
+// failed because that data key no longer exists, and err otherwise. The key_id
+// foreign key makes the write fail rather than store a note nobody can decrypt.
+func sealedWriteError(err error, keyID sql.NullInt64) error {
+	if keyID.Valid && isForeignKeyViolation(err) {
+		return errDataKeyErased
+	}
+	return err
 }
 
-// Insert adds a new MoodNote record into the 'mood_notes' table.
-func (m *MoodNoteModel) Insert(note *MoodNote) error {
+// openNote decrypts note.Title and note.Content in place if keyID says they were sealed.
+// Content may be empty for queries that only need the title.
+func (m *MoodNoteModel) openNote(note *MoodNote, keyID sql.NullInt64) error {
+	if !keyID.Valid {
+		return nil // Stored as plaintext
+	}
+	if m.Cipher == nil {
+		return errors.New("mood note is encrypted but no master key is configured")
+	}
+	var err error
+	note.Title, err = m.Cipher.open("title", note.Title, keyID.Int64)
+	if err != nil {
+		return err
+	}
+	if note.Content != "" {
+		note.Content, err = m.Cipher.open("content", note.Content, keyID.Int64)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- CRUD ---
+
+// Insert adds a new MoodNote record into the 'mood_notes' table, recording it in the audit log.
+func (m *MoodNoteModel) Insert(note *MoodNote, audit AuditInfo) error {
 	query := `
-		INSERT INTO mood_notes (title, content)
-		VALUES ($1, $2)
+		INSERT INTO mood_notes (title, content, occurred_at, key_id, client_id)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at, updated_at, version`
 
-	args := []any{note.Title, note.Content}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	clientID := sql.NullString{String: note.ClientID, Valid: note.ClientID != ""}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&note.ID, &note.CreatedAt, &note.UpdatedAt, &note.Version)
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		title, content, keyID, err := m.sealNote(note)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, query, title, content, note.OccurredAt, keyID, clientID).Scan(&note.ID, scanTime(&note.CreatedAt), scanTime(&note.UpdatedAt), &note.Version)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errors.New("duplicate mood note client ID")
+			}
+			return sealedWriteError(err, keyID)
+		}
+		return recordAudit(ctx, tx, audit, AuditNoteCreate, note.ID, 0, note.Version)
+	})
 }
 
 // Get retrieves a specific MoodNote record by ID.
@@ -53,22 +141,41 @@ func (m *MoodNoteModel) Get(id int64) (*MoodNote, error) {
 		return nil, errors.New("invalid mood note ID provided")
 	}
 
+	return m.getWhere("id = $1", id)
+}
+
+// GetByClientID returns the note the browser queued offline under clientID, so a
+// repeated sync of the same entry doesn't create it twice.
+func (m *MoodNoteModel) GetByClientID(clientID string) (*MoodNote, error) {
+	if clientID == "" {
+		return nil, errors.New("mood note record not found")
+	}
+	return m.getWhere("client_id = $1", clientID)
+}
+
+// getWhere returns the one note matching a WHERE condition with a single argument.
+func (m *MoodNoteModel) getWhere(condition string, arg any) (*MoodNote, error) {
 	query := `
-		SELECT id, created_at, updated_at, title, content, version
+		SELECT id, created_at, updated_at, occurred_at, title, content, version, key_id
 		FROM mood_notes
-		WHERE id = $1`
+		WHERE ` + condition
 
-	var note MoodNote
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	var (
+		note  MoodNote
+		keyID sql.NullInt64
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	err := m.DB.QueryRowContext(ctx, query, arg).Scan(
 		&note.ID,
 		&note.CreatedAt,
 		&note.UpdatedAt,
+		&note.OccurredAt,
 		&note.Title,
 		&note.Content,
 		&note.Version,
+		&keyID,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -77,20 +184,41 @@ func (m *MoodNoteModel) Get(id int64) (*MoodNote, error) {
 		return nil, err
 	}
 
+	err = m.openNote(&note, keyID)
+	if err != nil {
+		return nil, err
+	}
 	return &note, nil
 }
 
 // GetAll retrieves all mood note entries from the database.
 func (m *MoodNoteModel) GetAll() ([]*MoodNote, error) {
 	query := `
-		SELECT id, created_at, updated_at, title, content, version
+		SELECT id, created_at, updated_at, occurred_at, title, content, version, key_id
 		FROM mood_notes
-		ORDER BY created_at DESC`
+		ORDER BY occurred_at DESC, id DESC`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	return m.list(query)
+}
+
+// GetRecent retrieves the limit most recent mood notes, in GetAll's order,
+// decrypting only those.
+func (m *MoodNoteModel) GetRecent(limit int) ([]*MoodNote, error) {
+	query := `
+		SELECT id, created_at, updated_at, occurred_at, title, content, version, key_id
+		FROM mood_notes
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $1`
+
+	return m.list(query, limit)
+}
+
+// list runs a query selecting GetAll's columns and returns the decrypted notes.
+func (m *MoodNoteModel) list(query string, args ...any) ([]*MoodNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -99,17 +227,24 @@ func (m *MoodNoteModel) GetAll() ([]*MoodNote, error) {
 	var notes []*MoodNote
 	for rows.Next() {
 		n := &MoodNote{}
+		var keyID sql.NullInt64
 		err := rows.Scan(
 			&n.ID,
 			&n.CreatedAt,
 			&n.UpdatedAt,
+			&n.OccurredAt,
 			&n.Title,
 			&n.Content,
 			&n.Version,
+			&keyID,
 		)
 		if err != nil {
 			return nil, err
 		}
+		err = m.openNote(n, keyID)
+		if err != nil {
+			return nil, err
+		}
 		notes = append(notes, n)
 	}
 
@@ -120,59 +255,184 @@ func (m *MoodNoteModel) GetAll() ([]*MoodNote, error) {
 	return notes, nil
 }
 
-// Update modifies an existing mood note record.
-func (m *MoodNoteModel) Update(note *MoodNote) error {
+// GetNeighbours returns the notes written just before (previous) and just after (next)
+// the given note, ordered by entry date (occurred_at). Either result is nil at the ends of the list.
+// Only the ID and Title are filled in, which is all the navigation links need.
+func (m *MoodNoteModel) GetNeighbours(note *MoodNote) (*MoodNote, *MoodNote, error) {
+	// (occurred_at, id) is compared as a pair so notes with identical timestamps still have a stable order.
+	prevQuery := `
+		SELECT id, title, key_id
+		FROM mood_notes
+		WHERE (occurred_at, id) < ($1, $2)
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT 1`
+
+	nextQuery := `
+		SELECT id, title, key_id
+		FROM mood_notes
+		WHERE (occurred_at, id) > ($1, $2)
+		ORDER BY occurred_at ASC, id ASC
+		LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var neighbours [2]*MoodNote
+	for i, query := range []string{prevQuery, nextQuery} {
+		n := &MoodNote{}
+		var keyID sql.NullInt64
+		err := m.DB.QueryRowContext(ctx, query, note.OccurredAt, note.ID).Scan(&n.ID, &n.Title, &keyID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue // No neighbour in this direction
+			}
+			return nil, nil, err
+		}
+		err = m.openNote(n, keyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		neighbours[i] = n
+	}
+
+	return neighbours[0], neighbours[1], nil
+}
+
+// CountCreatedBetween returns how many notes were written (created_at) in [start, end).
+// Used to skip reminders on days the writer has already journaled.
+func (m *MoodNoteModel) CountCreatedBetween(start, end time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM mood_notes
+		WHERE created_at >= $1 AND created_at < $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, start, end).Scan(&count)
+	return count, err
+}
+
+// Update modifies an existing mood note record, recording it in the audit log.
+func (m *MoodNoteModel) Update(note *MoodNote, audit AuditInfo) error {
 	if note.ID < 1 {
 		return errors.New("invalid mood note ID for update")
 	}
 
 	query := `
 		UPDATE mood_notes
-		SET title = $1, content = $2, version = version + 1
-		WHERE id = $3 AND version = $4
+		SET title = $1, content = $2, occurred_at = $3, key_id = $4, version = version + 1, updated_at = NOW()
+		WHERE id = $5 AND version = $6
 		RETURNING updated_at, version`
 
-	args := []any{note.Title, note.Content, note.ID, note.Version}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&note.UpdatedAt, &note.Version)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return errors.New("mood note record not found or version mismatch")
+	versionBefore := note.Version
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		title, content, keyID, err := m.sealNote(note)
+		if err != nil {
+			return err
 		}
-		return err
-	}
-
-	return nil
+		err = tx.QueryRowContext(ctx, query, title, content, note.OccurredAt, keyID, note.ID, versionBefore).Scan(scanTime(&note.UpdatedAt), &note.Version)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("mood note record not found or version mismatch")
+			}
+			return sealedWriteError(err, keyID)
+		}
+		return recordAudit(ctx, tx, audit, AuditNoteUpdate, note.ID, versionBefore, note.Version)
+	})
 }
 
-// Delete removes a specific mood note entry from the database.
-func (m *MoodNoteModel) Delete(id int64) error {
+// Delete removes a specific mood note entry from the database, recording it in the audit log.
+func (m *MoodNoteModel) Delete(id int64, audit AuditInfo) error {
 	if id < 1 {
 		return errors.New("invalid mood note ID provided")
 	}
 
 	query := `
 		DELETE FROM mood_notes
-		WHERE id = $1`
+		WHERE id = $1
+		RETURNING version`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		var version int
+		err := tx.QueryRowContext(ctx, query, id).Scan(&version)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("mood note record not found or already deleted")
+			}
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditNoteDelete, id, version, 0)
+	})
+}
+
+// ReencryptNotes moves up to limit notes that aren't sealed with the active data key
+// (including plaintext notes from before encryption was turned on) to the active key,
+// and returns how many it changed. Versions and updated_at are left alone: the note
+// hasn't been edited. A note edited concurrently is skipped and picked up next time.
+func (m *MoodNoteModel) ReencryptNotes(limit int) (int, error) {
+	if m.Cipher == nil {
+		return 0, nil
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `
+		SELECT id, title, content, version, key_id
+		FROM mood_notes
+		WHERE key_id IS DISTINCT FROM $1
+		ORDER BY id
+		LIMIT $2`, m.Cipher.ActiveKeyID(), limit)
 	if err != nil {
-		return err
+		return 0, err
 	}
-
-	if rowsAffected == 0 {
-		return errors.New("mood note record not found or already deleted")
+	var notes []*MoodNote
+	var keyIDs []sql.NullInt64
+	for rows.Next() {
+		n := &MoodNote{}
+		var keyID sql.NullInt64
+		err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.Version, &keyID)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		notes = append(notes, n)
+		keyIDs = append(keyIDs, keyID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
 	}
 
-	return nil
-}
\ No newline at end of file
+	changed := 0
+	for i, n := range notes {
+		err := m.openNote(n, keyIDs[i])
+		if err != nil {
+			return changed, fmt.Errorf("note %d: %w", n.ID, err)
+		}
+		title, content, keyID, err := m.sealNote(n)
+		if err != nil {
+			return changed, err
+		}
+		result, err := m.DB.ExecContext(ctx, `
+			UPDATE mood_notes SET title = $1, content = $2, key_id = $3
+			WHERE id = $4 AND version = $5`, title, content, keyID, n.ID, n.Version)
+		if err != nil {
+			return changed, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return changed, err
+		}
+		changed += int(affected)
+	}
+	return changed, nil
+}
@@ -0,0 +1,36 @@
+// internal/data/mood_notes_test.go
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/validator"
+)
+
+func TestValidateMoodNoteOccurredAt(t *testing.T) {
+	tests := []struct {
+		name       string
+		occurredAt time.Time
+		wantError  string
+	}{
+		{"an hour ago", time.Now().Add(-time.Hour), ""},
+		{"first instant allowed", MinOccurredAt, ""},
+		{"missing", time.Time{}, "must be provided"},
+		{"year 1", time.Date(1, time.March, 1, 0, 0, 0, 0, time.UTC), "must not be before 1900"},
+		{"typo for 2026", time.Date(26, time.March, 1, 9, 30, 0, 0, time.UTC), "must not be before 1900"},
+		{"just before the minimum", MinOccurredAt.Add(-time.Second), "must not be before 1900"},
+		{"within the minute of slack", time.Now().Add(30 * time.Second), ""},
+		{"tomorrow", time.Now().AddDate(0, 0, 1), "must not be in the future"},
+	}
+	limits := MoodNoteLimits{TitleMaxLength: 100, ContentMaxLength: 1000}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.NewValidator()
+			ValidateMoodNote(v, &MoodNote{Title: "Title", Content: "Body", OccurredAt: tt.occurredAt}, limits)
+			if got := v.Errors["occurred_at"]; got != tt.wantError {
+				t.Errorf("occurred_at error = %q; want %q", got, tt.wantError)
+			}
+		})
+	}
+}
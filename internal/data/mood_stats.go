@@ -0,0 +1,122 @@
+// internal/data/mood_stats.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// DigestStats summarises one period of journaling for the weekly digest email.
+// It has no dominant moods or average intensity: a MoodNote is a title and free
+// text with no mood or intensity fields to count or average, so Entries against
+// PreviousEntries is the week-over-week comparison until it has them.
+type DigestStats struct {
+	Start           time.Time // Inclusive start of the period
+	End             time.Time // Exclusive end of the period
+	Entries         int       // Notes whose entry date falls in the period
+	PreviousEntries int       // Same count for the period before, for comparison
+	DaysJournaled   int       // Distinct local days with at least one entry
+	AverageLength   int       // Average content length in characters
+	Longest         *MoodNote // Longest entry in the period (nil if none)
+	YearAgo         *MoodNote // An entry from the same local day one year before End (nil if none)
+}
+
+// DigestStats aggregates the notes whose entry date (occurred_at) falls in the
+// period p starting at start (see PeriodStart), comparing with the period before
+// it. Both are calendar periods in loc, so a week with a DST change is still
+// Monday to Monday, and "days journaled" matches the writer's calendar.
+func (m *MoodNoteModel) DigestStats(start time.Time, p Period, loc *time.Location) (*DigestStats, error) {
+	start = start.In(loc)
+	end := PeriodEnd(start, p)
+	prevStart := PeriodBefore(start, p)
+	stats := &DigestStats{Start: start, End: end}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	// --- Counts ---
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE occurred_at >= $1),
+			COUNT(*) FILTER (WHERE occurred_at < $1)
+		FROM mood_notes
+		WHERE occurred_at >= $3 AND occurred_at < $2`
+
+	err := m.DB.QueryRowContext(ctx, query, start, end, prevStart).Scan(
+		&stats.Entries,
+		&stats.PreviousEntries,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lengths, the longest entry and distinct local days are worked out in Go:
+	// content may be encrypted, and loc may be the server's "Local" zone, which
+	// PostgreSQL can't name.
+	notes, err := m.notesBetween(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	days := map[time.Time]bool{}
+	totalLength, longestLength := 0, -1
+	for _, n := range notes {
+		days[PeriodStart(n.OccurredAt, loc, PeriodDay)] = true
+		length := utf8.RuneCountInString(n.Content)
+		totalLength += length
+		// Ties go to the most recent entry; notes are sorted newest first.
+		if length > longestLength {
+			stats.Longest, longestLength = n, length
+		}
+	}
+	stats.DaysJournaled = len(days)
+	if len(notes) > 0 {
+		stats.AverageLength = int(math.Round(float64(totalLength) / float64(len(notes))))
+	}
+
+	// --- Highlights ---
+	// "One year ago today": the local day before End, one year earlier.
+	lastDay := PeriodStart(end.Add(-time.Nanosecond), loc, PeriodDay)
+	yearAgoStart := PeriodStart(lastDay.AddDate(-1, 0, 0), loc, PeriodDay) // Either day may have begun at 01:00
+	yearAgo, err := m.notesBetween(ctx, yearAgoStart, PeriodEnd(yearAgoStart, PeriodDay))
+	if err != nil {
+		return nil, err
+	}
+	if len(yearAgo) > 0 {
+		stats.YearAgo = yearAgo[len(yearAgo)-1] // The earliest entry that day
+	}
+
+	return stats, nil
+}
+
+// notesBetween returns the (decrypted) notes whose entry date falls in [start, end), newest first.
+func (m *MoodNoteModel) notesBetween(ctx context.Context, start, end time.Time) ([]*MoodNote, error) {
+	rows, err := m.DB.QueryContext(ctx, `
+		SELECT id, occurred_at, title, content, version, key_id
+		FROM mood_notes
+		WHERE occurred_at >= $1 AND occurred_at < $2
+		ORDER BY occurred_at DESC, id DESC`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []*MoodNote
+	for rows.Next() {
+		n := &MoodNote{}
+		var keyID sql.NullInt64
+		err := rows.Scan(&n.ID, &n.OccurredAt, &n.Title, &n.Content, &n.Version, &keyID)
+		if err != nil {
+			return nil, err
+		}
+		err = m.openNote(n, keyID)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
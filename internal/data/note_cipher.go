@@ -0,0 +1,386 @@
+// internal/data/note_cipher.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/vault"
+)
+
+// NoteCipher encrypts note titles and content at rest. MoodNoteModel uses it
+// transparently: callers always see plaintext. New and edited notes are sealed
+// with the single active data key; older notes stay readable with whichever
+// key sealed them until the re-encryption job moves them to the active one.
+//
+// Because the database only holds ciphertext, nothing may filter or measure
+// title/content in SQL while encryption is on. Future full-text search has to
+// either decrypt and match in memory (fine at journal scale) or look up a blind
+// index: HMACs of normalised words under a separate key, stored beside the note.
+type NoteCipher struct {
+	DB           *sql.DB
+	QueryTimeout time.Duration
+	Keyring      *vault.Keyring
+	MaxKeyAge    time.Duration // Replace the active data key once it is this old; zero keeps it forever
+
+	mu     sync.RWMutex
+	keys   map[int64][]byte // Unwrapped data keys by ID
+	active int64
+}
+
+// Load reads the active data key's ID without creating, rotating or rewrapping
+// anything. It's for tools that inspect the keys; the app itself calls Setup.
+func (c *NoteCipher) Load() error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(c.QueryTimeout))
+	defer cancel()
+
+	var id int64
+	err := c.DB.QueryRowContext(ctx, `SELECT id FROM data_keys WHERE active`).Scan(&id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	c.mu.Lock()
+	c.active = id
+	c.mu.Unlock()
+	return nil
+}
+
+// Setup prepares the data keys at startup: keys wrapped by a retired master key
+// are rewrapped with the current one, and a new active data key is created if
+// there is none or the active one is older than MaxKeyAge.
+func (c *NoteCipher) Setup() error {
+	_, err := c.RewrapDataKeys()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(c.QueryTimeout))
+	defer cancel()
+
+	var (
+		id        int64
+		createdAt time.Time
+	)
+	err = c.DB.QueryRowContext(ctx, `SELECT id, created_at FROM data_keys WHERE active`).Scan(&id, &createdAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.RotateDataKey()
+	case err != nil:
+		return err
+	case c.MaxKeyAge > 0 && time.Since(createdAt) > c.MaxKeyAge:
+		return c.RotateDataKey()
+	}
+
+	c.mu.Lock()
+	c.active = id
+	c.mu.Unlock()
+	return nil
+}
+
+// RotateDataKey creates a new data key and makes it the active one. Notes sealed
+// with the previous key are moved over by ReencryptNotes.
+func (c *NoteCipher) RotateDataKey() error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(c.QueryTimeout))
+	defer cancel()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id, key, err := c.insertDataKey(ctx, tx)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.useDataKey(id, key)
+	return nil
+}
+
+// insertDataKey stores a new data key as the active one in tx, without using it
+// yet; call useDataKey once tx has committed.
+func (c *NoteCipher) insertDataKey(ctx context.Context, tx DBTX) (int64, []byte, error) {
+	key, err := vault.NewDataKey()
+	if err != nil {
+		return 0, nil, err
+	}
+	wrapped, masterID, err := c.Keyring.Wrap(key)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE data_keys SET active = FALSE WHERE active`)
+	if err != nil {
+		return 0, nil, err
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO data_keys (wrapped_key, master_key_id, active)
+		VALUES ($1, $2, TRUE)
+		RETURNING id`, wrapped, masterID).Scan(&id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, key, nil
+}
+
+// useDataKey makes a stored data key the active one. The caller holds c.mu.
+func (c *NoteCipher) useDataKey(id int64, key []byte) {
+	if c.keys == nil {
+		c.keys = make(map[int64][]byte)
+	}
+	c.keys[id] = key
+	c.active = id
+}
+
+// RewrapDataKeys re-encrypts every data key that was wrapped by a master key other
+// than the current one, and returns how many it changed. After it has run, the
+// old master key can be removed from the config.
+func (c *NoteCipher) RewrapDataKeys() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(c.QueryTimeout))
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, `
+		SELECT id, wrapped_key, master_key_id
+		FROM data_keys
+		WHERE master_key_id <> $1`, c.Keyring.CurrentID())
+	if err != nil {
+		return 0, err
+	}
+	type rewrap struct {
+		id       int64
+		wrapped  []byte
+		masterID string
+	}
+	var pending []rewrap
+	for rows.Next() {
+		var r rewrap
+		err := rows.Scan(&r.id, &r.wrapped, &r.masterID)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range pending {
+		key, err := c.Keyring.Unwrap(r.wrapped, r.masterID)
+		if err != nil {
+			return 0, fmt.Errorf("unwrapping data key %d: %w", r.id, err)
+		}
+		wrapped, masterID, err := c.Keyring.Wrap(key)
+		if err != nil {
+			return 0, err
+		}
+		_, err = c.DB.ExecContext(ctx, `
+			UPDATE data_keys SET wrapped_key = $1, master_key_id = $2
+			WHERE id = $3`, wrapped, masterID, r.id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
+}
+
+// DeleteUnusedDataKeys removes inactive data keys that no note, draft, export or
+// attachment refers to any more, so rotated-out key material doesn't linger in the
+// database or its backups. Drafts and exports aren't re-encrypted; they release
+// their key when they expire.
+func (c *NoteCipher) DeleteUnusedDataKeys() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(c.QueryTimeout))
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, `
+		DELETE FROM data_keys
+		WHERE NOT active
+		AND NOT EXISTS (SELECT 1 FROM mood_notes n WHERE n.key_id = data_keys.id)
+		AND NOT EXISTS (SELECT 1 FROM drafts d WHERE d.key_id = data_keys.id)
+		AND NOT EXISTS (SELECT 1 FROM data_exports e WHERE e.key_id = data_keys.id)
+		AND NOT EXISTS (SELECT 1 FROM attachments a WHERE a.key_id = data_keys.id)`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// Forget deleted keys; they're reloaded on demand if somehow still needed.
+	c.mu.Lock()
+	for id := range c.keys {
+		if id != c.active {
+			delete(c.keys, id)
+		}
+	}
+	c.mu.Unlock()
+	return int(n), nil
+}
+
+// KeyStats summarises the state of note encryption, for operators.
+type KeyStats struct {
+	DataKeys         int `json:"data_keys"`           // Data keys in the database, active or not
+	StaleWrapped     int `json:"stale_wrapped"`       // Data keys wrapped by a master key other than the current one
+	Notes            int `json:"notes"`               // All notes
+	NotesOnActiveKey int `json:"notes_on_active_key"` // Notes already sealed with the active data key
+}
+
+// Stats counts data keys and how far notes have moved to the active key.
+func (c *NoteCipher) Stats() (KeyStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(c.QueryTimeout))
+	defer cancel()
+
+	var s KeyStats
+	err := c.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE master_key_id <> $1)
+		FROM data_keys`, c.Keyring.CurrentID()).Scan(&s.DataKeys, &s.StaleWrapped)
+	if err != nil {
+		return s, err
+	}
+	err = c.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE key_id = $1)
+		FROM mood_notes`, c.ActiveKeyID()).Scan(&s.Notes, &s.NotesOnActiveKey)
+	return s, err
+}
+
+// ActiveKeyID returns the ID of the data key that seals new notes.
+func (c *NoteCipher) ActiveKeyID() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.active
+}
+
+// dataKey returns the unwrapped data key with the given ID, loading it on first use.
+func (c *NoteCipher) dataKey(id int64) ([]byte, error) {
+	c.mu.RLock()
+	key, ok := c.keys[id]
+	c.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(c.QueryTimeout))
+	defer cancel()
+
+	var (
+		wrapped  []byte
+		masterID string
+	)
+	err := c.DB.QueryRowContext(ctx, `SELECT wrapped_key, master_key_id FROM data_keys WHERE id = $1`, id).Scan(&wrapped, &masterID)
+	if err != nil {
+		return nil, fmt.Errorf("loading data key %d: %w", id, err)
+	}
+	key, err = c.Keyring.Unwrap(wrapped, masterID)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key %d: %w", id, err)
+	}
+
+	c.mu.Lock()
+	if c.keys == nil {
+		c.keys = make(map[int64][]byte)
+	}
+	c.keys[id] = key
+	c.mu.Unlock()
+	return key, nil
+}
+
+// seal encrypts one field of a note with the active data key. The field name is
+// authenticated, so a title's ciphertext can't be swapped into the content column.
+func (c *NoteCipher) seal(field, plaintext string) (string, int64, error) {
+	return c.sealColumn("mood_notes."+field, plaintext)
+}
+
+// open reverses seal for a value sealed with data key keyID.
+func (c *NoteCipher) open(field, stored string, keyID int64) (string, error) {
+	return c.openColumn("mood_notes."+field, stored, keyID)
+}
+
+// sealColumn encrypts a value for the column named "table.column", which is
+// authenticated along with it. seal is sealColumn for mood_notes.
+func (c *NoteCipher) sealColumn(column, plaintext string) (string, int64, error) {
+	id := c.ActiveKeyID()
+	if id == 0 {
+		return "", 0, errors.New("note cipher has no active data key (Setup not called)")
+	}
+	key, err := c.dataKey(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, errDataKeyErased // Erased since ActiveKeyID; see EraseIfDue
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	sealed, err := vault.Seal(key, []byte(plaintext), []byte(column))
+	if err != nil {
+		return "", 0, err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), id, nil
+}
+
+// openColumn reverses sealColumn for a value sealed with data key keyID.
+func (c *NoteCipher) openColumn(column, stored string, keyID int64) (string, error) {
+	key, err := c.dataKey(keyID)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil {
+		return "", fmt.Errorf("decoding encrypted %s: %w", column, err)
+	}
+	plaintext, err := vault.Open(key, sealed, []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s: %w", column, err)
+	}
+	return string(plaintext), nil
+}
+
+// --- Blobs ---
+
+// SealStream returns a writer that encrypts everything written to it onto w with
+// the active data key, and that key's ID, to be stored with the blob. name (the
+// blob's key) is authenticated along with the contents, so one blob can't be
+// passed off as another. Close the writer to finish the blob.
+func (c *NoteCipher) SealStream(w io.Writer, name string) (io.WriteCloser, int64, error) {
+	id := c.ActiveKeyID()
+	if id == 0 {
+		return nil, 0, errors.New("note cipher has no active data key (Setup not called)")
+	}
+	key, err := c.dataKey(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	sw, err := vault.NewStreamWriter(w, key, []byte("blob:"+name))
+	if err != nil {
+		return nil, 0, err
+	}
+	return sw, id, nil
+}
+
+// OpenStream decrypts a blob of size bytes written through SealStream with data key keyID.
+func (c *NoteCipher) OpenStream(r io.ReadSeeker, size int64, name string, keyID int64) (*vault.StreamReader, error) {
+	key, err := c.dataKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	sr, err := vault.NewStreamReader(r, size, key, []byte("blob:"+name))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", name, err)
+	}
+	return sr, nil
+}
@@ -0,0 +1,257 @@
+// internal/data/note_shares.go
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/validator"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// NoteShare is a read-only link to a single note.
+type NoteShare struct {
+	ID           int64     `json:"id"`
+	NoteID       int64     `json:"note_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"` // Zero means the link never expires
+	RevokedAt    time.Time `json:"revoked_at"` // Zero while the link is live
+	Views        int       `json:"views"`
+	LastViewedAt time.Time `json:"last_viewed_at"` // Zero until the link is first opened
+	HasPasscode  bool      `json:"has_passcode"`
+
+	// Token is the secret part of the link. Only a hash is stored, so it is
+	// set on the value returned by Insert and nowhere else.
+	Token string `json:"-"`
+
+	passcodeHash []byte
+}
+
+// shareTokenLength is the length of an encoded share token (32 random bytes, base64url).
+const shareTokenLength = 43
+
+// ValidateSharePasscode checks an optional passcode chosen for a share link.
+// bcrypt only looks at the first 72 bytes, hence the upper limit.
+func ValidateSharePasscode(v *validator.Validator, passcode string) {
+	if passcode == "" {
+		return
+	}
+	v.Check(validator.MinLength(passcode, 4), "passcode", "must be at least 4 characters long")
+	v.Check(len(passcode) <= 72, "passcode", "must not be more than 72 bytes long")
+}
+
+// CheckPasscode reports whether passcode unlocks the share. Shares without a passcode always match.
+func (s *NoteShare) CheckPasscode(passcode string) bool {
+	if !s.HasPasscode {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword(s.passcodeHash, []byte(passcode)) == nil
+}
+
+// NoteShareModel stores share links.
+type NoteShareModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// hashShareToken returns the value stored in note_shares.token_hash. A fast hash
+// is fine here: tokens are 256 random bits, not guessable passwords.
+func hashShareToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// Insert creates a share link for noteID. A zero expiresAt never expires and an
+// empty passcode means none is required. The returned share carries the plaintext Token.
+func (m *NoteShareModel) Insert(noteID int64, expiresAt time.Time, passcode string, audit AuditInfo) (*NoteShare, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	share := &NoteShare{
+		NoteID:      noteID,
+		ExpiresAt:   expiresAt,
+		HasPasscode: passcode != "",
+		Token:       base64.RawURLEncoding.EncodeToString(b),
+	}
+
+	var passcodeHash []byte
+	if share.HasPasscode {
+		passcodeHash, err = bcrypt.GenerateFromPassword([]byte(passcode), 12)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var expires sql.NullTime
+	if !expiresAt.IsZero() {
+		expires = sql.NullTime{Time: expiresAt, Valid: true}
+	}
+
+	query := `
+		INSERT INTO note_shares (note_id, token_hash, passcode_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	err = inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, query, noteID, hashShareToken(share.Token), passcodeHash, expires).Scan(&share.ID, scanTime(&share.CreatedAt))
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditShareCreate, share.ID, 0, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// GetByToken returns the live (not revoked, not expired) share for token.
+func (m *NoteShareModel) GetByToken(token string) (*NoteShare, error) {
+	if len(token) != shareTokenLength {
+		return nil, errors.New("share link not found")
+	}
+
+	query := `
+		SELECT id, note_id, created_at, expires_at, revoked_at, views, last_viewed_at, passcode_hash
+		FROM note_shares
+		WHERE token_hash = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW())`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	share, err := scanShare(m.DB.QueryRowContext(ctx, query, hashShareToken(token)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("share link not found")
+		}
+		return nil, err
+	}
+	return share, nil
+}
+
+// GetAllForNote returns the live shares of a note, newest first.
+func (m *NoteShareModel) GetAllForNote(noteID int64) ([]*NoteShare, error) {
+	query := `
+		SELECT id, note_id, created_at, expires_at, revoked_at, views, last_viewed_at, passcode_hash
+		FROM note_shares
+		WHERE note_id = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []*NoteShare
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// GetAll returns every share ever created, including expired and revoked ones, oldest first.
+func (m *NoteShareModel) GetAll() ([]*NoteShare, error) {
+	query := `
+		SELECT id, note_id, created_at, expires_at, revoked_at, views, last_viewed_at, passcode_hash
+		FROM note_shares
+		ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []*NoteShare
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// scanShare reads the columns selected by the NoteShareModel queries.
+func scanShare(row interface{ Scan(...any) error }) (*NoteShare, error) {
+	var (
+		share        NoteShare
+		expiresAt    sql.NullTime
+		revokedAt    sql.NullTime
+		lastViewedAt sql.NullTime
+	)
+	err := row.Scan(&share.ID, &share.NoteID, &share.CreatedAt, &expiresAt, &revokedAt, &share.Views, &lastViewedAt, &share.passcodeHash)
+	if err != nil {
+		return nil, err
+	}
+	share.ExpiresAt = expiresAt.Time
+	share.RevokedAt = revokedAt.Time
+	share.LastViewedAt = lastViewedAt.Time
+	share.HasPasscode = share.passcodeHash != nil
+	return &share, nil
+}
+
+// RecordView counts one view of a share.
+func (m *NoteShareModel) RecordView(id int64) error {
+	query := `
+		UPDATE note_shares
+		SET views = views + 1, last_viewed_at = NOW()
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// Revoke disables a share of noteID immediately. The row is kept so its view count stays on record.
+func (m *NoteShareModel) Revoke(noteID, id int64, audit AuditInfo) error {
+	query := `
+		UPDATE note_shares
+		SET revoked_at = NOW()
+		WHERE id = $1 AND note_id = $2 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		result, err := tx.ExecContext(ctx, query, id, noteID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return errors.New("share link not found or already revoked")
+		}
+		return recordAudit(ctx, tx, audit, AuditShareRevoke, id, 0, 0)
+	})
+}
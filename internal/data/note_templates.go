@@ -0,0 +1,173 @@
+// internal/data/note_templates.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/validator"
+)
+
+// NoteTemplate is one of the owner's own templates for new entries. Built-in
+// templates ship with the app instead (see JournalLibrary).
+type NoteTemplate struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`    // Shown in the template picker
+	Title     string    `json:"title"`   // Pre-filled entry title; may be empty
+	Content   string    `json:"content"` // Pre-filled entry content (Markdown)
+	Version   int       `json:"version"`
+}
+
+// noteTemplateNameMaxLength keeps names short enough for the picker.
+const noteTemplateNameMaxLength = 60
+
+// ValidateNoteTemplate checks a template against the same size limits as notes,
+// so anything it pre-fills can be saved as an entry.
+func ValidateNoteTemplate(v *validator.Validator, t *NoteTemplate, limits MoodNoteLimits) {
+	v.Check(validator.NotBlank(t.Name), "name", "must be provided")
+	v.Check(validator.MaxLength(t.Name, noteTemplateNameMaxLength), "name", fmt.Sprintf("must not be more than %d characters long", noteTemplateNameMaxLength))
+	v.Check(validator.NotBlank(t.Content), "content", "must be provided")
+	v.Check(validator.MaxLength(t.Title, limits.TitleMaxLength), "title", fmt.Sprintf("must not be more than %d characters long", limits.TitleMaxLength))
+	v.Check(validator.MaxLength(t.Content, limits.ContentMaxLength), "content", fmt.Sprintf("must not be more than %d characters long", limits.ContentMaxLength))
+}
+
+// NoteTemplateModel stores the owner's note templates.
+type NoteTemplateModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// Insert adds a template, recording it in the audit log.
+func (m *NoteTemplateModel) Insert(t *NoteTemplate, audit AuditInfo) error {
+	query := `
+		INSERT INTO note_templates (name, title, content)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, query, t.Name, t.Title, t.Content).Scan(&t.ID, scanTime(&t.CreatedAt), scanTime(&t.UpdatedAt), &t.Version)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errors.New("duplicate note template name")
+			}
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditTemplateCreate, t.ID, 0, t.Version)
+	})
+}
+
+// Get returns one template.
+func (m *NoteTemplateModel) Get(id int64) (*NoteTemplate, error) {
+	if id < 1 {
+		return nil, errors.New("note template not found")
+	}
+
+	query := `
+		SELECT id, created_at, updated_at, name, title, content, version
+		FROM note_templates
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var t NoteTemplate
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt, &t.Name, &t.Title, &t.Content, &t.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("note template not found")
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetAll returns every template, by name.
+func (m *NoteTemplateModel) GetAll() ([]*NoteTemplate, error) {
+	query := `
+		SELECT id, created_at, updated_at, name, title, content, version
+		FROM note_templates
+		ORDER BY LOWER(name), id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*NoteTemplate
+	for rows.Next() {
+		t := &NoteTemplate{}
+		err := rows.Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt, &t.Name, &t.Title, &t.Content, &t.Version)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// Update saves changes to a template if t.Version is still current (optimistic
+// locking, as for notes), recording it in the audit log.
+func (m *NoteTemplateModel) Update(t *NoteTemplate, audit AuditInfo) error {
+	query := `
+		UPDATE note_templates
+		SET name = $1, title = $2, content = $3, version = version + 1, updated_at = NOW()
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	versionBefore := t.Version
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, query, t.Name, t.Title, t.Content, t.ID, versionBefore).Scan(scanTime(&t.UpdatedAt), &t.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return errors.New("note template not found or version mismatch")
+			case isUniqueViolation(err):
+				return errors.New("duplicate note template name")
+			}
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditTemplateUpdate, t.ID, versionBefore, t.Version)
+	})
+}
+
+// Delete removes a template, recording it in the audit log. Notes written from
+// it are unaffected: a template only pre-fills the form.
+func (m *NoteTemplateModel) Delete(id int64, audit AuditInfo) error {
+	query := `
+		DELETE FROM note_templates
+		WHERE id = $1
+		RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		var version int
+		err := tx.QueryRowContext(ctx, query, id).Scan(&version)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("note template not found")
+			}
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditTemplateDelete, id, version, 0)
+	})
+}
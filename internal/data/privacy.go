@@ -0,0 +1,455 @@
+// internal/data/privacy.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// --- Data Export ---
+
+// DataExport is a "download all my data" request. Once built, its archive is
+// kept in the blob store under BlobKey, sealed with data key KeyID.
+type DataExport struct {
+	ID          int64     `json:"id"`
+	RequestedAt time.Time `json:"requested_at"`
+	Status      string    `json:"status"`       // pending, building, ready, failed or expired
+	CompletedAt time.Time `json:"completed_at"` // Zero until the build finishes
+	ExpiresAt   time.Time `json:"expires_at"`   // When a ready archive stops being downloadable
+	Error       string    `json:"error"`        // Why the build failed
+	BlobKey     string    `json:"-"`            // Set while ready
+	KeyID       int64     `json:"-"`            // 0 when the archive was stored without encryption
+}
+
+// DataExportBlobKey returns the blob key for export id's archive. It depends only
+// on the ID, so rebuilding an export interrupted by a restart replaces its blob.
+func DataExportBlobKey(id int64) string {
+	return fmt.Sprintf("exports/%d", id)
+}
+
+// Ready reports whether the archive can be downloaded now.
+func (e *DataExport) Ready() bool {
+	return e.Status == "ready" && time.Now().Before(e.ExpiresAt)
+}
+
+// DataExportModel stores export requests and where their archives are.
+type DataExportModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// Insert queues a new export for the background worker.
+func (m *DataExportModel) Insert(audit AuditInfo) (*DataExport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	e := &DataExport{Status: "pending"}
+	err := inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO data_exports DEFAULT VALUES
+			RETURNING id, requested_at`).Scan(&e.ID, scanTime(&e.RequestedAt))
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditDataExport, e.ID, 0, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ClaimPending marks the oldest pending export as building and returns its ID,
+// or 0 if there is nothing to do. The outer status check keeps two workers from
+// taking the same one: the second waits for the first's row lock, sees the export
+// is no longer pending and claims nothing this time.
+func (m *DataExportModel) ClaimPending() (int64, error) {
+	query := `
+		UPDATE data_exports SET status = 'building'
+		WHERE status = 'pending' AND id = (
+			SELECT id FROM data_exports
+			WHERE status = 'pending'
+			ORDER BY id
+			LIMIT 1
+		)
+		RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var id int64
+	err := m.DB.QueryRowContext(ctx, query).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// ResetStale puts exports left in 'building' by a crash or restart back in the queue.
+func (m *DataExportModel) ResetStale() error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `UPDATE data_exports SET status = 'pending' WHERE status = 'building'`)
+	return err
+}
+
+// Complete records that an export's archive is in the blob store under blobKey,
+// sealed with data key keyID (0 for none), and downloadable until expiresAt.
+func (m *DataExportModel) Complete(id int64, blobKey string, keyID int64, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `
+		UPDATE data_exports
+		SET status = 'ready', blob_key = $1, key_id = $2, completed_at = NOW(), expires_at = $3
+		WHERE id = $4`, blobKey, sql.NullInt64{Int64: keyID, Valid: keyID != 0}, expiresAt, id)
+	return err
+}
+
+// Fail records that an export could not be built.
+func (m *DataExportModel) Fail(id int64, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `
+		UPDATE data_exports
+		SET status = 'failed', error = $1, completed_at = NOW()
+		WHERE id = $2`, reason, id)
+	return err
+}
+
+// GetRecent returns the latest exports, newest first, without their archives.
+func (m *DataExportModel) GetRecent(limit int) ([]*DataExport, error) {
+	query := `
+		SELECT id, requested_at, status, completed_at, expires_at, error
+		FROM data_exports
+		ORDER BY id DESC
+		LIMIT $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []*DataExport
+	for rows.Next() {
+		e, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// Get returns an export's status, without its archive.
+func (m *DataExportModel) Get(id int64) (*DataExport, error) {
+	query := `
+		SELECT id, requested_at, status, completed_at, expires_at, error
+		FROM data_exports
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	e, err := scanDataExport(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("data export not found")
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// scanDataExport reads the columns selected by GetRecent and Get.
+func scanDataExport(row interface{ Scan(...any) error }) (*DataExport, error) {
+	var (
+		e                      DataExport
+		completedAt, expiresAt sql.NullTime
+	)
+	err := row.Scan(&e.ID, &e.RequestedAt, &e.Status, &completedAt, &expiresAt, &e.Error)
+	if err != nil {
+		return nil, err
+	}
+	e.CompletedAt = completedAt.Time
+	e.ExpiresAt = expiresAt.Time
+	return &e, nil
+}
+
+// GetReady returns a ready, unexpired export.
+func (m *DataExportModel) GetReady(id int64) (*DataExport, error) {
+	query := `
+		SELECT id, requested_at, completed_at, expires_at, blob_key, key_id
+		FROM data_exports
+		WHERE id = $1 AND status = 'ready' AND expires_at > NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	e := &DataExport{Status: "ready"}
+	var keyID sql.NullInt64
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&e.ID, &e.RequestedAt, &e.CompletedAt, &e.ExpiresAt, &e.BlobKey, &keyID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("data export not found or expired")
+		}
+		return nil, err
+	}
+	e.KeyID = keyID.Int64
+	return e, nil
+}
+
+// Expired returns up to limit ready exports whose download window has passed.
+// The data job deletes each one's blob, then calls MarkExpired.
+func (m *DataExportModel) Expired(limit int) ([]*DataExport, error) {
+	query := `
+		SELECT id, blob_key
+		FROM data_exports
+		WHERE status = 'ready' AND expires_at <= NOW()
+		ORDER BY id
+		LIMIT $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []*DataExport
+	for rows.Next() {
+		e := &DataExport{Status: "ready"}
+		err := rows.Scan(&e.ID, &e.BlobKey)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// MarkExpired records that an expired export's blob has been deleted.
+func (m *DataExportModel) MarkExpired(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `
+		UPDATE data_exports SET status = 'expired', blob_key = NULL, key_id = NULL
+		WHERE id = $1`, id)
+	return err
+}
+
+// --- Data Erasure ---
+
+// DataErasure is a scheduled "delete all my data" request.
+type DataErasure struct {
+	ID           int64
+	RequestedAt  time.Time
+	ScheduledFor time.Time
+}
+
+// erasedTables lists every table holding the owner's data, children before parents.
+// data_erasures itself is kept so the completed erasure stays on record.
+// attachments is not listed: deleting mood_notes detaches them, and the data
+// job removes detached attachments' files and rows right after the erasure.
+var erasedTables = []string{
+	"drafts",
+	"note_shares",
+	"audit_events",
+	"mood_notes",
+	"note_templates",
+	"data_exports",
+	"data_keys",
+	"email_sends",
+	"email_unsubscribes",
+	"api_tokens",
+	"tokens",
+	"verified_emails",
+}
+
+// DataErasureModel schedules, cancels and carries out erasure.
+type DataErasureModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+	Cipher       *NoteCipher // Given a fresh data key by the erasure; nil when encryption is off
+}
+
+// Schedule asks for all data to be erased at scheduledFor.
+func (m *DataErasureModel) Schedule(scheduledFor time.Time, audit AuditInfo) (*DataErasure, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	e := &DataErasure{ScheduledFor: scheduledFor}
+	err := inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO data_erasures (scheduled_for)
+			VALUES ($1)
+			ON CONFLICT DO NOTHING
+			RETURNING id, requested_at`, scheduledFor).Scan(&e.ID, scanTime(&e.RequestedAt))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("data erasure already scheduled")
+			}
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditEraseSchedule, e.ID, 0, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Pending returns the scheduled erasure, or nil if there is none.
+func (m *DataErasureModel) Pending() (*DataErasure, error) {
+	query := `
+		SELECT id, requested_at, scheduled_for
+		FROM data_erasures
+		WHERE cancelled_at IS NULL AND completed_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var e DataErasure
+	err := m.DB.QueryRowContext(ctx, query).Scan(&e.ID, &e.RequestedAt, &e.ScheduledFor)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Cancel calls off the scheduled erasure.
+func (m *DataErasureModel) Cancel(audit AuditInfo) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		var id int64
+		err := tx.QueryRowContext(ctx, `
+			UPDATE data_erasures SET cancelled_at = NOW()
+			WHERE cancelled_at IS NULL AND completed_at IS NULL
+			RETURNING id`).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("no data erasure scheduled")
+			}
+			return err
+		}
+		return recordAudit(ctx, tx, audit, AuditEraseCancel, id, 0, 0)
+	})
+}
+
+// EraseIfDue deletes every row of the owner's data once the scheduled time has
+// passed, and reports whether it did, along with the blob keys of the export
+// archives it deleted, for the caller to remove from the blob store (they can't be
+// decrypted any more either way: their data keys are gone).
+//
+// Everything happens in one transaction, which also replaces the erased data keys
+// with a new active one. The cipher is switched to it under its lock just before
+// the commit, so nothing is sealed with an erased key afterwards. A note sealed
+// with one before then fails the key_id foreign key when it is written, and
+// MoodNoteModel retries it with the new key (see errDataKeyErased). EraseIfDue therefore
+// needs a model of its own, not one sharing a transaction through Models.WithTx.
+func (m *DataErasureModel) EraseIfDue() (bool, []string, error) {
+	db, ok := m.DB.(*sql.DB)
+	if !ok {
+		return false, nil, errors.New("data: EraseIfDue needs its own transaction")
+	}
+
+	// Erasing a large journal can take longer than a normal query.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var (
+		erased   bool
+		blobKeys []string
+		locked   bool
+		newKeyID int64
+		newKey   []byte
+	)
+	defer func() {
+		if locked {
+			m.Cipher.mu.Unlock()
+		}
+	}()
+	err := runTx(ctx, db, func(tx *sql.Tx) error {
+		erased, blobKeys = false, nil
+
+		// Marking the erasure complete first also locks it, so a concurrent Cancel
+		// waits and then finds nothing to cancel; a rollback undoes both.
+		var id int64
+		err := tx.QueryRowContext(ctx, `
+			UPDATE data_erasures SET completed_at = NOW()
+			WHERE cancelled_at IS NULL AND completed_at IS NULL AND scheduled_for <= NOW()
+			RETURNING id`).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT blob_key FROM data_exports WHERE blob_key IS NOT NULL`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key string
+			err := rows.Scan(&key)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			blobKeys = append(blobKeys, key)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, table := range erasedTables {
+			_, err := tx.ExecContext(ctx, "DELETE FROM "+table)
+			if err != nil {
+				return fmt.Errorf("erasing %s: %w", table, err)
+			}
+		}
+
+		if m.Cipher != nil {
+			newKeyID, newKey, err = m.Cipher.insertDataKey(ctx, tx)
+			if err != nil {
+				return err
+			}
+			// Held from here until the commit's outcome is known; a retry of the
+			// whole transaction keeps holding it.
+			if !locked {
+				m.Cipher.mu.Lock()
+				locked = true
+			}
+		}
+		erased = true
+		return nil
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	if erased && m.Cipher != nil {
+		// Only the new key is left; forget the erased ones.
+		m.Cipher.keys = nil
+		m.Cipher.useDataKey(newKeyID, newKey)
+	}
+	return erased, blobKeys, nil
+}
@@ -0,0 +1,202 @@
+// internal/data/privacy_test.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEraseIfDue(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		cipher := newTestCipher(t, db)
+		models := NewModels(db, 0, cipher)
+
+		// Something in every table the erasure covers.
+		note := insertTestNote(t, models.MoodNotes, &MoodNote{Title: "Secret", Content: "Very secret", OccurredAt: time.Now().Add(-time.Hour)})
+		steps := []func() error{
+			func() error {
+				return models.Drafts.Save("owner", &Draft{Slot: DraftSlot(note.ID), Content: "draft"}, time.Hour)
+			},
+			func() error { _, err := models.NoteShares.Insert(note.ID, time.Time{}, "", testAudit); return err },
+			func() error { return models.Templates.Insert(&NoteTemplate{Name: "Mine", Content: "Body"}, testAudit) },
+			func() error {
+				return models.Attachments.Insert(&Attachment{NoteID: note.ID, Kind: AttachmentAudio, ContentType: "audio/ogg", Filename: "a.ogg", Size: 1, BlobKey: "attachments/aa/aa", KeyID: cipher.ActiveKeyID()}, testAudit)
+			},
+			func() error {
+				e, err := models.DataExports.Insert(testAudit)
+				if err != nil {
+					return err
+				}
+				return models.DataExports.Complete(e.ID, DataExportBlobKey(e.ID), cipher.ActiveKeyID(), time.Now().Add(time.Hour))
+			},
+			func() error { _, err := models.EmailSends.Claim("digest", "me@example.com", time.Now()); return err },
+			func() error { return models.Unsubscribes.Insert("digest", "me@example.com") },
+			func() error {
+				return models.APITokens.Insert(&APIToken{Name: "Script", Scopes: []string{ScopeNotesRead}}, testAudit)
+			},
+			func() error {
+				_, err := models.Tokens.New(TokenScopeEmailVerification, "me@example.com", time.Hour)
+				return err
+			},
+			func() error { return models.VerifiedEmails.Insert("me@example.com") },
+			cipher.RotateDataKey, // A second data key, inactive
+		}
+		for _, step := range steps {
+			err := step()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, table := range erasedTables {
+			if countRows(t, db, table) == 0 {
+				t.Fatalf("test setup left %s empty", table)
+			}
+		}
+		oldKeyID := cipher.ActiveKeyID()
+
+		// Not due yet.
+		_, err := models.DataErasures.Schedule(time.Now().Add(time.Hour), testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		erased, _, err := models.DataErasures.EraseIfDue()
+		if err != nil || erased {
+			t.Fatalf("EraseIfDue before the scheduled time = %v, %v", erased, err)
+		}
+
+		_, err = db.Exec("UPDATE data_erasures SET scheduled_for = $1", time.Now().Add(-time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		erased, blobKeys, err := models.DataErasures.EraseIfDue()
+		if err != nil || !erased {
+			t.Fatalf("EraseIfDue = %v, %v", erased, err)
+		}
+		if !slices.Equal(blobKeys, []string{"exports/1"}) {
+			t.Errorf("blob keys to delete = %v", blobKeys)
+		}
+
+		// No rows remain but the new data key, and the record of the erasure.
+		for _, table := range erasedTables {
+			want := 0
+			if table == "data_keys" {
+				want = 1
+			}
+			if n := countRows(t, db, table); n != want {
+				t.Errorf("%s has %d rows after erasure; want %d", table, n, want)
+			}
+		}
+		var attached int
+		db.QueryRow("SELECT COUNT(*) FROM attachments WHERE note_id IS NOT NULL").Scan(&attached)
+		if attached != 0 {
+			t.Errorf("%d attachments still attached after erasure", attached)
+		}
+		pending, err := models.DataErasures.Pending()
+		if err != nil || pending != nil {
+			t.Errorf("Pending after erasure = %+v, %v", pending, err)
+		}
+
+		// The cipher moved to the new key straight away; the erased ones are gone.
+		var activeID int64
+		err = db.QueryRow("SELECT id FROM data_keys WHERE active").Scan(&activeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cipher.ActiveKeyID(); got != activeID || got == oldKeyID {
+			t.Errorf("cipher's active key = %d; want the new key %d", got, activeID)
+		}
+		_, err = cipher.dataKey(oldKeyID)
+		if err == nil {
+			t.Error("erased data key still usable")
+		}
+		after := insertTestNote(t, models.MoodNotes, &MoodNote{Title: "Fresh start", Content: "New", OccurredAt: time.Now().Add(-time.Minute)})
+		got, err := models.MoodNotes.Get(after.ID)
+		if err != nil || got.Content != "New" {
+			t.Errorf("note written after erasure = %+v, %v", got, err)
+		}
+
+		erased, _, err = models.DataErasures.EraseIfDue()
+		if err != nil || erased {
+			t.Errorf("second EraseIfDue = %v, %v", erased, err)
+		}
+	})
+}
+
+func TestCancelledErasure(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		models := NewModels(db, 0, nil)
+		insertTestNote(t, models.MoodNotes, &MoodNote{Title: "Keep", Content: "Me", OccurredAt: time.Now().Add(-time.Hour)})
+
+		_, err := models.DataErasures.Schedule(time.Now().Add(-time.Minute), testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = models.DataErasures.Schedule(time.Now().Add(-time.Minute), testAudit)
+		if err == nil || err.Error() != "data erasure already scheduled" {
+			t.Errorf("second Schedule error = %v", err)
+		}
+		err = models.DataErasures.Cancel(testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		erased, _, err := models.DataErasures.EraseIfDue()
+		if err != nil || erased {
+			t.Fatalf("EraseIfDue after Cancel = %v, %v", erased, err)
+		}
+		if n := countRows(t, db, "mood_notes"); n != 1 {
+			t.Errorf("%d notes after a cancelled erasure; want 1", n)
+		}
+		err = models.DataErasures.Cancel(testAudit)
+		if err == nil || err.Error() != "no data erasure scheduled" {
+			t.Errorf("second Cancel error = %v", err)
+		}
+	})
+}
+
+// TestWriteWithErasedDataKey writes notes through a cipher still holding a data
+// key that an erasure deleted, as a save racing EraseIfDue would. The key_id
+// foreign key must stop them, rather than leave notes nobody can decrypt.
+func TestWriteWithErasedDataKey(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *sql.DB) {
+		models := NewModels(db, 0, newTestCipher(t, db))
+		stale := &MoodNoteModel{DB: db, Cipher: newTestCipher(t, db)}
+		_, err := stale.Cipher.dataKey(stale.Cipher.ActiveKeyID()) // Cached, as after any earlier save
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = models.DataErasures.Schedule(time.Now().Add(-time.Minute), testAudit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		erased, _, err := models.DataErasures.EraseIfDue()
+		if err != nil || !erased {
+			t.Fatalf("EraseIfDue = %v, %v", erased, err)
+		}
+
+		// The foreign key stops a note sealed with the cached key...
+		err = stale.Insert(&MoodNote{Title: "Late", Content: "Sealed too early", OccurredAt: time.Now().Add(-time.Minute)}, testAudit)
+		if !errors.Is(err, errDataKeyErased) {
+			t.Errorf("Insert with an erased data key error = %v; want %v", err, errDataKeyErased)
+		}
+		if n := countRows(t, db, "mood_notes"); n != 0 {
+			t.Errorf("%d notes written with an erased data key", n)
+		}
+
+		note := insertTestNote(t, models.MoodNotes, &MoodNote{Title: "Fresh start", Content: "New", OccurredAt: time.Now().Add(-time.Minute)})
+		edit := *note
+		edit.Content = "Edited"
+		stale.Cipher.keys = nil // ...and sealing fails if the key is loaded after the erasure.
+		err = stale.Update(&edit, testAudit)
+		if !errors.Is(err, errDataKeyErased) {
+			t.Errorf("Update with an erased data key error = %v; want %v", err, errDataKeyErased)
+		}
+		got, err := models.MoodNotes.Get(note.ID)
+		if err != nil || got.Content != "New" || got.Version != note.Version {
+			t.Errorf("note after the failed update = %+v, %v", got, err)
+		}
+	})
+}
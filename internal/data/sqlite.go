@@ -0,0 +1,215 @@
+// internal/data/sqlite.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLiteDriver is the database/sql driver name for SQLite storage. The models
+// are written in the SQL that PostgreSQL and SQLite share (RETURNING, ON CONFLICT,
+// FILTER, row values), and the driver fills the few gaps without touching the
+// query text:
+//
+//   - $1, $2... are named parameters to SQLite, so arguments are bound by name
+//     rather than by position (see sqliteArgs)
+//   - NOW() is registered as an SQL function returning the current UTC time in
+//     the stored timestamp format
+//   - time.Time arguments are stored as UTC, so timestamps compare correctly as text
+//
+// Transactions take SQLite's single write lock as soon as they begin
+// (_txlock=immediate), which is why no query needs FOR UPDATE. Columns without a
+// declared type (RETURNING clauses) come back as text; the models scan those
+// with scanTime.
+//
+// The driver needs cgo (it wraps go-sqlite3, see sqlite_cgo.go). In builds
+// without cgo it is still registered, but opening it returns an error.
+//
+// The DSN is a file path (or a file: URI); sensible defaults for foreign keys,
+// busy timeout, WAL and transaction locking are added unless given explicitly.
+const SQLiteDriver = "moodnotes-sqlite"
+
+// sqliteTimeLayout is how go-sqlite3 writes time.Time values; NOW() matches it.
+const sqliteTimeLayout = "2006-01-02 15:04:05.999999999-07:00"
+
+// sqliteDSNDefaults are added to every SQLite DSN that doesn't set them.
+var sqliteDSNDefaults = map[string]string{
+	"_foreign_keys":  "on",
+	"_busy_timeout":  "5000",
+	"_journal_mode":  "WAL",
+	"_txlock":        "immediate",
+	"_synchronous":   "NORMAL",
+	"_cache_size":    "-8000", // 8 MB
+	"_secure_delete": "on",    // Deleted notes are overwritten, not left in free pages
+}
+
+// sqliteDSN adds the default connection parameters that dsn doesn't set.
+func sqliteDSN(dsn string) string {
+	file, query, _ := strings.Cut(dsn, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return dsn // Let go-sqlite3 report it
+	}
+	for k, v := range sqliteDSNDefaults {
+		if !params.Has(k) {
+			params.Set(k, v)
+		}
+	}
+	if !strings.HasPrefix(file, "file:") {
+		file = "file:" + file
+	}
+	return file + "?" + params.Encode()
+}
+
+// sqliteArgs binds arguments by name. SQLite numbers named parameters in the
+// order they first appear, so "$3 ... $2" would swap two positional arguments;
+// naming each argument after its position ("1" matches $1) avoids that. Times
+// are converted to UTC.
+func sqliteArgs(args []driver.NamedValue) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		if a.Name == "" {
+			a.Name = strconv.Itoa(a.Ordinal)
+		}
+		if t, ok := a.Value.(time.Time); ok {
+			a.Value = t.UTC()
+		}
+		named[i] = a
+	}
+	return named
+}
+
+// sqliteNow implements NOW() for SQLite.
+func sqliteNow() string {
+	return time.Now().UTC().Format(sqliteTimeLayout)
+}
+
+// --- Timestamps ---
+
+// scanTime returns a Scanner that stores a timestamp in t. PostgreSQL always
+// returns timestamps as time.Time, but SQLite has no timestamp type: go-sqlite3
+// converts columns declared TIMESTAMP, while RETURNING clauses (which have no
+// declared type) return the stored text. Scan every RETURNING timestamp with it.
+func scanTime(t *time.Time) sql.Scanner {
+	return (*timeScanner)(t)
+}
+
+type timeScanner time.Time
+
+// Scan implements sql.Scanner.
+func (s *timeScanner) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case time.Time:
+		*s = timeScanner(v)
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("data: cannot scan %T into a timestamp", src)
+	}
+	// The stored format, or SQLite's CURRENT_TIMESTAMP.
+	for _, layout := range []string{sqliteTimeLayout, time.DateTime} {
+		t, err := time.Parse(layout, text)
+		if err == nil {
+			*s = timeScanner(t)
+			return nil
+		}
+	}
+	return fmt.Errorf("data: cannot parse timestamp %q", text)
+}
+
+// --- Migrations ---
+
+// MigrateSQLite applies the SQLite migrations in fsys (NNNNNN_name.up.sql files)
+// that are newer than the database's schema version, each in its own transaction,
+// and returns how many it applied. The version is kept in schema_migrations in
+// the same shape the migrate tool uses for PostgreSQL.
+func MigrateSQLite(db *sql.DB, fsys fs.FS) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)`)
+	if err != nil {
+		return 0, err
+	}
+	var current int64
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return 0, err
+	}
+
+	names, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+	slices.Sort(names) // Zero-padded versions sort correctly as text
+
+	applied := 0
+	for _, name := range names {
+		num, _, _ := strings.Cut(path.Base(name), "_")
+		version, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return applied, fmt.Errorf("unexpected migration file %s", name)
+		}
+		if version <= current {
+			continue
+		}
+		err = applySQLiteMigration(ctx, db, fsys, name, version)
+		if err != nil {
+			return applied, fmt.Errorf("migration %s: %w", name, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// applySQLiteMigration runs one migration file and records its version. SQLite
+// DDL is transactional, so a failed migration leaves no trace.
+func applySQLiteMigration(ctx context.Context, db *sql.DB, fsys fs.FS, name string, version int64) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	script, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op after Commit
+
+	_, err = tx.ExecContext(ctx, string(script))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)`, version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
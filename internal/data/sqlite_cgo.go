@@ -0,0 +1,104 @@
+// internal/data/sqlite_cgo.go
+
+//go:build cgo
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	sql.Register(SQLiteDriver, &sqliteDriver{})
+}
+
+// sqliteDriver opens go-sqlite3 connections and wraps them in sqliteConn.
+type sqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+// Open implements driver.Driver.
+func (d *sqliteDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(sqliteDSN(dsn))
+	if err != nil {
+		return nil, err
+	}
+	c := conn.(*sqlite3.SQLiteConn)
+	err = c.RegisterFunc("now", sqliteNow, false)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &sqliteConn{c}, nil
+}
+
+// sqliteConn passes queries through unchanged and binds their arguments with
+// sqliteArgs.
+type sqliteConn struct {
+	*sqlite3.SQLiteConn
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStmt{stmt.(*sqlite3.SQLiteStmt)}, nil
+}
+
+// Prepare implements driver.Conn.
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.SQLiteConn.ExecContext(ctx, query, sqliteArgs(args))
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.SQLiteConn.QueryContext(ctx, query, sqliteArgs(args))
+}
+
+// sqliteStmt binds a prepared statement's arguments with sqliteArgs.
+type sqliteStmt struct {
+	*sqlite3.SQLiteStmt
+}
+
+// ExecContext implements driver.StmtExecContext.
+func (s *sqliteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.SQLiteStmt.ExecContext(ctx, sqliteArgs(args))
+}
+
+// QueryContext implements driver.StmtQueryContext.
+func (s *sqliteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.SQLiteStmt.QueryContext(ctx, sqliteArgs(args))
+}
+
+// --- Errors ---
+
+// sqliteUniqueViolation reports whether err is a SQLite unique constraint violation.
+func sqliteUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// sqliteForeignKeyViolation reports whether err is a SQLite foreign key violation.
+func sqliteForeignKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+}
+
+// sqliteRetryable reports whether err means SQLite was busy or locked by another
+// connection, so the transaction may succeed if run again.
+func sqliteRetryable(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
@@ -0,0 +1,82 @@
+// internal/data/sqlite_cgo_test.go
+
+//go:build cgo
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/mickali02/mood-notes-app/migrations"
+)
+
+func TestSQLiteRetryableErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{sqlite3.Error{Code: sqlite3.ErrLocked}, true},
+		{fmt.Errorf("committing: %w", sqlite3.Error{Code: sqlite3.ErrBusy}), true},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, false},
+	}
+	for _, tt := range tests {
+		if got := retryableTxError(tt.err); got != tt.want {
+			t.Errorf("retryableTxError(%v) = %v; want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestWithTxRetriesWhenBusy holds SQLite's write lock on one connection while
+// another, which won't wait for it, begins a transaction: SQLITE_BUSY, then a retry.
+func TestWithTxRetriesWhenBusy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "moodnotes.db")
+	holder, err := sql.Open(SQLiteDriver, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close()
+	_, err = MigrateSQLite(holder, migrations.SQLite())
+	if err != nil {
+		t.Fatal(err)
+	}
+	impatient, err := sql.Open(SQLiteDriver, file+"?_busy_timeout=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer impatient.Close()
+
+	lock, err := holder.BeginTx(context.Background(), nil) // BEGIN IMMEDIATE takes the write lock
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(5 * time.Millisecond) // Well before the first retry
+		lock.Rollback()
+	}()
+
+	models := NewModels(impatient, 0, nil)
+	err = models.WithTx(context.Background(), func(tx Models) error {
+		return tx.Templates.Insert(&NoteTemplate{Name: "After the lock", Content: "Body"}, testAudit)
+	})
+	if err != nil {
+		t.Fatalf("WithTx while another connection held the lock: %v", err)
+	}
+
+	// Without a retry in time, the busy error comes back as it is.
+	lock, err = holder.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Rollback()
+	err = models.WithTx(context.Background(), func(tx Models) error { return nil })
+	if !retryableTxError(err) {
+		t.Errorf("WithTx with the lock held throughout = %v; want SQLITE_BUSY", err)
+	}
+}
@@ -0,0 +1,31 @@
+// internal/data/sqlite_nocgo.go
+
+//go:build !cgo
+
+package data
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// Without cgo there is no go-sqlite3, so the SQLite driver is a placeholder that
+// explains why it can't open anything. PostgreSQL-only builds (CGO_ENABLED=0,
+// e.g. static release binaries) are otherwise unaffected.
+func init() {
+	sql.Register(SQLiteDriver, sqliteUnavailable{})
+}
+
+type sqliteUnavailable struct{}
+
+// Open implements driver.Driver.
+func (sqliteUnavailable) Open(string) (driver.Conn, error) {
+	return nil, errors.New("SQLite storage is not available: this binary was built without cgo (rebuild with CGO_ENABLED=1)")
+}
+
+// The error checks below can never match: no SQLite errors exist in this build.
+
+func sqliteUniqueViolation(error) bool     { return false }
+func sqliteForeignKeyViolation(error) bool { return false }
+func sqliteRetryable(error) bool           { return false }
@@ -0,0 +1,33 @@
+// internal/data/testdb_sqlite_test.go
+
+//go:build cgo
+
+package data
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/mickali02/mood-notes-app/migrations"
+)
+
+func init() {
+	testBackends = append(testBackends, testBackend{name: "sqlite", open: openTestSQLite})
+}
+
+// openTestSQLite migrates a new database file in the test's temporary directory.
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(SQLiteDriver, filepath.Join(t.TempDir(), "moodnotes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = MigrateSQLite(db, migrations.SQLite())
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db
+}
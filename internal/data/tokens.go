@@ -0,0 +1,138 @@
+// internal/data/tokens.go
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+
+	"github.com/mickali02/mood-notes-app/internal/validator"
+)
+
+// TokenScopeEmailVerification tokens confirm that whoever reads a reminder or
+// digest recipient's mail asked for the emails. Password-reset and activation
+// tokens belong in the same table once the app has user accounts.
+const TokenScopeEmailVerification = "email-verification"
+
+// tokenPlaintextLength is the length of a token: 16 random bytes, base32 without padding.
+const tokenPlaintextLength = 26
+
+// Token is a single-use secret mailed to Email. Only a hash is stored, so
+// Plaintext is set on the value returned by New and nowhere else.
+type Token struct {
+	Plaintext string
+	Scope     string
+	Email     string
+	Expiry    time.Time
+}
+
+// ValidateTokenPlaintext checks the shape of a token taken from a link or form.
+func ValidateTokenPlaintext(v *validator.Validator, plaintext string) {
+	v.Check(validator.NotBlank(plaintext), "token", "must be provided")
+	v.Check(len(plaintext) == tokenPlaintextLength, "token", "must be 26 characters long")
+}
+
+// TokenModel stores single-use email tokens.
+type TokenModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// hashToken returns the value stored in tokens.hash.
+func hashToken(plaintext string) []byte {
+	sum := sha256.Sum256([]byte(plaintext))
+	return sum[:]
+}
+
+// New creates a token for email that is valid for ttl, clearing out expired
+// tokens while it's at it.
+func (m *TokenModel) New(scope, email string, ttl time.Duration) (*Token, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	token := &Token{
+		Plaintext: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b),
+		Scope:     scope,
+		Email:     email,
+		Expiry:    time.Now().Add(ttl),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	err = inTx(ctx, m.DB, func(tx DBTX) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM tokens WHERE expires_at <= NOW()`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO tokens (hash, scope, email, expires_at)
+			VALUES ($1, $2, $3, $4)`,
+			hashToken(token.Plaintext), scope, email, token.Expiry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Consume deletes an unexpired scope token and returns the address it was sent
+// to, so each token works once. It returns "token not found" otherwise.
+func (m *TokenModel) Consume(scope, plaintext string) (string, error) {
+	query := `
+		DELETE FROM tokens
+		WHERE hash = $1 AND scope = $2 AND expires_at > NOW()
+		RETURNING email`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var email string
+	err := m.DB.QueryRowContext(ctx, query, hashToken(plaintext), scope).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errors.New("token not found")
+		}
+		return "", err
+	}
+	return email, nil
+}
+
+// VerifiedEmailModel stores the addresses that followed a verification link.
+type VerifiedEmailModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// Insert records that email is verified. Repeating it is harmless.
+func (m *VerifiedEmailModel) Insert(email string) error {
+	query := `
+		INSERT INTO verified_emails (email)
+		VALUES ($1)
+		ON CONFLICT (email) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, email)
+	return err
+}
+
+// Exists reports whether email has been verified.
+func (m *VerifiedEmailModel) Exists(email string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM verified_emails WHERE email = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,43 @@
+// internal/data/unsubscribes.go
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// UnsubscribeModel stores opt-outs from optional emails such as the weekly digest.
+type UnsubscribeModel struct {
+	DB           DBTX
+	QueryTimeout time.Duration
+}
+
+// Insert records that recipient no longer wants kind emails. Repeating it is harmless.
+func (m *UnsubscribeModel) Insert(kind, recipient string) error {
+	query := `
+		INSERT INTO email_unsubscribes (kind, recipient)
+		VALUES ($1, $2)
+		ON CONFLICT (kind, recipient) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, kind, recipient)
+	return err
+}
+
+// Exists reports whether recipient has unsubscribed from kind emails.
+func (m *UnsubscribeModel) Exists(kind, recipient string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM email_unsubscribes
+			WHERE kind = $1 AND recipient = $2
+		)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout(m.QueryTimeout))
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, kind, recipient).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,108 @@
+// internal/mailer/mailer.go
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"text/template"
+	"time"
+)
+
+// Message is a rendered email with both plain-text and HTML parts.
+type Message struct {
+	From      string
+	To        string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+	Date      time.Time
+	Headers   map[string]string // Extra headers, e.g. List-Unsubscribe
+}
+
+// Sender delivers a rendered message. Implementations: SMTPSender for real
+// delivery, LogSender and FileSender for development.
+type Sender interface {
+	Send(msg *Message) error
+}
+
+// Mailer renders emails from templates and hands them to a Sender.
+//
+// Each email template file must define three templates:
+//
+//	{{define "subject"}}...{{end}}    plain text
+//	{{define "plainBody"}}...{{end}}  plain text
+//	{{define "htmlBody"}}...{{end}}   HTML, auto-escaped
+type Mailer struct {
+	sender    Sender
+	from      string
+	templates fs.FS
+	dir       string // Directory inside templates holding the *.tmpl files
+}
+
+// New returns a Mailer that reads templates from dir inside templates (normally ui.Files, "email").
+func New(sender Sender, from string, templates fs.FS, dir string) *Mailer {
+	return &Mailer{
+		sender:    sender,
+		from:      from,
+		templates: templates,
+		dir:       dir,
+	}
+}
+
+// Send renders templateFile with data and sends it to recipient.
+func (m *Mailer) Send(recipient, templateFile string, data any) error {
+	msg, err := m.Render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+	return m.sender.Send(msg)
+}
+
+// SendMessage sends a message returned by Render, after the caller has adjusted it
+// (for example to add headers).
+func (m *Mailer) SendMessage(msg *Message) error {
+	return m.sender.Send(msg)
+}
+
+// Render builds the message without sending it.
+func (m *Mailer) Render(recipient, templateFile string, data any) (*Message, error) {
+	path := m.dir + "/" + templateFile
+
+	// Subject and plain body use text/template so nothing gets HTML-escaped.
+	textTmpl, err := template.New("email").ParseFS(m.templates, path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing email template %s: %w", templateFile, err)
+	}
+	subject := new(bytes.Buffer)
+	err = textTmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, fmt.Errorf("rendering subject of %s: %w", templateFile, err)
+	}
+	plainBody := new(bytes.Buffer)
+	err = textTmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, fmt.Errorf("rendering plain body of %s: %w", templateFile, err)
+	}
+
+	// The HTML part goes through html/template for contextual escaping.
+	htmlTmpl, err := htmltemplate.New("email").ParseFS(m.templates, path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing email template %s: %w", templateFile, err)
+	}
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, fmt.Errorf("rendering HTML body of %s: %w", templateFile, err)
+	}
+
+	return &Message{
+		From:      m.from,
+		To:        recipient,
+		Subject:   subject.String(),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+		Date:      time.Now(),
+	}, nil
+}
@@ -0,0 +1,118 @@
+// internal/mailer/mime.go
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bytes encodes the message as an RFC 5322 email with a multipart/alternative
+// body (plain text first, HTML second, so clients prefer the HTML part).
+func (msg *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	date := msg.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	messageID, err := newMessageID(msg.From)
+	if err != nil {
+		return nil, err
+	}
+
+	// --- Headers ---
+	headers := []struct{ key, value string }{
+		{"From", msg.From},
+		{"To", msg.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(msg.Subject))},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	}
+	// Extra headers go in a stable order so messages are reproducible.
+	keys := make([]string, 0, len(msg.Headers))
+	for k := range msg.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		headers = append(headers, struct{ key, value string }{textproto.CanonicalMIMEHeaderKey(k), msg.Headers[k]})
+	}
+	var head bytes.Buffer
+	for _, h := range headers {
+		// Guard against header injection from addresses or subjects.
+		if strings.ContainsAny(h.value, "\r\n") {
+			return nil, fmt.Errorf("invalid newline in %s header", h.key)
+		}
+		fmt.Fprintf(&head, "%s: %s\r\n", h.key, h.value)
+	}
+	head.WriteString("\r\n")
+
+	// --- Body Parts ---
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.PlainBody},
+		{"text/html; charset=utf-8", msg.HTMLBody},
+	}
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		_, err = qp.Write([]byte(p.body))
+		if err != nil {
+			return nil, err
+		}
+		err = qp.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+// newMessageID returns a unique Message-ID using the sender's domain.
+func newMessageID(from string) (string, error) {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain), nil
+}
+
+// addressOnly extracts the bare email address ("a@b.c") from a header value
+// such as "Feel Flow <a@b.c>", for use in the SMTP envelope.
+func addressOnly(header string) (string, error) {
+	addr, err := mail.ParseAddress(header)
+	if err != nil {
+		return "", fmt.Errorf("invalid email address %q: %w", header, err)
+	}
+	return addr.Address, nil
+}
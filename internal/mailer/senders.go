@@ -0,0 +1,85 @@
+// internal/mailer/senders.go
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// --- SMTP ---
+
+// SMTPSender delivers mail through an SMTP server. net/smtp upgrades the
+// connection with STARTTLS whenever the server offers it.
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string // Leave empty for servers that don't require authentication
+	Password string
+}
+
+// Send implements Sender.
+func (s *SMTPSender) Send(msg *Message) error {
+	from, err := addressOnly(msg.From)
+	if err != nil {
+		return err
+	}
+	to, err := addressOnly(msg.To)
+	if err != nil {
+		return err
+	}
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	err = smtp.SendMail(addr, auth, from, []string{to}, body)
+	if err != nil {
+		return fmt.Errorf("sending mail via %s: %w", addr, err)
+	}
+	return nil
+}
+
+// --- Development Senders ---
+
+// LogSender writes the plain-text version of each message to the logger
+// instead of sending it. Useful when running locally.
+type LogSender struct {
+	Logger *slog.Logger
+}
+
+// Send implements Sender.
+func (s *LogSender) Send(msg *Message) error {
+	s.Logger.Info("email (not sent, log mailer)", "to", msg.To, "subject", msg.Subject, "body", msg.PlainBody)
+	return nil
+}
+
+// FileSender saves each message as an .eml file in Dir, which most mail
+// clients can open to check the HTML rendering.
+type FileSender struct {
+	Dir string
+}
+
+// Send implements Sender.
+func (s *FileSender) Send(msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(s.Dir, 0o750)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().UTC().Format("20060102T150405"), time.Now().UnixNano()%1e6)
+	return os.WriteFile(filepath.Join(s.Dir, name), body, 0o640)
+}
@@ -0,0 +1,143 @@
+// internal/markdown/markdown.go
+package markdown
+
+import (
+	"bytes"
+	"container/list"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Renderer converts note content written in Markdown into sanitised HTML.
+// Raw HTML in the source is never passed through: goldmark drops it, and the
+// output is then filtered through an allow-list policy as a second line of defence.
+type Renderer struct {
+	md         goldmark.Markdown
+	policy     *bluemonday.Policy
+	textPolicy *bluemonday.Policy // Strips every tag, for plain-text excerpts
+
+	// Rendered notes are cached by (id, version): a note's content can only change
+	// together with its version, so entries never need invalidating.
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[cacheKey]*list.Element
+	order      *list.List // Front = most recently used
+}
+
+type cacheKey struct {
+	id      int64
+	version int
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	html template.HTML
+}
+
+// New returns a Renderer that caches up to maxEntries rendered notes.
+func New(maxEntries int) *Renderer {
+	return &Renderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.Strikethrough, extension.Linkify),
+		),
+		policy:     newPolicy(),
+		textPolicy: bluemonday.StrictPolicy(),
+		maxEntries: maxEntries,
+		entries:    make(map[cacheKey]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// languageClassRX limits code block classes to the "language-xyz" form goldmark emits.
+var languageClassRX = regexp.MustCompile(`^language-[\w+-]+$`)
+
+// newPolicy builds the allow-list of elements and attributes notes may contain:
+// headings, paragraphs, lists, emphasis, links, quotes and code.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements("h1", "h2", "h3", "h4", "h5", "h6", "p", "br", "hr",
+		"ul", "ol", "li", "em", "strong", "del", "blockquote", "pre", "code")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("class").Matching(languageClassRX).OnElements("code")
+
+	// Links: only web and mail URLs, and never let them pass our referrer or window.
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return p
+}
+
+// Render converts source to sanitised HTML without touching the cache.
+// Used for previews of content that hasn't been saved yet.
+func (r *Renderer) Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	err := r.md.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", err
+	}
+	// The policy output is safe to mark as trusted HTML.
+	return template.HTML(r.policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// RenderNote returns the HTML for a saved note, rendering it only on a cache miss.
+func (r *Renderer) RenderNote(id int64, version int, source string) (template.HTML, error) {
+	key := cacheKey{id: id, version: version}
+
+	r.mu.Lock()
+	if el, ok := r.entries[key]; ok {
+		r.order.MoveToFront(el)
+		rendered := el.Value.(*cacheEntry).html
+		r.mu.Unlock()
+		return rendered, nil
+	}
+	r.mu.Unlock()
+
+	// Render outside the lock; two concurrent misses for the same key just do the work twice.
+	rendered, err := r.Render(source)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[key]; !ok {
+		r.entries[key] = r.order.PushFront(&cacheEntry{key: key, html: rendered})
+		for r.maxEntries > 0 && r.order.Len() > r.maxEntries {
+			oldest := r.order.Back()
+			r.order.Remove(oldest)
+			delete(r.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+	return rendered, nil
+}
+
+// blockBreaks adds a space after block-level tags so "<p>a</p><p>b</p>" becomes "a b", not "ab".
+var blockBreaks = strings.NewReplacer(
+	"</p>", "</p> ", "</li>", "</li> ", "</blockquote>", "</blockquote> ", "</pre>", "</pre> ",
+	"</h1>", "</h1> ", "</h2>", "</h2> ", "</h3>", "</h3> ", "</h4>", "</h4> ", "</h5>", "</h5> ", "</h6>", "</h6> ",
+	"<br>", "<br> ", "<br/>", "<br/> ", "<hr>", "<hr> ", "<hr/>", "<hr/> ",
+)
+
+// NoteText returns the plain text of a saved note, with all Markdown syntax removed
+// and whitespace collapsed. Cutting this text can never leave half a Markdown
+// construct behind, which is why excerpts are built from it.
+func (r *Renderer) NoteText(id int64, version int, source string) (string, error) {
+	rendered, err := r.RenderNote(id, version, source)
+	if err != nil {
+		return "", err
+	}
+	text := html.UnescapeString(r.textPolicy.Sanitize(blockBreaks.Replace(string(rendered))))
+	return strings.Join(strings.Fields(text), " "), nil
+}
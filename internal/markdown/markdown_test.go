@@ -0,0 +1,120 @@
+// internal/markdown/markdown_test.go
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	const external = `rel="nofollow noreferrer noopener" target="_blank"`
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"emphasis", "*soft* and **bold** and ~~gone~~", "<p><em>soft</em> and <strong>bold</strong> and <del>gone</del></p>\n"},
+		{"script tag", "<script>alert(1)</script>", "\n"},
+		{"raw html", `hi <b onmouseover="steal()">bold</b>`, "<p>hi bold</p>\n"},
+		{"raw image with onerror", `<img src=x onerror=alert(1)>`, "\n"},
+		{"raw link with onclick", `<a href="https://example.com" onclick="steal()">y</a>`, "<p>y</p>\n"},
+		{"javascript link", "[click](javascript:alert(1))", "<p>click</p>\n"},
+		{"javascript link, mixed case", "[click](JaVaScRiPt:alert(1))", "<p>click</p>\n"},
+		{"data link", "[click](data:text/html;base64,PHNjcmlwdD4=)", "<p>click</p>\n"},
+		{"web link", "[site](https://example.com)", `<p><a href="https://example.com" ` + external + ">site</a></p>\n"},
+		{"bare url", "https://example.com", `<p><a href="https://example.com" ` + external + ">https://example.com</a></p>\n"},
+		{"mail link", "[me](mailto:me@example.com)", `<p><a href="mailto:me@example.com" rel="nofollow noreferrer">me</a></p>` + "\n"},
+		{"code block", "```go\nx := 1\n```", `<pre><code class="language-go">x := 1` + "\n</code></pre>\n"},
+		{"ordered list start", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+	}
+	r := New(10)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Render(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Render(%q) =\n%q\nwant\n%q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPolicy checks the allow-list on its own, as it would act if goldmark ever
+// let raw HTML through.
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"script", `<p>a</p><script>alert(1)</script>`, "<p>a</p>"},
+		{"style", `<style>body{display:none}</style><p>a</p>`, "<p>a</p>"},
+		{"iframe", `<iframe src="https://evil.example"></iframe>`, ""},
+		{"event handlers", `<p onclick="x()" onmouseover="y()">a</p>`, "<p>a</p>"},
+		{"style attribute", `<p style="position:fixed">a</p>`, "<p>a</p>"},
+		{"javascript href", `<a href="javascript:alert(1)">a</a>`, "a"},
+		{"data href", `<a href="data:text/html,<script>alert(1)</script>">a</a>`, "a"},
+		{"vbscript href", `<a href="vbscript:msgbox(1)">a</a>`, "a"},
+		{"target and rel replaced", `<a href="https://example.com" target="_self" rel="opener">a</a>`, `<a href="https://example.com" rel="nofollow noreferrer noopener" target="_blank">a</a>`},
+		{"relative link", `<a href="/note/1">a</a>`, "a"}, // Only absolute web and mail URLs
+		{"code class", `<code class="language-js evil">x</code><code class="language-js">y</code>`, `<code>x</code><code class="language-js">y</code>`},
+		{"image", `<img src="https://example.com/t.gif">`, ""},
+	}
+	p := newPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Sanitize(tt.html); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q; want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderNoteCache(t *testing.T) {
+	r := New(2)
+	render := func(id int64, version int, source string) string {
+		t.Helper()
+		got, err := r.RenderNote(id, version, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(got)
+	}
+
+	first := render(1, 1, "*one*")
+	// The same (id, version) is a hit, whatever source is passed.
+	if got := render(1, 1, "*changed*"); got != first {
+		t.Errorf("same version rendered %q; want cached %q", got, first)
+	}
+	// A new version is a miss.
+	if got := render(1, 2, "*changed*"); !strings.Contains(got, "changed") {
+		t.Errorf("new version rendered %q; want the new source", got)
+	}
+
+	// Two entries fit: using 1@1 keeps it, so adding 2@1 evicts 1@2.
+	render(1, 1, "")
+	render(2, 1, "*two*")
+	if _, ok := r.entries[cacheKey{1, 2}]; ok {
+		t.Error("least recently used entry kept")
+	}
+	if _, ok := r.entries[cacheKey{1, 1}]; !ok {
+		t.Error("recently used entry evicted")
+	}
+	if n := r.order.Len(); n != 2 || len(r.entries) != 2 {
+		t.Errorf("cache holds %d entries (%d in map); want 2", n, len(r.entries))
+	}
+}
+
+func TestNoteText(t *testing.T) {
+	r := New(10)
+	got, err := r.NoteText(1, 1, "# Title\n\nA [link](https://example.com) and *emphasis*.\n\n- one\n- two\n\n<script>x</script>\n\nFish &amp; chips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Title A link and emphasis. one two Fish & chips"
+	if got != want {
+		t.Errorf("NoteText = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,259 @@
+// internal/vault/stream.go
+package vault
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Streams seal blobs too large to hold in memory, such as export archives and
+// attachments, and can be read back from any offset (http.ServeContent seeks to
+// answer Range requests).
+//
+// A sealed stream is a random nonce prefix followed by the plaintext in chunks of
+// StreamChunkSize bytes, each sealed with AES-GCM. A chunk's nonce is the prefix,
+// the chunk's index and a flag marking the last chunk, so chunks can't be
+// reordered, and a stream cut short is detected (the STREAM construction).
+const (
+	StreamChunkSize = 64 * 1024
+
+	streamPrefixSize = 7  // Random; the other 5 nonce bytes are the index and the flag
+	streamOverhead   = 16 // GCM tag per chunk
+	sealedChunkSize  = StreamChunkSize + streamOverhead
+)
+
+// streamNonce returns the nonce for chunk index of a stream.
+func streamNonce(nonce, prefix []byte, index uint32, last bool) []byte {
+	nonce = append(nonce[:0], prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, index)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// --- Writing ---
+
+// streamWriter seals what is written to it chunk by chunk.
+type streamWriter struct {
+	w      io.Writer
+	gcm    cipher.AEAD
+	aad    []byte
+	prefix []byte
+	nonce  []byte
+	buf    []byte // Plaintext of the chunk being filled
+	out    []byte // Sealed chunk
+	index  uint32
+	err    error
+}
+
+// NewStreamWriter returns a writer that seals everything written to it onto w.
+// aad is authenticated with every chunk, like Seal's. Nothing is written to w
+// until the first chunk is full or the writer is closed, which seals the last
+// chunk; the stream is incomplete (and fails to open) until then.
+func NewStreamWriter(w io.Writer, key, aad []byte) (io.WriteCloser, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, streamPrefixSize)
+	_, err = rand.Read(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("vault: generating nonce: %w", err)
+	}
+	return &streamWriter{
+		w:      w,
+		gcm:    gcm,
+		aad:    aad,
+		prefix: prefix,
+		nonce:  make([]byte, 0, gcm.NonceSize()),
+		buf:    make([]byte, 0, StreamChunkSize),
+		out:    make([]byte, 0, streamPrefixSize+sealedChunkSize),
+	}, nil
+}
+
+// Write implements io.Writer. A full chunk is only sealed once more data
+// arrives, since until then it might be the last one.
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(s.buf) == StreamChunkSize {
+			s.err = s.flush(false)
+			if s.err != nil {
+				return written, s.err
+			}
+		}
+		n := copy(s.buf[len(s.buf):StreamChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last chunk, which may be empty. It does not close the underlying writer.
+func (s *streamWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	s.err = s.flush(true)
+	if s.err == nil {
+		s.err = errors.New("vault: write to closed stream")
+		return nil
+	}
+	return s.err
+}
+
+// flush seals the buffered chunk and writes it out.
+func (s *streamWriter) flush(last bool) error {
+	if s.index == 1<<32-1 && !last {
+		return errors.New("vault: stream too long")
+	}
+	s.out = s.out[:0]
+	if s.index == 0 {
+		s.out = append(s.out, s.prefix...)
+	}
+	s.nonce = streamNonce(s.nonce, s.prefix, s.index, last)
+	s.out = s.gcm.Seal(s.out, s.nonce, s.buf, s.aad)
+	_, err := s.w.Write(s.out)
+	if err != nil {
+		return err
+	}
+	s.buf = s.buf[:0]
+	s.index++
+	return nil
+}
+
+// --- Reading ---
+
+// StreamReader reads the plaintext of a sealed stream. It seeks in the
+// underlying reader, so only the chunks that are read get decrypted.
+type StreamReader struct {
+	r      io.ReadSeeker
+	gcm    cipher.AEAD
+	aad    []byte
+	prefix []byte
+	nonce  []byte
+
+	sealedSize int64 // Of the stream, including the prefix
+	chunks     int64
+	size       int64 // Of the plaintext
+	pos        int64
+
+	chunk       []byte // Plaintext of chunk number cached
+	sealedChunk []byte
+	cached      int64
+}
+
+// NewStreamReader opens a stream written by NewStreamWriter. sealedSize is the
+// size of the whole sealed stream, e.g. the blob's size. Tampering shows up as
+// ErrDecrypt from Read.
+func NewStreamReader(r io.ReadSeeker, sealedSize int64, key, aad []byte) (*StreamReader, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	body := sealedSize - streamPrefixSize
+	if body < streamOverhead {
+		return nil, ErrDecrypt
+	}
+	chunks := (body + sealedChunkSize - 1) / sealedChunkSize
+	if body-(chunks-1)*sealedChunkSize < streamOverhead {
+		return nil, ErrDecrypt // The last chunk is too short to hold a tag
+	}
+
+	prefix := make([]byte, streamPrefixSize)
+	_, err = r.Seek(0, io.SeekStart)
+	if err == nil {
+		_, err = io.ReadFull(r, prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &StreamReader{
+		r:          r,
+		gcm:        gcm,
+		aad:        aad,
+		prefix:     prefix,
+		nonce:      make([]byte, 0, gcm.NonceSize()),
+		sealedSize: sealedSize,
+		chunks:     chunks,
+		size:       body - chunks*streamOverhead,
+		cached:     -1,
+	}, nil
+}
+
+// Size returns the length of the plaintext.
+func (s *StreamReader) Size() int64 {
+	return s.size
+}
+
+// Read implements io.Reader.
+func (s *StreamReader) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	index := s.pos / StreamChunkSize
+	err := s.load(index)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, s.chunk[s.pos-index*StreamChunkSize:])
+	s.pos += int64(n)
+	return n, nil
+}
+
+// Seek implements io.Seeker, in plaintext offsets.
+func (s *StreamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("vault: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("vault: negative position")
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+// load decrypts chunk index into s.chunk, unless it is there already.
+func (s *StreamReader) load(index int64) error {
+	if s.cached == index {
+		return nil
+	}
+	start := streamPrefixSize + index*sealedChunkSize
+	size := min(sealedChunkSize, s.sealedSize-start)
+	if cap(s.sealedChunk) < int(size) {
+		s.sealedChunk = make([]byte, sealedChunkSize)
+	}
+	sealed := s.sealedChunk[:size]
+
+	_, err := s.r.Seek(start, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = io.ReadFull(s.r, sealed)
+	if err != nil {
+		return err
+	}
+	s.nonce = streamNonce(s.nonce, s.prefix, uint32(index), index == s.chunks-1)
+	s.chunk, err = s.gcm.Open(s.chunk[:0], s.nonce, sealed, s.aad)
+	if err != nil {
+		s.cached = -1
+		return ErrDecrypt
+	}
+	s.cached = index
+	return nil
+}
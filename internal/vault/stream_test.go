@@ -0,0 +1,128 @@
+// internal/vault/stream_test.go
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// sealStream seals plaintext in one go.
+func sealStream(t *testing.T, key, plaintext, aad []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewStreamWriter(&buf, key, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Uneven writes, so chunks fill across Write calls.
+	for len(plaintext) > 0 {
+		n := min(len(plaintext), 10_000)
+		_, err = w.Write(plaintext[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext = plaintext[n:]
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key, _ := NewDataKey()
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 123} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+		sealed := sealStream(t, key, plaintext, []byte("blob:test"))
+
+		r, err := NewStreamReader(bytes.NewReader(sealed), int64(len(sealed)), key, []byte("blob:test"))
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if r.Size() != int64(size) {
+			t.Errorf("size %d: Size() = %d", size, r.Size())
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: read back %d bytes, %v", size, len(got), err)
+		}
+	}
+}
+
+func TestStreamSeek(t *testing.T) {
+	key, _ := NewDataKey()
+	plaintext := make([]byte, 2*StreamChunkSize+500)
+	rand.Read(plaintext)
+	sealed := sealStream(t, key, plaintext, nil)
+
+	r, err := NewStreamReader(bytes.NewReader(sealed), int64(len(sealed)), key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		offset int64
+		whence int
+		want   int64
+	}{
+		{StreamChunkSize - 10, io.SeekStart, StreamChunkSize - 10}, // Across a chunk boundary
+		{-100, io.SeekEnd, int64(len(plaintext)) - 100},
+		{5, io.SeekStart, 5},
+		{StreamChunkSize, io.SeekCurrent, 5 + 20 + StreamChunkSize}, // After reading 20 bytes at 5
+	}
+	for _, tt := range tests {
+		pos, err := r.Seek(tt.offset, tt.whence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos != tt.want {
+			t.Errorf("Seek(%d, %d) = %d; want %d", tt.offset, tt.whence, pos, tt.want)
+		}
+		buf := make([]byte, 20)
+		_, err = io.ReadFull(r, buf)
+		if err != nil {
+			t.Fatalf("reading at %d: %v", pos, err)
+		}
+		if !bytes.Equal(buf, plaintext[pos:pos+20]) {
+			t.Errorf("wrong bytes at %d", pos)
+		}
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	key, _ := NewDataKey()
+	plaintext := make([]byte, 2*StreamChunkSize)
+	rand.Read(plaintext)
+	sealed := sealStream(t, key, plaintext, []byte("blob:a"))
+
+	read := func(sealed []byte, key, aad []byte) error {
+		r, err := NewStreamReader(bytes.NewReader(sealed), int64(len(sealed)), key, aad)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadAll(r)
+		return err
+	}
+
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)/2] ^= 1
+	otherKey, _ := NewDataKey()
+
+	tests := map[string]error{
+		"bit flipped":            read(flipped, key, []byte("blob:a")),
+		"truncated at a chunk":   read(sealed[:streamPrefixSize+sealedChunkSize], key, []byte("blob:a")),
+		"truncated mid-chunk":    read(sealed[:len(sealed)-100], key, []byte("blob:a")),
+		"other blob's name":      read(sealed, key, []byte("blob:b")),
+		"wrong key":              read(sealed, otherKey, []byte("blob:a")),
+		"shorter than one chunk": read(sealed[:streamPrefixSize+5], key, []byte("blob:a")),
+	}
+	for name, err := range tests {
+		if !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: error = %v; want ErrDecrypt", name, err)
+		}
+	}
+}
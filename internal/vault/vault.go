@@ -0,0 +1,143 @@
+// internal/vault/vault.go
+
+// Package vault implements envelope encryption for data stored at rest.
+//
+// Notes are encrypted with AES-256-GCM under a random data key. Data keys are
+// stored in the database only in wrapped form: encrypted with a master key that
+// lives in the app's configuration, never in the database. Losing the master
+// keys means losing the data; leaking the database alone reveals nothing.
+//
+// Master keys have short IDs so several can be configured at once. The first
+// one wraps new data keys; the others are only used to unwrap keys that were
+// wrapped before a rotation.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length in bytes of master and data keys (AES-256).
+const KeySize = 32
+
+// wrapAAD binds wrapped data keys to their purpose, so a wrapped key can't be
+// passed off as an encrypted note or vice versa.
+var wrapAAD = []byte("mood-notes data key")
+
+// ErrDecrypt is returned when a ciphertext was tampered with or the key is wrong.
+var ErrDecrypt = errors.New("vault: message authentication failed")
+
+// Keyring holds the configured master keys.
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// ParseKeyring reads master keys written as "id:base64key". The first entry is
+// the current key. Generate a key with: openssl rand -base64 32
+func ParseKeyring(entries []string) (*Keyring, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("vault: no master keys given")
+	}
+	k := &Keyring{keys: make(map[string][]byte, len(entries))}
+	for i, entry := range entries {
+		id, encoded, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("vault: master key %d must look like id:base64key", i+1)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("vault: master key %q must be %d base64-encoded bytes", id, KeySize)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("vault: master key id %q is used twice", id)
+		}
+		k.keys[id] = key
+		if i == 0 {
+			k.current = id
+		}
+	}
+	return k, nil
+}
+
+// CurrentID returns the ID of the master key used to wrap new data keys.
+func (k *Keyring) CurrentID() string {
+	return k.current
+}
+
+// NewDataKey returns a fresh random data key.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("vault: generating data key: %w", err)
+	}
+	return key, nil
+}
+
+// Wrap encrypts dataKey with the current master key and returns it together with that key's ID.
+func (k *Keyring) Wrap(dataKey []byte) (wrapped []byte, masterID string, err error) {
+	wrapped, err = Seal(k.keys[k.current], dataKey, wrapAAD)
+	if err != nil {
+		return nil, "", err
+	}
+	return wrapped, k.current, nil
+}
+
+// Unwrap decrypts a data key that was wrapped with the master key masterID.
+func (k *Keyring) Unwrap(wrapped []byte, masterID string) ([]byte, error) {
+	master, ok := k.keys[masterID]
+	if !ok {
+		return nil, fmt.Errorf("vault: master key %q is not configured", masterID)
+	}
+	return Open(master, wrapped, wrapAAD)
+}
+
+// Seal encrypts plaintext with AES-GCM. The random nonce is prepended to the result.
+// aad is authenticated but not encrypted; the same aad must be given to Open.
+func Seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("vault: generating nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open decrypts a message produced by Seal.
+func Open(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// newGCM returns an AES-GCM AEAD for a 32-byte key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("vault: key must be %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,3 @@
+-- migrations/000002_add_occurred_at_to_mood_notes.down.sql
+DROP INDEX IF EXISTS mood_notes_occurred_at_idx;
+ALTER TABLE mood_notes DROP COLUMN IF EXISTS occurred_at;
@@ -0,0 +1,13 @@
+-- migrations/000002_add_occurred_at_to_mood_notes.up.sql
+-- occurred_at is the moment the entry is about, which the writer can backdate.
+-- created_at/updated_at remain untouched audit timestamps.
+ALTER TABLE mood_notes ADD COLUMN IF NOT EXISTS occurred_at TIMESTAMPTZ;
+
+-- Existing entries were written on the day they describe.
+UPDATE mood_notes SET occurred_at = created_at WHERE occurred_at IS NULL;
+
+ALTER TABLE mood_notes ALTER COLUMN occurred_at SET DEFAULT NOW();
+ALTER TABLE mood_notes ALTER COLUMN occurred_at SET NOT NULL;
+
+-- Listings are ordered newest entry first.
+CREATE INDEX IF NOT EXISTS mood_notes_occurred_at_idx ON mood_notes (occurred_at DESC, id DESC);
@@ -0,0 +1,2 @@
+-- migrations/000003_create_email_sends_table.down.sql
+DROP TABLE IF EXISTS email_sends;
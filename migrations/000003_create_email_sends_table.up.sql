@@ -0,0 +1,11 @@
+-- migrations/000003_create_email_sends_table.up.sql
+-- One row per scheduled email actually sent (e.g. the daily reminder for a given
+-- local day). The unique constraint is what stops restarts from double-sending.
+CREATE TABLE IF NOT EXISTS email_sends (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,              -- e.g. 'daily-reminder'
+    recipient TEXT NOT NULL,
+    period_start DATE NOT NULL,      -- Local day (or week start) the email is for
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, recipient, period_start)
+);
@@ -0,0 +1,2 @@
+-- migrations/000004_create_email_unsubscribes_table.down.sql
+DROP TABLE IF EXISTS email_unsubscribes;
@@ -0,0 +1,8 @@
+-- migrations/000004_create_email_unsubscribes_table.up.sql
+-- Opt-outs from optional emails (e.g. 'weekly-digest'), recorded by the signed unsubscribe link.
+CREATE TABLE IF NOT EXISTS email_unsubscribes (
+    kind TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (kind, recipient)
+);
@@ -0,0 +1,21 @@
+-- migrations/000005_add_note_encryption.down.sql
+-- Encrypted notes can't be decrypted in SQL, so refuse to drop their keys.
+DO $$
+BEGIN
+   IF EXISTS (SELECT 1 FROM mood_notes WHERE key_id IS NOT NULL) THEN
+      RAISE EXCEPTION 'mood_notes still has encrypted rows; rolling back would leave them unreadable';
+   END IF;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+   NEW.updated_at = NOW();
+   RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP INDEX IF EXISTS mood_notes_key_id_idx;
+ALTER TABLE mood_notes DROP COLUMN IF EXISTS key_id;
+DROP TABLE IF EXISTS data_keys;
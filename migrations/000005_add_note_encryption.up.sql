@@ -0,0 +1,30 @@
+-- migrations/000005_add_note_encryption.up.sql
+-- Envelope encryption for note titles and content (see internal/vault).
+-- Data keys are stored wrapped by a master key that only the app's config holds.
+CREATE TABLE IF NOT EXISTS data_keys (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    wrapped_key BYTEA NOT NULL,
+    master_key_id TEXT NOT NULL,     -- Which configured master key wrapped it
+    active BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- At most one key encrypts new notes at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS data_keys_active_idx ON data_keys (active) WHERE active;
+
+-- NULL key_id means title/content are plaintext; otherwise they hold
+-- base64(nonce || AES-GCM ciphertext) under that data key.
+ALTER TABLE mood_notes ADD COLUMN IF NOT EXISTS key_id BIGINT REFERENCES data_keys (id);
+CREATE INDEX IF NOT EXISTS mood_notes_key_id_idx ON mood_notes (key_id);
+
+-- Re-encrypting a note rewrites its columns without editing it, so updated_at
+-- now only moves when the version does (every edit bumps the version).
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+   IF NEW.version IS DISTINCT FROM OLD.version THEN
+      NEW.updated_at = NOW();
+   END IF;
+   RETURN NEW;
+END;
+$$ language 'plpgsql';
@@ -0,0 +1,2 @@
+-- migrations/000006_create_note_shares_table.down.sql
+DROP TABLE IF EXISTS note_shares;
@@ -0,0 +1,16 @@
+-- migrations/000006_create_note_shares_table.up.sql
+-- Read-only links to a single note (e.g. for a therapist). Only a SHA-256 hash
+-- of each token is stored, so the table can't be used to rebuild the links.
+CREATE TABLE IF NOT EXISTS note_shares (
+    id BIGSERIAL PRIMARY KEY,
+    note_id BIGINT NOT NULL REFERENCES mood_notes (id) ON DELETE CASCADE,
+    token_hash BYTEA NOT NULL UNIQUE,
+    passcode_hash BYTEA,             -- bcrypt; NULL when no passcode is required
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,          -- NULL never expires
+    revoked_at TIMESTAMPTZ,
+    views INTEGER NOT NULL DEFAULT 0,
+    last_viewed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS note_shares_note_id_idx ON note_shares (note_id);
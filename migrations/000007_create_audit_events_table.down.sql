@@ -0,0 +1,2 @@
+-- migrations/000007_create_audit_events_table.down.sql
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,19 @@
+-- migrations/000007_create_audit_events_table.up.sql
+-- Append-only record of every data-changing action. Rows are written in the same
+-- transaction as the change itself, so the log can't miss or invent a change.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor TEXT NOT NULL,             -- Who did it ('owner' until there are accounts)
+    action TEXT NOT NULL,            -- e.g. 'note.create', 'share.revoke'
+    target_type TEXT NOT NULL,       -- e.g. 'note', 'share'
+    target_id BIGINT NOT NULL,
+    version_before INTEGER,          -- Note version before/after, where it applies
+    version_after INTEGER,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_occurred_at_idx ON audit_events (occurred_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS audit_events_target_idx ON audit_events (target_type, target_id);
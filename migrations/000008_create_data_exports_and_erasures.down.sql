@@ -0,0 +1,3 @@
+-- migrations/000008_create_data_exports_and_erasures.down.sql
+DROP TABLE IF EXISTS data_erasures;
+DROP TABLE IF EXISTS data_exports;
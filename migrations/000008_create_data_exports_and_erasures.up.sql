@@ -0,0 +1,25 @@
+-- migrations/000008_create_data_exports_and_erasures.up.sql
+-- "Download all my data": archives are built in the background and kept
+-- only until expires_at, after which the archive bytes are purged.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'building', 'ready', 'failed', 'expired')),
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    archive BYTEA,                   -- ZIP of JSON files; NULL until ready and after expiry
+    error TEXT NOT NULL DEFAULT ''
+);
+
+-- "Delete all my data": erasure runs once scheduled_for passes, unless cancelled first.
+CREATE TABLE IF NOT EXISTS data_erasures (
+    id BIGSERIAL PRIMARY KEY,
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    cancelled_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+-- Only one erasure can be waiting at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS data_erasures_pending_idx ON data_erasures ((TRUE))
+    WHERE cancelled_at IS NULL AND completed_at IS NULL;
@@ -0,0 +1,2 @@
+-- migrations/000009_create_note_templates_table.down.sql
+DROP TABLE IF EXISTS note_templates;
@@ -0,0 +1,15 @@
+-- migrations/000009_create_note_templates_table.up.sql
+-- The owner's own note templates ("Gratitude: 3 things...", a CBT thought record...).
+-- They pre-fill the new entry form. Unlike notes they are not encrypted: a
+-- template is a scaffold for an entry, not the entry itself.
+CREATE TABLE IF NOT EXISTS note_templates (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    name TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS note_templates_name_idx ON note_templates (LOWER(name));
@@ -0,0 +1,2 @@
+-- migrations/000010_create_drafts_table.down.sql
+DROP TABLE IF EXISTS drafts;
@@ -0,0 +1,22 @@
+-- migrations/000010_create_drafts_table.up.sql
+-- Autosaved copies of the note form, so a crash or a closed tab loses nothing.
+-- One draft per user and slot: 'new' for the new entry form, or the ID of the
+-- note being edited. Drafts are deleted when the note is saved or they expire.
+CREATE TABLE IF NOT EXISTS drafts (
+    id BIGSERIAL PRIMARY KEY,
+    owner TEXT NOT NULL,                 -- Whose draft; the single owner until the app has accounts
+    slot TEXT NOT NULL,
+    note_id BIGINT REFERENCES mood_notes (id) ON DELETE CASCADE,
+    base_version INTEGER,                -- Version of the note when editing started
+    title TEXT NOT NULL DEFAULT '',      -- Sealed like mood_notes when key_id is set
+    content TEXT NOT NULL DEFAULT '',
+    occurred_at TEXT NOT NULL DEFAULT '', -- Raw datetime-local value from the form
+    key_id BIGINT REFERENCES data_keys (id),
+    saved_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (owner, slot),
+    CHECK ((slot = 'new') = (note_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS drafts_expires_at_idx ON drafts (expires_at);
+CREATE INDEX IF NOT EXISTS drafts_key_id_idx ON drafts (key_id);
@@ -0,0 +1,3 @@
+-- migrations/000011_create_attachments_table.down.sql
+-- The blobs are not removed; delete the attachments directory by hand if needed.
+DROP TABLE IF EXISTS attachments;
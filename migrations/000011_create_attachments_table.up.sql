@@ -0,0 +1,20 @@
+-- migrations/000011_create_attachments_table.up.sql
+-- Images and audio clips attached to notes. The files themselves live in the
+-- blob store (see internal/blobstore); this table records where and what they are.
+-- Deleting a note detaches its attachments (note_id becomes NULL) rather than
+-- deleting the rows, so the data job can remove the blobs before the rows go.
+CREATE TABLE IF NOT EXISTS attachments (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    note_id BIGINT REFERENCES mood_notes (id) ON DELETE SET NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('image', 'audio')),
+    content_type TEXT NOT NULL,          -- Sniffed from the file, not taken from the upload
+    filename TEXT NOT NULL,              -- As uploaded, for Content-Disposition
+    size BIGINT NOT NULL,                -- Bytes stored, after location data was stripped
+    width INTEGER NOT NULL DEFAULT 0,    -- Images only
+    height INTEGER NOT NULL DEFAULT 0,
+    blob_key TEXT NOT NULL UNIQUE,
+    thumb_key TEXT                       -- JPEG thumbnail; images only
+);
+
+CREATE INDEX IF NOT EXISTS attachments_note_id_idx ON attachments (note_id);
@@ -0,0 +1,3 @@
+-- migrations/000012_add_client_id_to_mood_notes.down.sql
+DROP INDEX IF EXISTS mood_notes_client_id_idx;
+ALTER TABLE mood_notes DROP COLUMN IF EXISTS client_id;
@@ -0,0 +1,7 @@
+-- migrations/000012_add_client_id_to_mood_notes.up.sql
+-- Entries written offline are queued by the browser and sent later through the
+-- JSON API, possibly more than once if a response is lost. client_id is the ID
+-- the browser gave the entry, so a repeated send finds the note it already made.
+ALTER TABLE mood_notes ADD COLUMN IF NOT EXISTS client_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS mood_notes_client_id_idx ON mood_notes (client_id);
@@ -0,0 +1,7 @@
+-- migrations/000013_move_data_exports_to_blob_store.down.sql
+-- The archives stay in the blob store; their exports are marked expired.
+UPDATE data_exports SET status = 'expired' WHERE status = 'ready';
+DROP INDEX IF EXISTS data_exports_key_id_idx;
+ALTER TABLE data_exports DROP COLUMN IF EXISTS key_id;
+ALTER TABLE data_exports DROP COLUMN IF EXISTS blob_key;
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS archive BYTEA;
@@ -0,0 +1,11 @@
+-- migrations/000013_move_data_exports_to_blob_store.up.sql
+-- Export archives move out of the table into the blob store, sealed with a data
+-- key like notes are (see NoteCipher.SealStream); the table only says where.
+-- Archives built before this were stored in plaintext, so they are dropped and
+-- their exports marked expired; the owner can request a new one.
+UPDATE data_exports SET status = 'expired' WHERE status = 'ready';
+ALTER TABLE data_exports DROP COLUMN IF EXISTS archive;
+
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS blob_key TEXT UNIQUE;   -- NULL until ready and after expiry
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS key_id BIGINT REFERENCES data_keys (id); -- NULL when encryption is off
+CREATE INDEX IF NOT EXISTS data_exports_key_id_idx ON data_exports (key_id);
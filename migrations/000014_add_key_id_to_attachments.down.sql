@@ -0,0 +1,12 @@
+-- migrations/000014_add_key_id_to_attachments.down.sql
+-- Sealed blobs can't be decrypted without key_id, so refuse to drop it.
+DO $$
+BEGIN
+   IF EXISTS (SELECT 1 FROM attachments WHERE key_id IS NOT NULL) THEN
+      RAISE EXCEPTION 'attachments still has encrypted rows; rolling back would leave their files unreadable';
+   END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS attachments_key_id_idx;
+ALTER TABLE attachments DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,8 @@
+-- migrations/000014_add_key_id_to_attachments.up.sql
+-- Attachment blobs are sealed with a data key like notes (see NoteCipher.SealStream).
+-- NULL key_id means the blobs are plaintext: uploaded before this, or with
+-- encryption off. The re-encryption job moves them onto the active key.
+-- An erasure deletes every data key while the detached attachments still wait
+-- for the data job, hence ON DELETE SET NULL.
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS key_id BIGINT REFERENCES data_keys (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS attachments_key_id_idx ON attachments (key_id);
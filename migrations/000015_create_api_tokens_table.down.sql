@@ -0,0 +1,2 @@
+-- migrations/000015_create_api_tokens_table.down.sql
+DROP TABLE IF EXISTS api_tokens;
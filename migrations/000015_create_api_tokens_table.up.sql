@@ -0,0 +1,12 @@
+-- migrations/000015_create_api_tokens_table.up.sql
+-- Personal API tokens for the /v1 JSON API, for scripts and apps that can't use
+-- the browser. Like share links, only a SHA-256 hash of each token is stored.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,            -- Space-separated, e.g. 'notes:read notes:write'
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,          -- NULL never expires
+    last_used_at TIMESTAMPTZ
+);
@@ -0,0 +1,3 @@
+-- migrations/000016_create_tokens_table.down.sql
+DROP TABLE IF EXISTS verified_emails;
+DROP TABLE IF EXISTS tokens;
@@ -0,0 +1,16 @@
+-- migrations/000016_create_tokens_table.up.sql
+-- Single-use tokens sent by email, stored as SHA-256 hashes. The only scope so far
+-- is 'email-verification', which confirms a reminder or digest recipient before
+-- anything else is mailed to it.
+CREATE TABLE IF NOT EXISTS tokens (
+    hash BYTEA PRIMARY KEY,
+    scope TEXT NOT NULL,
+    email TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Addresses whose owner followed a verification link.
+CREATE TABLE IF NOT EXISTS verified_emails (
+    email TEXT PRIMARY KEY,
+    verified_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,26 @@
+// migrations/embed.go
+
+// Package migrations embeds the SQLite schema migrations so a self-hosted
+// SQLite install can migrate itself at startup. The PostgreSQL migrations in
+// this directory are applied with the migrate tool (make db/migrations/up).
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// sqliteFiles holds sqlite/NNNNNN_name.up.sql. SQLite installs only migrate
+// forward, so there are no down migrations; back up the database file instead.
+//
+//go:embed sqlite/*.up.sql
+var sqliteFiles embed.FS
+
+// SQLite returns the SQLite migrations, for data.MigrateSQLite.
+func SQLite() fs.FS {
+	sub, err := fs.Sub(sqliteFiles, "sqlite")
+	if err != nil {
+		panic(err) // Only possible if the directory name above is wrong
+	}
+	return sub
+}
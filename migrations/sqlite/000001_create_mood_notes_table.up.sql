@@ -0,0 +1,14 @@
+-- migrations/sqlite/000001_create_mood_notes_table.up.sql
+-- SQLite counterpart of migrations/000001. Timestamps are stored as UTC text in
+-- the format the driver reads back as time.Time, so they compare correctly as strings.
+CREATE TABLE IF NOT EXISTS mood_notes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    title TEXT NOT NULL CHECK (title <> ''),
+    content TEXT NOT NULL CHECK (content <> ''),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- SQLite triggers can't change the row being updated, so there is no
+-- updated_at trigger: MoodNoteModel.Update sets updated_at itself.
@@ -0,0 +1,8 @@
+-- migrations/sqlite/000002_add_occurred_at_to_mood_notes.up.sql
+-- SQLite can't add a NOT NULL column with a non-constant default, so the
+-- column stays nullable here; the app always sets it.
+ALTER TABLE mood_notes ADD COLUMN occurred_at TIMESTAMP;
+
+UPDATE mood_notes SET occurred_at = created_at WHERE occurred_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS mood_notes_occurred_at_idx ON mood_notes (occurred_at DESC, id DESC);
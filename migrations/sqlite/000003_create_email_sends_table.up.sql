@@ -0,0 +1,9 @@
+-- migrations/sqlite/000003_create_email_sends_table.up.sql
+CREATE TABLE IF NOT EXISTS email_sends (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    period_start TIMESTAMP NOT NULL, -- Local day (or week start) the email is for, as YYYY-MM-DD text
+    sent_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    UNIQUE (kind, recipient, period_start)
+);
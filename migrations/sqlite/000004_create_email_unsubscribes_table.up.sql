@@ -0,0 +1,7 @@
+-- migrations/sqlite/000004_create_email_unsubscribes_table.up.sql
+CREATE TABLE IF NOT EXISTS email_unsubscribes (
+    kind TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    PRIMARY KEY (kind, recipient)
+);
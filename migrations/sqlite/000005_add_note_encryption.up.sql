@@ -0,0 +1,13 @@
+-- migrations/sqlite/000005_add_note_encryption.up.sql
+CREATE TABLE IF NOT EXISTS data_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    wrapped_key BLOB NOT NULL,
+    master_key_id TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS data_keys_active_idx ON data_keys (active) WHERE active;
+
+ALTER TABLE mood_notes ADD COLUMN key_id INTEGER REFERENCES data_keys (id);
+CREATE INDEX IF NOT EXISTS mood_notes_key_id_idx ON mood_notes (key_id);
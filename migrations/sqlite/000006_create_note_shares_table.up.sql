@@ -0,0 +1,14 @@
+-- migrations/sqlite/000006_create_note_shares_table.up.sql
+CREATE TABLE IF NOT EXISTS note_shares (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id INTEGER NOT NULL REFERENCES mood_notes (id) ON DELETE CASCADE,
+    token_hash BLOB NOT NULL UNIQUE,
+    passcode_hash BLOB,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    views INTEGER NOT NULL DEFAULT 0,
+    last_viewed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS note_shares_note_id_idx ON note_shares (note_id);
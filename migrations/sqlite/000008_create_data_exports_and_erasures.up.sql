@@ -0,0 +1,23 @@
+-- migrations/sqlite/000008_create_data_exports_and_erasures.up.sql
+CREATE TABLE IF NOT EXISTS data_exports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    requested_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'building', 'ready', 'failed', 'expired')),
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    archive BLOB,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS data_erasures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    requested_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    scheduled_for TIMESTAMP NOT NULL,
+    cancelled_at TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+-- Only one erasure can be waiting at a time. SQLite can't index a constant,
+-- so the partial index covers a column that is always NULL in its rows.
+CREATE UNIQUE INDEX IF NOT EXISTS data_erasures_pending_idx ON data_erasures (IFNULL(cancelled_at, 0))
+    WHERE cancelled_at IS NULL AND completed_at IS NULL;
@@ -0,0 +1,19 @@
+-- migrations/sqlite/000010_create_drafts_table.up.sql
+CREATE TABLE IF NOT EXISTS drafts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner TEXT NOT NULL,
+    slot TEXT NOT NULL,
+    note_id INTEGER REFERENCES mood_notes (id) ON DELETE CASCADE,
+    base_version INTEGER,
+    title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    occurred_at TEXT NOT NULL DEFAULT '',
+    key_id INTEGER REFERENCES data_keys (id),
+    saved_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    expires_at TIMESTAMP NOT NULL,
+    UNIQUE (owner, slot),
+    CHECK ((slot = 'new') = (note_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS drafts_expires_at_idx ON drafts (expires_at);
+CREATE INDEX IF NOT EXISTS drafts_key_id_idx ON drafts (key_id);
@@ -0,0 +1,16 @@
+-- migrations/sqlite/000011_create_attachments_table.up.sql
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    note_id INTEGER REFERENCES mood_notes (id) ON DELETE SET NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('image', 'audio')),
+    content_type TEXT NOT NULL,
+    filename TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    blob_key TEXT NOT NULL UNIQUE,
+    thumb_key TEXT
+);
+
+CREATE INDEX IF NOT EXISTS attachments_note_id_idx ON attachments (note_id);
@@ -0,0 +1,4 @@
+-- migrations/sqlite/000012_add_client_id_to_mood_notes.up.sql
+ALTER TABLE mood_notes ADD COLUMN client_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS mood_notes_client_id_idx ON mood_notes (client_id);
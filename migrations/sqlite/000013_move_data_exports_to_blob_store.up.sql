@@ -0,0 +1,8 @@
+-- migrations/sqlite/000013_move_data_exports_to_blob_store.up.sql
+UPDATE data_exports SET status = 'expired' WHERE status = 'ready';
+ALTER TABLE data_exports DROP COLUMN archive;
+
+ALTER TABLE data_exports ADD COLUMN blob_key TEXT;
+ALTER TABLE data_exports ADD COLUMN key_id INTEGER REFERENCES data_keys (id);
+CREATE UNIQUE INDEX IF NOT EXISTS data_exports_blob_key_idx ON data_exports (blob_key);
+CREATE INDEX IF NOT EXISTS data_exports_key_id_idx ON data_exports (key_id);
@@ -0,0 +1,3 @@
+-- migrations/sqlite/000014_add_key_id_to_attachments.up.sql
+ALTER TABLE attachments ADD COLUMN key_id INTEGER REFERENCES data_keys (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS attachments_key_id_idx ON attachments (key_id);
@@ -0,0 +1,10 @@
+-- migrations/sqlite/000015_create_api_tokens_table.up.sql
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    token_hash BLOB NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP
+);
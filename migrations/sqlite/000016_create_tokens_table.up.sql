@@ -0,0 +1,12 @@
+-- migrations/sqlite/000016_create_tokens_table.up.sql
+CREATE TABLE IF NOT EXISTS tokens (
+    hash BLOB PRIMARY KEY,
+    scope TEXT NOT NULL,
+    email TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS verified_emails (
+    email TEXT PRIMARY KEY,
+    verified_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
@@ -3,7 +3,8 @@ package ui
 
 import "embed"
 
-// Embed the html templates and the entire static directory and its contents.
+// Embed the html templates, the email templates, the built-in journaling templates
+// and prompts, and the entire static directory and its contents.
 // NOTE: Paths are relative to this ui directory.
-//go:embed html static
+//go:embed html email static journal
 var Files embed.FS
\ No newline at end of file
@@ -0,0 +1,325 @@
+// ui/static/js/main.js
+// Progressive enhancements for Feel Flow. Kept as a static file so the
+// Content-Security-Policy never needs 'unsafe-inline'.
+(function () {
+    "use strict";
+
+    // Ask for confirmation before submitting any form marked with data-confirm.
+    document.addEventListener("submit", function (event) {
+        var form = event.target;
+        var message = form.getAttribute("data-confirm");
+        if (message && !window.confirm(message)) {
+            event.preventDefault();
+        }
+    });
+})();
+
+// Live Markdown preview for textareas marked with data-preview-url.
+// The server renders and sanitises the HTML, so it is safe to insert as-is.
+(function () {
+    "use strict";
+
+    var textarea = document.querySelector("textarea[data-preview-url]");
+    if (!textarea) {
+        return;
+    }
+    var target = document.getElementById(textarea.getAttribute("data-preview-target"));
+    if (!target) {
+        return;
+    }
+
+    var timer = null;
+    var latest = 0;
+
+    function refresh() {
+        var requestID = ++latest;
+        fetch(textarea.getAttribute("data-preview-url"), {
+            method: "POST",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+            body: new URLSearchParams({ content: textarea.value }),
+            credentials: "same-origin"
+        })
+            .then(function (response) {
+                return response.ok ? response.text() : Promise.reject(response.status);
+            })
+            .then(function (html) {
+                // Ignore responses that arrive after a newer request was sent.
+                if (requestID === latest) {
+                    target.innerHTML = html;
+                }
+            })
+            .catch(function () {
+                // Keep the last good preview; the form still works without it.
+            });
+    }
+
+    textarea.addEventListener("input", function () {
+        clearTimeout(timer);
+        timer = setTimeout(refresh, 400);
+    });
+    refresh();
+})();
+
+// Timezone detection: remember the browser's IANA timezone in the "tz" cookie so the
+// server can show dates (and group entries by day) in the writer's local time.
+(function () {
+    "use strict";
+
+    var zone;
+    try {
+        zone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+    } catch (e) {
+        return;
+    }
+    // IANA names are cookie-safe as-is; skip anything unexpected.
+    if (!zone || !/^[A-Za-z0-9_+\-\/]+$/.test(zone)) {
+        return;
+    }
+
+    var match = document.cookie.match(/(?:^|;\s*)tz=([^;]*)/);
+    var saved = match ? match[1] : "";
+    if (saved === zone) {
+        return;
+    }
+
+    var secure = window.location.protocol === "https:" ? "; Secure" : "";
+    document.cookie = "tz=" + zone + "; Path=/; Max-Age=31536000; SameSite=Lax" + secure;
+
+    // Re-render once if this page used a different zone. Only happens when the cookie
+    // changed, so an unknown zone the server rejects can't cause a reload loop.
+    if (document.documentElement.getAttribute("data-timezone") !== zone) {
+        window.location.reload();
+    }
+})();
+
+// Draft autosave for forms marked with data-draft-url: every few seconds, if the
+// form changed, PUT its fields to the server. A crash, timeout or closed tab then
+// loses at most a few seconds of writing; the form offers to restore the draft.
+(function () {
+    "use strict";
+
+    var form = document.querySelector("form[data-draft-url]");
+    if (!form || !window.fetch || !window.URLSearchParams) {
+        return;
+    }
+    var status = form.querySelector(".draft-status");
+    var interval = 5000;
+
+    function snapshot() {
+        return new URLSearchParams(new FormData(form)).toString();
+    }
+
+    var saved = snapshot(); // Unchanged forms are never saved as drafts
+    var saving = false;
+    var submitted = false;
+
+    function save() {
+        var body = snapshot();
+        if (saving || submitted || body === saved) {
+            return;
+        }
+        saving = true;
+        fetch(form.getAttribute("data-draft-url"), {
+            method: "PUT",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+            body: body,
+            credentials: "same-origin"
+        })
+            .then(function (response) {
+                if (!response.ok) {
+                    return Promise.reject(response.status);
+                }
+                saved = body;
+                if (status) {
+                    status.textContent = "Draft saved at " + new Date().toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" });
+                }
+            })
+            .catch(function () {
+                if (status) {
+                    status.textContent = "Draft not saved";
+                }
+            })
+            .then(function () {
+                saving = false;
+            });
+    }
+
+    // Stop once the form is submitted: the server discards the draft when the
+    // note is saved, and a late autosave would bring it back.
+    form.addEventListener("submit", function () {
+        submitted = true;
+    });
+    setInterval(save, interval);
+    // Last chance when the tab is hidden or closed; keepalive lets it outlive the page.
+    document.addEventListener("visibilitychange", function () {
+        if (document.visibilityState !== "hidden") {
+            return;
+        }
+        var body = snapshot();
+        if (submitted || body === saved) {
+            return;
+        }
+        fetch(form.getAttribute("data-draft-url"), {
+            method: "PUT",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+            body: body,
+            credentials: "same-origin",
+            keepalive: true
+        }).then(function (response) {
+            if (response.ok) {
+                saved = body;
+            }
+        }).catch(function () {});
+    });
+})();
+
+// Offline mode: register the service worker (sw.js) and show the entries written
+// offline that are waiting to be saved. The worker owns the outbox; pages ask it to
+// sync, and it sends the outbox back ({type: "outbox"}) whenever it changes.
+(function () {
+    "use strict";
+
+    // Public pages such as shared notes link no manifest: a visitor's browser
+    // mustn't install a worker that caches the app and calls its API.
+    if (!("serviceWorker" in navigator) || !document.querySelector('link[rel="manifest"]')) {
+        return;
+    }
+    navigator.serviceWorker.register("/sw.js", { scope: "/" }).catch(function () {});
+
+    var banner = document.querySelector("[data-outbox-status]");
+    var list = document.querySelector("[data-outbox]");
+    var empty = document.querySelector("[data-outbox-empty]");
+
+    function post(msg) {
+        navigator.serviceWorker.ready.then(function (reg) {
+            if (reg.active) {
+                reg.active.postMessage(msg);
+            }
+        });
+    }
+
+    var states = {
+        pending: "Waiting to be saved",
+        conflict: "Saved as a draft",
+        attention: "Couldn't be saved"
+    };
+
+    function renderOutbox(items) {
+        var pending = items.filter(function (item) { return item.status === "pending"; }).length;
+        var other = items.length - pending;
+        if (banner) {
+            banner.hidden = items.length === 0 || !!list;
+            banner.textContent = "";
+            if (items.length > 0) {
+                var parts = [];
+                if (pending > 0) {
+                    parts.push(pending + (pending === 1 ? " entry is" : " entries are") + " waiting to be saved.");
+                }
+                if (other > 0) {
+                    parts.push(other + (other === 1 ? " entry needs" : " entries need") + " your attention.");
+                }
+                banner.appendChild(document.createTextNode(parts.join(" ") + " "));
+                var link = document.createElement("a");
+                link.href = "/offline";
+                link.textContent = "Details";
+                banner.appendChild(link);
+            }
+        }
+        if (!list) {
+            return;
+        }
+        if (empty) {
+            empty.hidden = items.length > 0;
+        }
+        list.textContent = "";
+        items.forEach(function (item) {
+            var li = document.createElement("li");
+            var title = document.createElement("strong");
+            title.textContent = item.title || "(untitled)";
+            li.appendChild(title);
+            li.appendChild(document.createTextNode(" - " + (item.noteID ? "edit, " : "new entry, ") + states[item.status]));
+            if (item.message) {
+                var message = document.createElement("span");
+                message.className = "outbox-message";
+                message.textContent = item.message;
+                li.appendChild(message);
+            }
+            if (item.status === "conflict") {
+                var open = document.createElement("a");
+                open.href = "/note/edit/" + item.noteID; // Its draft banner offers to restore the edit
+                open.textContent = "Open the entry";
+                li.appendChild(document.createTextNode(" "));
+                li.appendChild(open);
+            }
+            if (item.status !== "pending") {
+                var dismiss = document.createElement("button");
+                dismiss.type = "button";
+                dismiss.className = "btn";
+                dismiss.textContent = "Dismiss";
+                dismiss.setAttribute("data-dismiss", item.id);
+                li.appendChild(document.createTextNode(" "));
+                li.appendChild(dismiss);
+            }
+            list.appendChild(li);
+        });
+    }
+
+    navigator.serviceWorker.addEventListener("message", function (event) {
+        if (event.data && event.data.type === "outbox") {
+            renderOutbox(event.data.items);
+        }
+    });
+    if (list) {
+        list.addEventListener("click", function (event) {
+            var id = event.target.getAttribute("data-dismiss");
+            if (id) {
+                post({ type: "dismiss", id: id });
+            }
+        });
+    }
+
+    // Try to sync on every page load and whenever the connection comes back.
+    post({ type: "sync" });
+    window.addEventListener("online", function () {
+        post({ type: "sync" });
+    });
+
+    // The offline page also says when an entry was just queued, and lists the
+    // entries cached for reading (the worker answers /v1/notes from its cache).
+    var queued = document.querySelector("[data-queued-message]");
+    var heading = document.querySelector("[data-offline-heading]");
+    if (heading && navigator.onLine) {
+        heading.textContent = "Saved on this device"; // Reached from the outbox banner's link
+    }
+    if (queued && new URLSearchParams(window.location.search).get("queued") === "1") {
+        queued.hidden = false;
+    }
+    var recent = document.querySelector("[data-recent-notes]");
+    if (!recent) {
+        return;
+    }
+    var csrf = document.querySelector('meta[name="csrf-token"]');
+    fetch("/v1/notes?limit=20", {
+        headers: { "Accept": "application/json", "X-CSRF-Token": csrf ? csrf.content : "" },
+        credentials: "same-origin"
+    })
+        .then(function (response) {
+            return response.ok ? response.json() : Promise.reject(response.status);
+        })
+        .then(function (body) {
+            var recentEmpty = document.querySelector("[data-recent-empty]");
+            if (recentEmpty) {
+                recentEmpty.hidden = body.notes.length > 0;
+            }
+            body.notes.forEach(function (note) {
+                var li = document.createElement("li");
+                var link = document.createElement("a");
+                link.href = "/note/" + note.id;
+                link.textContent = note.title || "(untitled)";
+                li.appendChild(link);
+                li.appendChild(document.createTextNode(" - " + new Date(note.occurred_at).toLocaleString()));
+                recent.appendChild(li);
+            });
+        })
+        .catch(function () {});
+})();
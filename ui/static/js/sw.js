@@ -0,0 +1,472 @@
+// ui/static/js/sw.js
+// Service worker for offline use. Served at /sw.js (not /static/js/sw.js) so it
+// controls the whole site; the server fills in VERSION and SHELL_ASSETS, see
+// serviceWorker in cmd/web/assets.go.
+//
+// - The app shell (versioned static files, the home page, the new entry form and
+//   /offline) is cached when the worker installs.
+// - Pages are fetched from the network first and cached as they are visited, and
+//   the most recent entries are cached in the background, for reading offline.
+// - A new or edited entry submitted while offline is queued in IndexedDB (the
+//   outbox) and sent through the JSON API once the network is back. Edits carry
+//   the version they were based on; if the entry changed in the meantime the
+//   server answers 409 and the edit is kept as a draft of the entry instead, so
+//   the edit form offers to restore it and nothing is overwritten silently. When
+//   the server answers 429, syncing pauses for as long as its Retry-After says.
+// - The API accepts the worker's requests by the browser's session cookie and
+//   the CSRF token that goes with it, fetched from /session/csrf.
+"use strict";
+
+var VERSION = "__VERSION__";
+var SHELL_ASSETS = ["__SHELL_ASSETS__"];
+var SHELL_CACHE = "feelflow-shell-" + VERSION;
+var PAGES_CACHE = "feelflow-pages-" + VERSION;
+var SHELL_PAGES = ["/", "/note/new", "/offline"];
+var RECENT_NOTES_URL = "/v1/notes?limit=20";
+var MAX_CACHED_PAGES = 60;
+var RECENT_REFRESH_INTERVAL = 5 * 60 * 1000;
+var MAX_RETRY_AFTER = 10 * 60; // Seconds; longer waits are left to the next page load
+
+// Pages worth keeping for offline reading; everything else is network only.
+var CACHED_PAGE = /^\/(?:|offline|note\/new|note\/\d+|note\/edit\/\d+)$/;
+// Forms whose submissions are queued when offline.
+var QUEUED_FORM = /^\/note\/(?:new|edit\/(\d+))$/;
+
+// --- Lifecycle ---
+
+self.addEventListener("install", function (event) {
+    event.waitUntil(
+        Promise.all([
+            caches.open(SHELL_CACHE).then(function (cache) { return cache.addAll(SHELL_ASSETS); }),
+            caches.open(PAGES_CACHE).then(function (cache) { return cache.addAll(SHELL_PAGES); })
+        ]).then(function () {
+            return self.skipWaiting();
+        })
+    );
+});
+
+// Cached pages link to the asset versions of their time, so they go with the old shell.
+self.addEventListener("activate", function (event) {
+    event.waitUntil(
+        caches.keys().then(function (names) {
+            return Promise.all(names.filter(function (name) {
+                return name !== SHELL_CACHE && name !== PAGES_CACHE;
+            }).map(function (name) {
+                return caches.delete(name);
+            }));
+        }).then(function () {
+            return self.clients.claim();
+        }).then(function () {
+            return syncOutbox();
+        })
+    );
+});
+
+// --- Fetching ---
+
+self.addEventListener("fetch", function (event) {
+    var request = event.request;
+    var url = new URL(request.url);
+    if (url.origin !== self.location.origin) {
+        return;
+    }
+
+    if (request.method === "POST" && request.mode === "navigate" && QUEUED_FORM.test(url.pathname)) {
+        event.respondWith(submitOrQueue(request, url.pathname));
+        return;
+    }
+    if (request.method !== "GET" || url.pathname === "/sw.js") {
+        return;
+    }
+    if (url.pathname.indexOf("/static/") === 0) {
+        event.respondWith(cacheFirst(request));
+    } else if (url.pathname.indexOf("/v1/notes") === 0) {
+        event.respondWith(networkFirst(request, false));
+    } else if (request.mode === "navigate") {
+        event.respondWith(networkFirst(request, CACHED_PAGE.test(url.pathname)));
+    }
+});
+
+// cacheFirst serves static files from the cache. Versioned URLs never change,
+// so whatever is cached is current.
+function cacheFirst(request) {
+    return caches.match(request).then(function (cached) {
+        return cached || fetch(request).then(function (response) {
+            if (response.ok && new URL(request.url).searchParams.has("v")) {
+                var copy = response.clone();
+                caches.open(SHELL_CACHE).then(function (cache) { cache.put(request, copy); });
+            }
+            return response;
+        });
+    });
+}
+
+// networkFirst fetches request, keeping a copy when store is true, and falls back
+// to the cached copy (or, for pages, to /offline) when the network is down.
+function networkFirst(request, store) {
+    return fetch(request).then(function (response) {
+        if (store && response.ok && response.type === "basic") {
+            var copy = response.clone();
+            caches.open(PAGES_CACHE).then(function (cache) {
+                return cache.put(request, copy);
+            }).then(trimPages);
+        }
+        return response;
+    }).catch(function (err) {
+        return caches.match(request).then(function (cached) {
+            if (cached) {
+                return cached;
+            }
+            if (request.mode !== "navigate") {
+                throw err;
+            }
+            // e.g. /note/new?template=gratitude falls back to the plain form.
+            return caches.match(request, { ignoreSearch: true }).then(function (similar) {
+                return similar || caches.match("/offline");
+            });
+        });
+    });
+}
+
+// trimPages drops the oldest cached pages beyond MAX_CACHED_PAGES.
+function trimPages() {
+    return caches.open(PAGES_CACHE).then(function (cache) {
+        return cache.keys().then(function (keys) {
+            var pinned = SHELL_PAGES.concat([RECENT_NOTES_URL]);
+            var pages = keys.filter(function (key) {
+                var url = new URL(key.url);
+                return pinned.indexOf(url.pathname + url.search) < 0;
+            });
+            var old = pages.slice(0, Math.max(0, pages.length - MAX_CACHED_PAGES));
+            return Promise.all(old.map(function (key) { return cache.delete(key); }));
+        });
+    });
+}
+
+// refreshRecentNotes caches the latest entries' pages for offline reading, at
+// most every RECENT_REFRESH_INTERVAL.
+var lastRecentRefresh = 0;
+
+function refreshRecentNotes() {
+    if (Date.now() - lastRecentRefresh < RECENT_REFRESH_INTERVAL) {
+        return Promise.resolve();
+    }
+    lastRecentRefresh = Date.now();
+    return caches.open(PAGES_CACHE).then(function (cache) {
+        return apiFetch(RECENT_NOTES_URL, { headers: { "Accept": "application/json" } }).then(function (response) {
+            if (!response.ok) {
+                return;
+            }
+            return cache.put(RECENT_NOTES_URL, response.clone()).then(function () {
+                return response.json();
+            }).then(function (body) {
+                return Promise.all(body.notes.map(function (note) {
+                    return cache.add("/note/" + note.id);
+                }));
+            }).then(trimPages);
+        });
+    }).catch(function () {
+        lastRecentRefresh = 0; // Offline: try again next time
+    });
+}
+
+// --- Outbox ---
+
+// submitOrQueue sends a note form as usual, and queues it if the network is down.
+function submitOrQueue(request, path) {
+    var copy = request.clone();
+    return fetch(request).catch(function () {
+        return copy.formData().then(function (form) {
+            return queueEntry(path, form);
+        }).then(function () {
+            return Response.redirect("/offline?queued=1", 303);
+        });
+    });
+}
+
+// queueEntry adds a submitted note form to the outbox.
+function queueEntry(path, form) {
+    var local = String(form.get("occurred_at") || "");
+    // A datetime-local value without a zone is read in this device's zone.
+    var occurred = local ? new Date(local) : new Date();
+    if (isNaN(occurred.getTime())) {
+        occurred = new Date();
+    }
+    var item = {
+        id: self.crypto.randomUUID(),
+        queuedAt: Date.now(),
+        title: String(form.get("title") || ""),
+        content: String(form.get("content") || ""),
+        occurredAt: occurred.toISOString(),
+        occurredAtLocal: local,
+        status: "pending",
+        message: ""
+    };
+    var edit = path.match(QUEUED_FORM)[1];
+    if (edit) {
+        item.noteID = Number(edit);
+        item.version = Number(form.get("version"));
+    }
+    return withStore("readwrite", function (store) {
+        store.put(item);
+    }).then(function () {
+        broadcast();
+        if (self.registration.sync) {
+            return self.registration.sync.register("outbox").catch(function () {});
+        }
+    });
+}
+
+// Background Sync, where supported, runs the outbox as soon as the device is
+// online. Failing the event makes the browser try again later.
+self.addEventListener("sync", function (event) {
+    if (event.tag === "outbox") {
+        event.waitUntil(syncOutbox().then(function (stopped) {
+            if (stopped) {
+                throw new Error("outbox not fully synced");
+            }
+        }));
+    }
+});
+
+// Pages ask for a sync when they load or come back online (see main.js), and to
+// dismiss entries that were handled or need attention.
+self.addEventListener("message", function (event) {
+    var msg = event.data || {};
+    if (msg.type === "sync") {
+        event.waitUntil(syncOutbox());
+    } else if (msg.type === "dismiss") {
+        event.waitUntil(withStore("readwrite", function (store) {
+            store.delete(msg.id);
+        }).then(broadcast));
+    }
+});
+
+var syncing = null;
+
+// syncOutbox sends pending entries oldest first, stopping at the first network
+// error or server problem so they are retried in order later. It resolves to true
+// if it stopped early.
+function syncOutbox() {
+    if (!syncing) {
+        syncing = outboxItems().then(function (items) {
+            var pending = items.filter(function (item) { return item.status === "pending"; });
+            return pending.reduce(function (chain, item) {
+                return chain.then(function (stop) {
+                    return stop || sendItem(item);
+                });
+            }, Promise.resolve(false));
+        }).then(function (stopped) {
+            return stopped || refreshRecentNotes().then(function () { return false; });
+        }).catch(function () {
+            return true;
+        }).then(function (stopped) {
+            syncing = null;
+            return broadcast().catch(function () {}).then(function () { return stopped; });
+        });
+    }
+    return syncing;
+}
+
+// sendItem sends one queued entry and resolves to true if syncing should stop.
+function sendItem(item) {
+    var body = { title: item.title, content: item.content, occurred_at: item.occurredAt };
+    var request;
+    if (item.noteID) {
+        body.version = item.version;
+        request = apiRequest("PUT", "/v1/notes/" + item.noteID, body);
+    } else {
+        body.client_id = item.id;
+        request = apiRequest("POST", "/v1/notes", body);
+    }
+
+    return request.then(function (response) {
+        if (response.ok) {
+            return removeItem(item.id).then(function () { return false; });
+        }
+        if (response.status === 409) {
+            return keepAsDraft(item);
+        }
+        if (response.status === 404 && item.noteID) {
+            // The entry was deleted elsewhere: keep the writing as a new entry.
+            delete item.noteID;
+            delete item.version;
+            return sendItem(item);
+        }
+        if (response.status === 400 || response.status === 422) {
+            return response.json().then(function (body) {
+                return markItem(item, "attention", describeError(body.error));
+            }).then(function () { return false; });
+        }
+        if (response.status === 429) {
+            retryLater(response);
+        }
+        return true; // Rate limited or a server problem: try again later
+    }, function () {
+        return true; // Offline
+    });
+}
+
+// keepAsDraft handles an edit conflict: the queued edit becomes the entry's
+// draft, based on the version it was written against, and the outbox keeps a
+// note pointing to the edit form, which offers to restore it.
+function keepAsDraft(item) {
+    var form = new URLSearchParams({
+        title: item.title,
+        content: item.content,
+        occurred_at: item.occurredAtLocal,
+        version: String(item.version)
+    });
+    return fetch("/drafts/" + item.noteID, {
+        method: "PUT",
+        headers: { "Content-Type": "application/x-www-form-urlencoded" },
+        body: form,
+        credentials: "same-origin"
+    }).then(function (response) {
+        if (response.status === 429) {
+            retryLater(response);
+        }
+        if (!response.ok) {
+            return true;
+        }
+        return markItem(item, "conflict", "The entry was changed elsewhere, so your offline edit was saved as a draft of it.")
+            .then(function () { return false; });
+    }, function () {
+        return true;
+    });
+}
+
+// retryLater syncs the outbox again once a 429 response's Retry-After has
+// passed. The browser may stop the worker before then, but pages ask for a sync
+// when they load, and Background Sync retries on its own schedule.
+var retryTimer = null;
+
+function retryLater(response) {
+    var seconds = parseInt(response.headers.get("Retry-After"), 10);
+    if (isNaN(seconds) || seconds < 1) {
+        seconds = 1;
+    }
+    if (seconds > MAX_RETRY_AFTER) {
+        return;
+    }
+    clearTimeout(retryTimer);
+    retryTimer = setTimeout(function () {
+        retryTimer = null;
+        syncOutbox();
+    }, seconds * 1000);
+}
+
+function apiRequest(method, url, body) {
+    return apiFetch(url, {
+        method: method,
+        headers: { "Content-Type": "application/json", "Accept": "application/json" },
+        body: JSON.stringify(body)
+    });
+}
+
+// apiFetch calls the JSON API with the session's CSRF token. A 401 means the
+// token is stale (e.g. the server restarted with a new secret), so it fetches a
+// new one and tries once more.
+var csrfToken = null;
+
+function apiFetch(url, init) {
+    function send() {
+        if (!csrfToken) {
+            csrfToken = fetch("/session/csrf", { credentials: "same-origin" }).then(function (response) {
+                return response.ok ? response.json() : Promise.reject(response.status);
+            }).then(function (body) {
+                return body.csrf_token;
+            }).catch(function (err) {
+                csrfToken = null;
+                throw err;
+            });
+        }
+        return csrfToken.then(function (token) {
+            init.headers["X-CSRF-Token"] = token;
+            init.credentials = "same-origin";
+            return fetch(url, init);
+        });
+    }
+    return send().then(function (response) {
+        if (response.status !== 401) {
+            return response;
+        }
+        csrfToken = null;
+        return send();
+    });
+}
+
+// describeError turns an API error (a message or a map of field errors) into text.
+function describeError(error) {
+    if (typeof error === "string") {
+        return error;
+    }
+    return Object.keys(error || {}).map(function (field) {
+        return field.replace("_", " ") + " " + error[field];
+    }).join("; ");
+}
+
+// broadcast sends the outbox to every open page.
+function broadcast() {
+    return outboxItems().then(function (items) {
+        return self.clients.matchAll({ type: "window", includeUncontrolled: true }).then(function (clients) {
+            clients.forEach(function (client) {
+                client.postMessage({ type: "outbox", items: items });
+            });
+        });
+    });
+}
+
+// --- IndexedDB ---
+
+function openDB() {
+    return new Promise(function (resolve, reject) {
+        var open = indexedDB.open("feelflow", 1);
+        open.onupgradeneeded = function () {
+            open.result.createObjectStore("outbox", { keyPath: "id" });
+        };
+        open.onsuccess = function () { resolve(open.result); };
+        open.onerror = function () { reject(open.error); };
+    });
+}
+
+// withStore runs fn on the outbox store and resolves with fn's result once the
+// transaction completes.
+function withStore(mode, fn) {
+    return openDB().then(function (db) {
+        return new Promise(function (resolve, reject) {
+            var tx = db.transaction("outbox", mode);
+            var result = fn(tx.objectStore("outbox"));
+            tx.oncomplete = function () {
+                db.close();
+                resolve(result && "result" in result ? result.result : undefined);
+            };
+            tx.onerror = function () {
+                db.close();
+                reject(tx.error);
+            };
+        });
+    });
+}
+
+function outboxItems() {
+    return withStore("readonly", function (store) {
+        return store.getAll();
+    }).then(function (items) {
+        return (items || []).sort(function (a, b) { return a.queuedAt - b.queuedAt; });
+    });
+}
+
+function removeItem(id) {
+    return withStore("readwrite", function (store) {
+        store.delete(id);
+    });
+}
+
+function markItem(item, status, message) {
+    item.status = status;
+    item.message = message;
+    return withStore("readwrite", function (store) {
+        store.put(item);
+    });
+}